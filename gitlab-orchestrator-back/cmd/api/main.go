@@ -7,6 +7,8 @@ import (
 	"gitlab-orchestrator-back/internal/handlers"
 	"gitlab-orchestrator-back/internal/logger"
 	"gitlab-orchestrator-back/internal/middleware"
+	"gitlab-orchestrator-back/internal/rbac"
+	"gitlab-orchestrator-back/internal/registry"
 	"gitlab-orchestrator-back/internal/routes"
 	"gitlab-orchestrator-back/internal/scheduler"
 
@@ -24,6 +26,10 @@ func main() {
 		logger.FatalfWithCaller("Failed to load configuration: %v", err)
 	}
 
+	if err := rbac.Validate(); err != nil {
+		logger.FatalfWithCaller("Invalid role permissions: %v", err)
+	}
+
 	// Print configuration
 	config.Config.Print()
 
@@ -35,6 +41,12 @@ func main() {
 	// Create GitLab client using configuration
 	gitClient := gitlab.NewClient()
 
+	// Create image registry client for product version validation
+	tagLister, err := registry.New()
+	if err != nil {
+		logger.FatalfWithCaller("Failed to initialize image registry: %v", err)
+	}
+
 	// Start scheduler with configured client
 	logger.InfoWithCaller("Starting task scheduler")
 	scheduler.StartRunnerScheduler(gitClient)
@@ -46,12 +58,20 @@ func main() {
 	// Middleware
 	e.Use(echoMiddleware.Logger())
 	e.Use(echoMiddleware.Recover())
-	e.Use(echoMiddleware.CORS())
+	if len(config.Config.CORSAllowedOrigins) > 0 {
+		e.Use(echoMiddleware.CORSWithConfig(echoMiddleware.CORSConfig{
+			AllowOrigins: config.Config.CORSAllowedOrigins,
+			AllowHeaders: []string{echo.HeaderAuthorization, echo.HeaderContentType, echo.HeaderXRequestID},
+		}))
+	}
 	e.Use(middleware.RequestIDMiddleware)
+	if !config.Config.AuthEnabled {
+		logger.WarnWithCaller("Authentication is disabled, API is open to anyone on the network")
+	}
 	logger.InfoWithCaller("Middleware configured")
 
 	// Setup routes
-	routes.SetupRoutes(e, &handlers.Handler{})
+	routes.SetupRoutes(e, &handlers.Handler{Gitlab: gitClient, Registry: tagLister})
 	logger.InfoWithCaller("API routes configured")
 
 	// Start server
@@ -0,0 +1,164 @@
+package admission
+
+import (
+	"fmt"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/quota"
+	"gitlab-orchestrator-back/internal/rbac"
+	"net/http"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+const StatusAwaitingApproval = "awaiting_approval"
+
+// Check проверяет регион, срок жизни и квоту нового стенда, задает срок жизни по квоте и сообщает,
+// нужно ли согласование. При отказе возвращает HTTP-статус и причину. Через Check проходят стенды,
+// которые создает пользователь и расписание
+func Check(request *internal.StandRequest) (needApproval bool, status int, message string) {
+	if status, message := validateRegion(request.Region); status != http.StatusOK {
+		return false, status, message
+	}
+	if request.TTLHours < 0 {
+		return false, http.StatusBadRequest, "ttlHours must not be negative"
+	}
+	// Квота проверяется и для готовых стендов из пула
+	if status, message := checkQuota(request); status != http.StatusOK {
+		return false, status, message
+	}
+	return RequiresApproval(*request), http.StatusOK, ""
+}
+
+// Create сохраняет стенд в очереди на создание, а если нужно согласование — на согласовании
+// с запросом пользователям с правом stand:approve
+func Create(request internal.StandRequest, needApproval bool, tx *gorm.DB) (models.Stand, error) {
+	standModel, err := internal.PopulateStand(request)
+	if err != nil {
+		return models.Stand{}, err
+	}
+	if needApproval {
+		standModel.Status = StatusAwaitingApproval
+	}
+
+	if err := database.CreateStand(standModel, tx); err != nil {
+		return models.Stand{}, err
+	}
+	if needApproval {
+		if err := notifyApprovers(standModel, tx); err != nil {
+			return models.Stand{}, err
+		}
+	}
+	return standModel, nil
+}
+
+// RequiresApproval определяет, нужно ли согласование администратора перед созданием стенда
+func RequiresApproval(request internal.StandRequest) bool {
+	if !config.Config.ApprovalEnabled {
+		return false
+	}
+	if config.Config.ApprovalMaxProducts > 0 && len(request.Products) > config.Config.ApprovalMaxProducts {
+		return true
+	}
+
+	user, err := database.GetUserByID(uint(request.UserID))
+	if err != nil {
+		return true
+	}
+	for _, role := range config.Config.ApprovalExemptRoles {
+		if user.HasRole(role) {
+			return false
+		}
+	}
+	return true
+}
+
+// validateRegion проверяет, что в регионе есть включенный пул ресурсов
+func validateRegion(region string) (int, string) {
+	if region == "" {
+		return http.StatusOK, ""
+	}
+	regions, err := database.GetRegions(database.DB)
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	if internal.ContainsString(regions, region) {
+		return http.StatusOK, ""
+	}
+	return http.StatusBadRequest, "unknown region " + region
+}
+
+// checkQuota проверяет, помещается ли стенд в квоту автора или команды, и задает срок жизни стенда
+func checkQuota(request *internal.StandRequest) (int, string) {
+	user, err := database.GetUserByID(uint(request.UserID))
+	if err != nil {
+		return http.StatusForbidden, "user not found"
+	}
+
+	var status quota.Status
+	if request.TeamID != 0 {
+		// Стенд команды учитывается только в квоте команды
+		team, err := database.GetTeamByID(request.TeamID, database.DB)
+		if err != nil {
+			return http.StatusBadRequest, "team not found"
+		}
+		if _, member := team.Member(uint(user.ID)); !member && !rbac.Can(*user, rbac.TeamsManage) {
+			return http.StatusForbidden, "only team members can create stands of team " + team.Name
+		}
+		status, err = quota.GetTeamStatus(*team, database.DB)
+		if err != nil {
+			return http.StatusInternalServerError, err.Error()
+		}
+	} else if status, err = quota.GetStatus(*user, database.DB); err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+
+	ttlHours, err := status.Check(request.Type, request.TTLHours)
+	if err != nil {
+		logger.WarnfWithCaller("Стенд %s пользователя %d не помещается в квоту: %v", request.NameStand, request.UserID, err)
+		if status.Team != "" {
+			return http.StatusForbidden, fmt.Sprintf("team %s: %v", status.Team, err)
+		}
+		return http.StatusForbidden, err.Error()
+	}
+	request.TTLHours = ttlHours
+	return http.StatusOK, ""
+}
+
+// notifyApprovers отправляет запрос на согласование стенда пользователям с правом stand:approve
+func notifyApprovers(stand models.Stand, tx *gorm.DB) error {
+	admins, err := database.GetUsersWithPermission(rbac.StandApprove, tx)
+	if err != nil {
+		return err
+	}
+	if len(admins) == 0 {
+		return fmt.Errorf("нет администраторов для согласования стенда")
+	}
+
+	products, err := database.GetProductsFromStand(stand.Name, tx)
+	if err != nil {
+		return err
+	}
+	requester := fmt.Sprintf("%d", stand.UserID)
+	if user, err := database.GetUserByID(stand.UserID); err == nil {
+		requester = fmt.Sprintf("%s (%d)", user.Name, user.ID)
+	}
+
+	for _, admin := range admins {
+		if err := database.CreateNotify(models.StepState{
+			StandName: stand.Name,
+			StepName:  "Согласование",
+			UserID:    uint(admin.ID),
+			Status:    StatusAwaitingApproval,
+			Kind:      models.NotifyKindApproval,
+			Message:   fmt.Sprintf("Автор: %s\nВетка: %s\nПродукты: %s", requester, stand.Ref, strings.Join(products, ", ")),
+		}, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,110 @@
+package auth
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal/config"
+	"strings"
+	"time"
+)
+
+const (
+	KeyPrefix     = "gko_" // Начало API-ключа, по нему ключ отличается от токена
+	keyPrefixSize = 12     // Длина начала ключа, которое хранится для опознания
+	ContextKey    = "auth" // Ключ Principal в контексте запроса
+)
+
+var (
+	ErrInvalidToken = errors.New("invalid token")
+	ErrTokenExpired = errors.New("token expired")
+)
+
+// Principal субъект запроса: сервис с API-ключом или пользователь с токеном
+type Principal struct {
+	KeyID   uint   // API-ключ, которым выполнен запрос или выпущен токен, 0 — ключ из конфигурации
+	KeyName string // Имя API-ключа
+	UserID  int64  // Пользователь токена или владелец ключа, 0 — ключ бота, который действует от имени любого пользователя
+}
+
+// Service проверяет, что запрос выполнен ключом бота, который может действовать от имени пользователя из запроса
+func (p Principal) Service() bool {
+	return p.UserID == 0
+}
+
+// tokenClaims содержимое подписанного токена
+type tokenClaims struct {
+	UserID    int64 `json:"uid"`
+	KeyID     uint  `json:"kid"`
+	ExpiresAt int64 `json:"exp"`
+}
+
+// GenerateKey создает новый API-ключ и возвращает его, начало ключа для опознания и хеш для хранения
+func GenerateKey() (key string, prefix string, hash string, err error) {
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", "", fmt.Errorf("failed to generate key: %v", err)
+	}
+	key = KeyPrefix + hex.EncodeToString(buf)
+	return key, key[:keyPrefixSize], HashKey(key), nil
+}
+
+// HashKey возвращает SHA-256 хеш API-ключа. Ключи случайные и длинные, поэтому соль не нужна
+func HashKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// IsKey проверяет, что credential — API-ключ, а не токен
+func IsKey(credential string) bool {
+	return strings.HasPrefix(credential, KeyPrefix)
+}
+
+// BootstrapKey проверяет, что key совпадает с ключом из AUTH_BOOTSTRAP_KEY
+func BootstrapKey(key string) bool {
+	bootstrap := config.Config.AuthBootstrapKey
+	return bootstrap != "" && subtle.ConstantTimeCompare([]byte(key), []byte(bootstrap)) == 1
+}
+
+// IssueToken выпускает токен пользователя userID, подписанный AUTH_TOKEN_SECRET и действующий AUTH_TOKEN_TTL
+func IssueToken(userID int64, keyID uint, now time.Time) (string, time.Time, error) {
+	expiresAt := now.Add(config.Config.AuthTokenTTL)
+	payload, err := json.Marshal(tokenClaims{UserID: userID, KeyID: keyID, ExpiresAt: expiresAt.Unix()})
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + sign(encoded), expiresAt, nil
+}
+
+// ParseToken проверяет подпись и срок действия токена и возвращает субъект запроса
+func ParseToken(token string, now time.Time) (Principal, error) {
+	encoded, signature, found := strings.Cut(token, ".")
+	if !found || !hmac.Equal([]byte(signature), []byte(sign(encoded))) {
+		return Principal{}, ErrInvalidToken
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return Principal{}, ErrInvalidToken
+	}
+	var claims tokenClaims
+	if err := json.Unmarshal(payload, &claims); err != nil || claims.UserID == 0 {
+		return Principal{}, ErrInvalidToken
+	}
+	if now.Unix() >= claims.ExpiresAt {
+		return Principal{}, ErrTokenExpired
+	}
+	return Principal{KeyID: claims.KeyID, UserID: claims.UserID}, nil
+}
+
+func sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(config.Config.AuthTokenSecret))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
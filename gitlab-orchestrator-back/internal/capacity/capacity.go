@@ -0,0 +1,105 @@
+package capacity
+
+import (
+	"fmt"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/models"
+
+	"gorm.io/gorm"
+)
+
+// DefaultCostType тип, стоимость которого используется для типов стендов без своей стоимости
+const DefaultCostType = "default"
+
+// Cost возвращает ресурсы, которые занимает стенд типа standType. Если для типа и для default
+// стоимость не задана, стенд занимает одну виртуальную машину
+func Cost(standType string) config.StandCost {
+	if cost, exist := config.Config.StandCosts[standType]; exist {
+		return cost
+	}
+	if cost, exist := config.Config.StandCosts[DefaultCostType]; exist {
+		return cost
+	}
+	return config.StandCost{VMs: 1}
+}
+
+// PoolUsage занятость пула ресурсов размещенными в нем стендами
+type PoolUsage struct {
+	Pool   models.ResourcePool `json:"pool"`
+	Used   config.StandCost    `json:"used"`
+	Stands []string            `json:"stands"`
+}
+
+// Add учитывает стенд в занятости пула
+func (u *PoolUsage) Add(stand models.Stand) {
+	cost := Cost(stand.Type)
+	u.Used.VMs += cost.VMs
+	u.Used.VCPU += cost.VCPU
+	u.Used.RAMGB += cost.RAMGB
+	u.Stands = append(u.Stands, stand.Name)
+}
+
+// Fits проверяет, хватит ли в пуле ресурсов еще на cost
+func (u PoolUsage) Fits(cost config.StandCost) bool {
+	return fits(u.Pool.MaxVMs, u.Used.VMs, cost.VMs) &&
+		fits(u.Pool.MaxVCPU, u.Used.VCPU, cost.VCPU) &&
+		fits(u.Pool.MaxRAMGB, u.Used.RAMGB, cost.RAMGB)
+}
+
+func fits(limit int, used int, need int) bool {
+	return limit == 0 || used+need <= limit
+}
+
+// Usage считает занятость всех пулов по размещенным в них стендам
+func Usage(tx *gorm.DB) ([]PoolUsage, error) {
+	pools, err := database.GetResourcePools(tx)
+	if err != nil {
+		return nil, err
+	}
+	stands, err := database.GetPlacedStands(tx)
+	if err != nil {
+		return nil, err
+	}
+
+	usage := make([]PoolUsage, len(pools))
+	index := make(map[string]int)
+	for i, pool := range pools {
+		usage[i] = PoolUsage{Pool: pool, Stands: []string{}}
+		index[pool.Name] = i
+	}
+	for _, stand := range stands {
+		if i, exist := index[stand.Pool]; exist {
+			usage[i].Add(stand)
+		}
+	}
+	return usage, nil
+}
+
+// Place выбирает для стенда включенный пул его региона, в котором хватает ресурсов.
+// Из подходящих пулов выбирается пул с наименьшим числом занятых виртуальных машин.
+// Если пула нет, возвращает -1 и причину, по которой стенд остается в очереди
+func Place(stand models.Stand, usage []PoolUsage) (int, string) {
+	cost := Cost(stand.Type)
+	best := -1
+	inRegion := false
+	for i, pool := range usage {
+		if pool.Pool.Disabled || (stand.Region != "" && pool.Pool.Region != stand.Region) {
+			continue
+		}
+		inRegion = true
+		if pool.Fits(cost) && (best < 0 || pool.Used.VMs < usage[best].Used.VMs) {
+			best = i
+		}
+	}
+	if best >= 0 {
+		return best, ""
+	}
+	if !inRegion && stand.Region != "" {
+		return -1, fmt.Sprintf("нет включенных пулов ресурсов в регионе %s", stand.Region)
+	}
+	if !inRegion {
+		return -1, "нет включенных пулов ресурсов"
+	}
+	return -1, fmt.Sprintf("недостаточно ресурсов в пулах: нужно ВМ %d, vCPU %d, RAM %d ГБ", cost.VMs, cost.VCPU, cost.RAMGB)
+}
@@ -5,7 +5,10 @@ import (
 	"github.com/joho/godotenv"
 	"gitlab-orchestrator-back/internal/logger"
 	"os"
+	"regexp"
 	"strconv"
+	"strings"
+	"time"
 )
 
 // Configuration holds all application config values
@@ -26,10 +29,85 @@ type Configuration struct {
 	GitlabProjectID            int    `env:"GITLAB_PROJECT_ID"`
 	GitlabTriggerPipelineToken string `env:"GITLAB_TRIGGER_PIPELINE_TOKEN"`
 
+	// Scheduler settings
+	ProvisionMaxAttempts int           `env:"PROVISION_MAX_ATTEMPTS" default:"3"`
+	ReconcileInterval    time.Duration `env:"RECONCILE_INTERVAL" default:"5m"`
+
+	// CI lint settings
+	CILintEnabled     bool `env:"CI_LINT_ENABLED" default:"true"`
+	CILintCheckStages bool `env:"CI_LINT_CHECK_STAGES" default:"false"`
+
+	// Orphan scanner settings
+	StandNamePattern     string        `env:"STAND_NAME_PATTERN" default:"^[a-z]+-[0-9]+$"`
+	OrphanScanInterval   time.Duration `env:"ORPHAN_SCAN_INTERVAL" default:"1h"`
+	OrphanCleanupEnabled bool          `env:"ORPHAN_CLEANUP_ENABLED" default:"false"`
+	OrphanGracePeriod    time.Duration `env:"ORPHAN_GRACE_PERIOD" default:"24h"`
+
+	// Approval settings
+	ApprovalEnabled     bool     `env:"APPROVAL_ENABLED" default:"false"`
+	ApprovalExemptRoles []string `env:"APPROVAL_EXEMPT_ROLES" default:"admin"`
+	ApprovalMaxProducts int      `env:"APPROVAL_MAX_PRODUCTS" default:"0"`
+
+	// Manual jobs that need a human decision, stage -> job name patterns
+	JobApprovalRules map[string][]string `env:"JOB_APPROVAL_RULES"`
+
+	// Terraform plan settings
+	TerraformPlanJob      string `env:"TERRAFORM_PLAN_JOB" default:"*plan*"`
+	TerraformPlanArtifact string `env:"TERRAFORM_PLAN_ARTIFACT"`
+
+	// Image registry settings for product version validation
+	RegistryType          string `env:"REGISTRY_TYPE" default:"none"`
+	RegistryTagsFile      string `env:"REGISTRY_TAGS_FILE"`
+	RegistryURL           string `env:"REGISTRY_URL"`
+	RegistryImageTemplate string `env:"REGISTRY_IMAGE_TEMPLATE" default:"%s"`
+	RegistryToken         string `env:"REGISTRY_TOKEN"`
+
+	// Fleet operation settings
+	FleetBatchSize     int           `env:"FLEET_BATCH_SIZE" default:"5"`
+	FleetCheckInterval time.Duration `env:"FLEET_CHECK_INTERVAL" default:"30s"`
+
+	// Capacity settings: stand type -> resources it takes in a pool
+	StandCosts map[string]StandCost `env:"STAND_COSTS"`
+
+	// Quota settings: role -> limits, "default" for users without a configured role
+	Quotas map[string]Quota `env:"QUOTAS"`
+
+	// Warm pool settings: stand type -> number of pre-provisioned stands
+	WarmPool        map[string]int `env:"WARM_POOL"`
+	WarmPoolOwnerID uint           `env:"WARM_POOL_OWNER_ID"`
+	WarmPoolRef     string         `env:"WARM_POOL_REF" default:"master"`
+	WarmPoolPrefix  string         `env:"WARM_POOL_PREFIX" default:"warm"`
+
+	// Authorization settings: role -> permissions, overrides built-in roles
+	RolePermissions       map[string][]string `env:"ROLE_PERMISSIONS"`
+	AccessRequestCooldown time.Duration       `env:"ACCESS_REQUEST_COOLDOWN" default:"24h"`
+
+	// Authentication settings
+	AuthEnabled        bool          `env:"AUTH_ENABLED" default:"true"`
+	AuthTokenSecret    string        `env:"AUTH_TOKEN_SECRET"`
+	AuthTokenTTL       time.Duration `env:"AUTH_TOKEN_TTL" default:"15m"`
+	AuthBootstrapKey   string        `env:"AUTH_BOOTSTRAP_KEY"`
+	CORSAllowedOrigins []string      `env:"CORS_ALLOWED_ORIGINS"`
+
 	// Logger settings
 	LogLevel string `env:"LOG_LEVEL" default:"info"`
 }
 
+// StandCost ресурсы, которые стенд занимает в пуле
+type StandCost struct {
+	VMs   int `json:"vms"`
+	VCPU  int `json:"vcpu"`
+	RAMGB int `json:"ram_gb"`
+}
+
+// Quota лимиты пользователя: активные стенды, суммарное число ВМ и срок жизни стенда в часах.
+// Нулевой лимит — без ограничения
+type Quota struct {
+	Stands   int `json:"stands"`
+	VMs      int `json:"vms"`
+	TTLHours int `json:"ttl_hours"`
+}
+
 // Global configuration instance
 var Config = &Configuration{}
 
@@ -69,6 +147,154 @@ func (c *Configuration) Load() error {
 
 	c.GitlabTriggerPipelineToken = os.Getenv("GITLAB_TRIGGER_PIPELINE_TOKEN")
 
+	// Load scheduler settings
+	provisionMaxAttempts, err := getEnvIntWithDefault("PROVISION_MAX_ATTEMPTS", 3)
+	if err != nil {
+		return err
+	}
+	c.ProvisionMaxAttempts = provisionMaxAttempts
+
+	reconcileInterval, err := getEnvDurationWithDefault("RECONCILE_INTERVAL", 5*time.Minute)
+	if err != nil {
+		return err
+	}
+	c.ReconcileInterval = reconcileInterval
+
+	// Load CI lint settings
+	ciLintEnabled, err := getEnvBoolWithDefault("CI_LINT_ENABLED", true)
+	if err != nil {
+		return err
+	}
+	c.CILintEnabled = ciLintEnabled
+
+	ciLintCheckStages, err := getEnvBoolWithDefault("CI_LINT_CHECK_STAGES", false)
+	if err != nil {
+		return err
+	}
+	c.CILintCheckStages = ciLintCheckStages
+
+	// Load orphan scanner settings
+	c.StandNamePattern = getEnvWithDefault("STAND_NAME_PATTERN", "^[a-z]+-[0-9]+$")
+
+	orphanScanInterval, err := getEnvDurationWithDefault("ORPHAN_SCAN_INTERVAL", time.Hour)
+	if err != nil {
+		return err
+	}
+	c.OrphanScanInterval = orphanScanInterval
+
+	orphanCleanupEnabled, err := getEnvBoolWithDefault("ORPHAN_CLEANUP_ENABLED", false)
+	if err != nil {
+		return err
+	}
+	c.OrphanCleanupEnabled = orphanCleanupEnabled
+
+	orphanGracePeriod, err := getEnvDurationWithDefault("ORPHAN_GRACE_PERIOD", 24*time.Hour)
+	if err != nil {
+		return err
+	}
+	c.OrphanGracePeriod = orphanGracePeriod
+
+	// Load approval settings
+	approvalEnabled, err := getEnvBoolWithDefault("APPROVAL_ENABLED", false)
+	if err != nil {
+		return err
+	}
+	c.ApprovalEnabled = approvalEnabled
+	c.ApprovalExemptRoles = getEnvListWithDefault("APPROVAL_EXEMPT_ROLES", []string{"admin"})
+
+	approvalMaxProducts, err := getEnvIntWithDefault("APPROVAL_MAX_PRODUCTS", 0)
+	if err != nil {
+		return err
+	}
+	c.ApprovalMaxProducts = approvalMaxProducts
+
+	jobApprovalRules, err := parseJobApprovalRules(os.Getenv("JOB_APPROVAL_RULES"))
+	if err != nil {
+		return err
+	}
+	c.JobApprovalRules = jobApprovalRules
+
+	c.TerraformPlanJob = getEnvWithDefault("TERRAFORM_PLAN_JOB", "*plan*")
+	c.TerraformPlanArtifact = os.Getenv("TERRAFORM_PLAN_ARTIFACT")
+
+	// Load image registry settings
+	c.RegistryType = getEnvWithDefault("REGISTRY_TYPE", "none")
+	c.RegistryTagsFile = os.Getenv("REGISTRY_TAGS_FILE")
+	c.RegistryURL = os.Getenv("REGISTRY_URL")
+	c.RegistryImageTemplate = getEnvWithDefault("REGISTRY_IMAGE_TEMPLATE", "%s")
+	c.RegistryToken = os.Getenv("REGISTRY_TOKEN")
+
+	// Load fleet operation settings
+	fleetBatchSize, err := getEnvIntWithDefault("FLEET_BATCH_SIZE", 5)
+	if err != nil {
+		return err
+	}
+	c.FleetBatchSize = fleetBatchSize
+
+	fleetCheckInterval, err := getEnvDurationWithDefault("FLEET_CHECK_INTERVAL", 30*time.Second)
+	if err != nil {
+		return err
+	}
+	c.FleetCheckInterval = fleetCheckInterval
+
+	// Load capacity settings
+	standCosts, err := parseStandCosts(os.Getenv("STAND_COSTS"))
+	if err != nil {
+		return err
+	}
+	c.StandCosts = standCosts
+
+	// Load quota settings
+	quotas, err := parseQuotas(os.Getenv("QUOTAS"))
+	if err != nil {
+		return err
+	}
+	c.Quotas = quotas
+
+	// Load warm pool settings
+	warmPool, err := parseWarmPool(os.Getenv("WARM_POOL"))
+	if err != nil {
+		return err
+	}
+	c.WarmPool = warmPool
+
+	warmPoolOwnerID, err := getEnvIntWithDefault("WARM_POOL_OWNER_ID", 0)
+	if err != nil {
+		return err
+	}
+	c.WarmPoolOwnerID = uint(warmPoolOwnerID)
+	c.WarmPoolRef = getEnvWithDefault("WARM_POOL_REF", "master")
+	c.WarmPoolPrefix = getEnvWithDefault("WARM_POOL_PREFIX", "warm")
+
+	// Load authorization settings
+	rolePermissions, err := parseRolePermissions(os.Getenv("ROLE_PERMISSIONS"))
+	if err != nil {
+		return err
+	}
+	c.RolePermissions = rolePermissions
+
+	accessRequestCooldown, err := getEnvDurationWithDefault("ACCESS_REQUEST_COOLDOWN", 24*time.Hour)
+	if err != nil {
+		return err
+	}
+	c.AccessRequestCooldown = accessRequestCooldown
+
+	// Load authentication settings
+	authEnabled, err := getEnvBoolWithDefault("AUTH_ENABLED", true)
+	if err != nil {
+		return err
+	}
+	c.AuthEnabled = authEnabled
+	c.AuthTokenSecret = os.Getenv("AUTH_TOKEN_SECRET")
+
+	authTokenTTL, err := getEnvDurationWithDefault("AUTH_TOKEN_TTL", 15*time.Minute)
+	if err != nil {
+		return err
+	}
+	c.AuthTokenTTL = authTokenTTL
+	c.AuthBootstrapKey = os.Getenv("AUTH_BOOTSTRAP_KEY")
+	c.CORSAllowedOrigins = getEnvListWithDefault("CORS_ALLOWED_ORIGINS", nil)
+
 	// Load logger settings
 	c.LogLevel = getEnvWithDefault("LOG_LEVEL", "info")
 
@@ -100,6 +326,64 @@ func (c *Configuration) validate() error {
 		return fmt.Errorf("database configuration is incomplete")
 	}
 
+	if c.ProvisionMaxAttempts < 1 {
+		return fmt.Errorf("PROVISION_MAX_ATTEMPTS must be greater than zero")
+	}
+
+	if c.ReconcileInterval <= 0 {
+		return fmt.Errorf("RECONCILE_INTERVAL must be positive")
+	}
+
+	if _, err := regexp.Compile(c.StandNamePattern); err != nil {
+		return fmt.Errorf("invalid STAND_NAME_PATTERN: %v", err)
+	}
+
+	if c.OrphanScanInterval <= 0 {
+		return fmt.Errorf("ORPHAN_SCAN_INTERVAL must be positive")
+	}
+
+	if c.FleetBatchSize <= 0 {
+		return fmt.Errorf("FLEET_BATCH_SIZE must be greater than zero")
+	}
+
+	if c.FleetCheckInterval <= 0 {
+		return fmt.Errorf("FLEET_CHECK_INTERVAL must be positive")
+	}
+
+	if len(c.WarmPool) > 0 && c.WarmPoolOwnerID == 0 {
+		return fmt.Errorf("WARM_POOL_OWNER_ID is required when WARM_POOL is set")
+	}
+
+	if !regexp.MustCompile(`^[a-z]+$`).MatchString(c.WarmPoolPrefix) {
+		return fmt.Errorf("invalid WARM_POOL_PREFIX %q: expected lowercase letters", c.WarmPoolPrefix)
+	}
+
+	if c.AuthEnabled {
+		if len(c.AuthTokenSecret) < 32 {
+			return fmt.Errorf("AUTH_TOKEN_SECRET of at least 32 characters is required when AUTH_ENABLED is set")
+		}
+		if c.AuthTokenTTL <= 0 {
+			return fmt.Errorf("AUTH_TOKEN_TTL must be positive")
+		}
+		if c.AuthBootstrapKey != "" && len(c.AuthBootstrapKey) < 32 {
+			return fmt.Errorf("AUTH_BOOTSTRAP_KEY must be at least 32 characters")
+		}
+	}
+
+	switch c.RegistryType {
+	case "none":
+	case "file":
+		if c.RegistryTagsFile == "" {
+			return fmt.Errorf("REGISTRY_TAGS_FILE is required for REGISTRY_TYPE=file")
+		}
+	case "docker":
+		if c.RegistryURL == "" {
+			return fmt.Errorf("REGISTRY_URL is required for REGISTRY_TYPE=docker")
+		}
+	default:
+		return fmt.Errorf("invalid REGISTRY_TYPE %q: expected none, file or docker", c.RegistryType)
+	}
+
 	return nil
 }
 
@@ -123,6 +407,25 @@ func (c *Configuration) Print() {
 	logger.InfofWithCaller("- Database: %s@%s:%s/%s", c.DBUser, c.DBHost, c.DBPort, c.DBName)
 	logger.InfofWithCaller("- GitLab API URL: %s", c.GitlabAPIURL)
 	logger.InfofWithCaller("- GitLab Project ID: %d", c.GitlabProjectID)
+	logger.InfofWithCaller("- Provision Max Attempts: %d", c.ProvisionMaxAttempts)
+	logger.InfofWithCaller("- Reconcile Interval: %s", c.ReconcileInterval)
+	logger.InfofWithCaller("- CI Lint: %v (check stages %v)", c.CILintEnabled, c.CILintCheckStages)
+	logger.InfofWithCaller("- Stand Name Pattern: %s", c.StandNamePattern)
+	logger.InfofWithCaller("- Orphan Scan Interval: %s", c.OrphanScanInterval)
+	logger.InfofWithCaller("- Orphan Cleanup: %v (grace period %s)", c.OrphanCleanupEnabled, c.OrphanGracePeriod)
+	logger.InfofWithCaller("- Approval: %v (exempt roles %v, max products %d)",
+		c.ApprovalEnabled, c.ApprovalExemptRoles, c.ApprovalMaxProducts)
+	logger.InfofWithCaller("- Job Approval Rules: %v", c.JobApprovalRules)
+	logger.InfofWithCaller("- Terraform Plan: job %s, artifact %q", c.TerraformPlanJob, c.TerraformPlanArtifact)
+	logger.InfofWithCaller("- Registry: %s (file %q, url %q, image %q)",
+		c.RegistryType, c.RegistryTagsFile, c.RegistryURL, c.RegistryImageTemplate)
+	logger.InfofWithCaller("- Fleet: batch size %d, check interval %s", c.FleetBatchSize, c.FleetCheckInterval)
+	logger.InfofWithCaller("- Stand Costs: %v", c.StandCosts)
+	logger.InfofWithCaller("- Quotas: %v", c.Quotas)
+	logger.InfofWithCaller("- Warm Pool: %v (owner %d, ref %s, prefix %s)",
+		c.WarmPool, c.WarmPoolOwnerID, c.WarmPoolRef, c.WarmPoolPrefix)
+	logger.InfofWithCaller("- Role Permissions: %v", c.RolePermissions)
+	logger.InfofWithCaller("- Auth: %v (token TTL %s, CORS origins %v)", c.AuthEnabled, c.AuthTokenTTL, c.CORSAllowedOrigins)
 	logger.InfofWithCaller("- Log Level: %s", c.LogLevel)
 
 	// Don't log sensitive information
@@ -137,6 +440,12 @@ func (c *Configuration) Print() {
 	} else {
 		logger.InfoWithCaller("- GitLab Pipeline Token: [NOT CONFIGURED]")
 	}
+
+	if c.AuthBootstrapKey != "" {
+		logger.InfoWithCaller("- Auth Bootstrap Key: [CONFIGURED]")
+	} else {
+		logger.InfoWithCaller("- Auth Bootstrap Key: [NOT CONFIGURED]")
+	}
 }
 
 // Helper function to get environment variable with a default value
@@ -147,3 +456,166 @@ func getEnvWithDefault(key, defaultValue string) string {
 	}
 	return value
 }
+
+// Helper function to get integer environment variable with a default value
+func getEnvIntWithDefault(key string, defaultValue int) (int, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return parsed, nil
+}
+
+// Helper function to get duration environment variable with a default value
+func getEnvDurationWithDefault(key string, defaultValue time.Duration) (time.Duration, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return parsed, nil
+}
+
+// Helper function to get boolean environment variable with a default value
+func getEnvBoolWithDefault(key string, defaultValue bool) (bool, error) {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue, nil
+	}
+	parsed, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("invalid %s: %v", key, err)
+	}
+	return parsed, nil
+}
+
+// Helper function to get comma-separated environment variable with a default value
+func getEnvListWithDefault(key string, defaultValue []string) []string {
+	value := os.Getenv(key)
+	if value == "" {
+		return defaultValue
+	}
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
+}
+
+// parseJobApprovalRules разбирает правила вида "terraform:*apply*,helm:deploy-prod"
+func parseJobApprovalRules(value string) (map[string][]string, error) {
+	rules := make(map[string][]string)
+	for _, rule := range strings.Split(value, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+		stage, pattern, found := strings.Cut(rule, ":")
+		if !found || stage == "" || pattern == "" {
+			return nil, fmt.Errorf("invalid JOB_APPROVAL_RULES entry %q, expected stage:pattern", rule)
+		}
+		rules[stage] = append(rules[stage], pattern)
+	}
+	return rules, nil
+}
+
+// parseStandCosts разбирает стоимость стендов в формате "type:vms/vcpu/ramGB,..."
+func parseStandCosts(value string) (map[string]StandCost, error) {
+	costs := make(map[string]StandCost)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		standType, resources, found := strings.Cut(entry, ":")
+		parts := strings.Split(resources, "/")
+		if !found || standType == "" || len(parts) != 3 {
+			return nil, fmt.Errorf("invalid STAND_COSTS entry %q, expected type:vms/vcpu/ramGB", entry)
+		}
+		var values [3]int
+		for i, part := range parts {
+			number, err := strconv.Atoi(part)
+			if err != nil || number < 0 {
+				return nil, fmt.Errorf("invalid STAND_COSTS entry %q, expected type:vms/vcpu/ramGB", entry)
+			}
+			values[i] = number
+		}
+		costs[standType] = StandCost{VMs: values[0], VCPU: values[1], RAMGB: values[2]}
+	}
+	return costs, nil
+}
+
+// parseWarmPool разбирает размер пула готовых стендов в формате "type:count,..."
+func parseWarmPool(value string) (map[string]int, error) {
+	pool := make(map[string]int)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		standType, countValue, found := strings.Cut(entry, ":")
+		count, err := strconv.Atoi(countValue)
+		if !found || standType == "" || err != nil || count < 0 {
+			return nil, fmt.Errorf("invalid WARM_POOL entry %q, expected type:count", entry)
+		}
+		pool[standType] = count
+	}
+	return pool, nil
+}
+
+// parseQuotas разбирает квоты ролей в формате "role:stands/vms/ttlHours,..."
+func parseQuotas(value string) (map[string]Quota, error) {
+	quotas := make(map[string]Quota)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		role, limits, found := strings.Cut(entry, ":")
+		parts := strings.Split(limits, "/")
+		if !found || role == "" || len(parts) != 3 {
+			return nil, fmt.Errorf("invalid QUOTAS entry %q, expected role:stands/vms/ttlHours", entry)
+		}
+		var values [3]int
+		for i, part := range parts {
+			number, err := strconv.Atoi(part)
+			if err != nil || number < 0 {
+				return nil, fmt.Errorf("invalid QUOTAS entry %q, expected role:stands/vms/ttlHours", entry)
+			}
+			values[i] = number
+		}
+		quotas[role] = Quota{Stands: values[0], VMs: values[1], TTLHours: values[2]}
+	}
+	return quotas, nil
+}
+
+// parseRolePermissions разбирает права ролей в формате "role=permission|permission,..."
+func parseRolePermissions(value string) (map[string][]string, error) {
+	roles := make(map[string][]string)
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		role, permissions, found := strings.Cut(entry, "=")
+		if !found || role == "" {
+			return nil, fmt.Errorf("invalid ROLE_PERMISSIONS entry %q, expected role=permission|permission", entry)
+		}
+		roles[role] = []string{}
+		for _, permission := range strings.Split(permissions, "|") {
+			if permission = strings.TrimSpace(permission); permission != "" {
+				roles[role] = append(roles[role], permission)
+			}
+		}
+	}
+	return roles, nil
+}
@@ -0,0 +1,156 @@
+package cron
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule разобранное cron-выражение из пяти полей: минуты, часы, день месяца, месяц, день недели
+type Schedule struct {
+	minute   map[int]bool
+	hour     map[int]bool
+	dom      map[int]bool
+	month    map[int]bool
+	dow      map[int]bool
+	domStar  bool
+	dowStar  bool
+	location *time.Location
+}
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+// Parse разбирает cron-выражение для часового пояса timeZone (пустой пояс — UTC)
+func Parse(expr string, timeZone string) (*Schedule, error) {
+	location := time.UTC
+	if timeZone != "" {
+		loc, err := time.LoadLocation(timeZone)
+		if err != nil {
+			return nil, fmt.Errorf("неизвестный часовой пояс %q: %v", timeZone, err)
+		}
+		location = loc
+	}
+
+	expr = strings.TrimSpace(expr)
+	if macro, ok := macros[expr]; ok {
+		expr = macro
+	}
+
+	fields := strings.Fields(expr)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron-выражение %q должно содержать 5 полей", expr)
+	}
+
+	schedule := &Schedule{
+		location: location,
+		domStar:  fields[2] == "*",
+		dowStar:  fields[4] == "*",
+	}
+	var err error
+	if schedule.minute, err = parseField(fields[0], 0, 59); err != nil {
+		return nil, fmt.Errorf("минуты: %v", err)
+	}
+	if schedule.hour, err = parseField(fields[1], 0, 23); err != nil {
+		return nil, fmt.Errorf("часы: %v", err)
+	}
+	if schedule.dom, err = parseField(fields[2], 1, 31); err != nil {
+		return nil, fmt.Errorf("день месяца: %v", err)
+	}
+	if schedule.month, err = parseField(fields[3], 1, 12); err != nil {
+		return nil, fmt.Errorf("месяц: %v", err)
+	}
+	if schedule.dow, err = parseField(fields[4], 0, 7); err != nil {
+		return nil, fmt.Errorf("день недели: %v", err)
+	}
+	// 7 и 0 — воскресенье
+	if schedule.dow[7] {
+		schedule.dow[0] = true
+	}
+	return schedule, nil
+}
+
+// parseField разбирает поле вида "*", "*/15", "1-5", "1-10/2" или их список через запятую
+func parseField(field string, min, max int) (map[int]bool, error) {
+	values := make(map[int]bool)
+	for _, part := range strings.Split(field, ",") {
+		rangePart, step := part, 1
+		if before, after, found := strings.Cut(part, "/"); found {
+			parsed, err := strconv.Atoi(after)
+			if err != nil || parsed <= 0 {
+				return nil, fmt.Errorf("некорректный шаг %q", after)
+			}
+			rangePart, step = before, parsed
+		}
+
+		low, high := min, max
+		if rangePart != "*" {
+			from, to, isRange := strings.Cut(rangePart, "-")
+			var err error
+			if low, err = strconv.Atoi(from); err != nil {
+				return nil, fmt.Errorf("некорректное значение %q", from)
+			}
+			high = low
+			if isRange {
+				if high, err = strconv.Atoi(to); err != nil {
+					return nil, fmt.Errorf("некорректное значение %q", to)
+				}
+			} else if step > 1 {
+				high = max // "5/10" означает с 5 до конца диапазона
+			}
+		}
+		if low < min || high > max || low > high {
+			return nil, fmt.Errorf("значение %q вне диапазона %d-%d", part, min, max)
+		}
+
+		for value := low; value <= high; value += step {
+			values[value] = true
+		}
+	}
+	return values, nil
+}
+
+// dayMatches проверяет день по правилам cron: если заданы и день месяца, и день недели, достаточно любого из них
+func (s *Schedule) dayMatches(t time.Time) bool {
+	domMatch := s.dom[t.Day()]
+	dowMatch := s.dow[int(t.Weekday())]
+	if !s.domStar && !s.dowStar {
+		return domMatch || dowMatch
+	}
+	return domMatch && dowMatch
+}
+
+// Next возвращает ближайший момент запуска строго после after или нулевое время, если его нет
+func (s *Schedule) Next(after time.Time) time.Time {
+	t := after.In(s.location).Truncate(time.Minute).Add(time.Minute)
+	limit := t.AddDate(5, 0, 0)
+
+	for t.Before(limit) {
+		if !s.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, s.location)
+			continue
+		}
+		if !s.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
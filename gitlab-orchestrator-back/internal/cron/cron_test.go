@@ -0,0 +1,120 @@
+package cron
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		name     string
+		expr     string
+		timeZone string
+	}{
+		{name: "мало полей", expr: "* * * *"},
+		{name: "много полей", expr: "* * * * * *"},
+		{name: "неизвестный макрос", expr: "@sometimes"},
+		{name: "минута вне диапазона", expr: "60 * * * *"},
+		{name: "час вне диапазона", expr: "0 24 * * *"},
+		{name: "нулевой день месяца", expr: "0 0 0 * *"},
+		{name: "месяц вне диапазона", expr: "0 0 1 13 *"},
+		{name: "день недели вне диапазона", expr: "0 0 * * 8"},
+		{name: "нулевой шаг", expr: "*/0 * * * *"},
+		{name: "обратный диапазон", expr: "5-1 * * * *"},
+		{name: "не число", expr: "a * * * *"},
+		{name: "неизвестный часовой пояс", expr: "* * * * *", timeZone: "Mars/Olympus"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := Parse(tt.expr, tt.timeZone); err == nil {
+				t.Errorf("Parse(%q, %q) не вернул ошибку", tt.expr, tt.timeZone)
+			}
+		})
+	}
+}
+
+func TestNext(t *testing.T) {
+	// 1 января 2026 года — четверг
+	tests := []struct {
+		name     string
+		expr     string
+		timeZone string
+		after    time.Time
+		want     time.Time
+	}{
+		{
+			name:  "каждые 15 минут",
+			expr:  "*/15 * * * *",
+			after: time.Date(2026, 1, 1, 10, 7, 30, 0, time.UTC),
+			want:  time.Date(2026, 1, 1, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			name:  "строго после момента запуска",
+			expr:  "@daily",
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "шаг от значения до конца диапазона",
+			expr:  "5/10 * * * *",
+			after: time.Date(2026, 1, 1, 10, 6, 0, 0, time.UTC),
+			want:  time.Date(2026, 1, 1, 10, 15, 0, 0, time.UTC),
+		},
+		{
+			name:  "список значений",
+			expr:  "0 8,20 * * *",
+			after: time.Date(2026, 1, 1, 9, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 1, 1, 20, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "рабочие дни пропускают выходные",
+			expr:  "0 9 * * 1-5",
+			after: time.Date(2026, 1, 2, 10, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 1, 5, 9, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "7 — воскресенье",
+			expr:  "0 0 * * 7",
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 1, 4, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "день месяца или день недели",
+			expr:  "0 0 13 * 5",
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2026, 1, 2, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "переход через год",
+			expr:  "@yearly",
+			after: time.Date(2026, 6, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Date(2027, 1, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "часовой пояс расписания",
+			expr:     "0 9 * * *",
+			timeZone: "Europe/Moscow",
+			after:    time.Date(2026, 1, 1, 5, 0, 0, 0, time.UTC),
+			want:     time.Date(2026, 1, 1, 6, 0, 0, 0, time.UTC),
+		},
+		{
+			name:  "несуществующая дата",
+			expr:  "0 0 31 2 *",
+			after: time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC),
+			want:  time.Time{},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule, err := Parse(tt.expr, tt.timeZone)
+			if err != nil {
+				t.Fatalf("Parse(%q, %q): %v", tt.expr, tt.timeZone, err)
+			}
+			if got := schedule.Next(tt.after); !got.Equal(tt.want) {
+				t.Errorf("Next(%s) = %s, ожидалось %s", tt.after, got, tt.want)
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"gitlab-orchestrator-back/internal/config"
 	"gitlab-orchestrator-back/internal/logger"
 	"gitlab-orchestrator-back/internal/models"
+	"strings"
 
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
@@ -28,21 +29,104 @@ func InitDB() error {
 
 	logger.InfoWithCaller("Connected to database successfully")
 
+	if err := dedupePendingAccessRequests(DB); err != nil {
+		logger.ErrorfWithCaller("Failed to deduplicate pending access requests: %v", err)
+		return err
+	}
+
 	// Auto migrate the database schema
 	err = DB.AutoMigrate(
-		&models.User{},      // Correct struct for the user table
-		&models.Stand{},     // Struct for the stand table
-		&models.Step{},      // Struct for the step table
-		&models.Pipeline{},  // Struct for the pipeline table
-		&models.Job{},       // Struct for the job table
-		&models.Subos{},     // Struct for the subos table
-		&models.StepState{}, // Struct for the step state table
+		&models.User{},              // Correct struct for the user table
+		&models.Stand{},             // Struct for the stand table
+		&models.Step{},              // Struct for the step table
+		&models.Pipeline{},          // Struct for the pipeline table
+		&models.Job{},               // Struct for the job table
+		&models.Subos{},             // Struct for the subos table
+		&models.StepState{},         // Struct for the step state table
+		&models.ProvisionAction{},   // Struct for the provision action table
+		&models.OrphanResource{},    // Struct for the orphan resource table
+		&models.Schedule{},          // Struct for the schedule table
+		&models.DeploymentHistory{}, // Struct for the deployment history table
+		&models.FleetOperation{},    // Struct for the fleet operation table
+		&models.FleetTarget{},       // Struct for the fleet target table
+		&models.ResourcePool{},      // Struct for the resource pool table
+		&models.QuotaGrant{},        // Struct for the quota grant table
+		&models.Team{},              // Struct for the team table
+		&models.TeamMember{},        // Struct for the team member table
+		&models.StandCollaborator{}, // Struct for the stand collaborator table
+		&models.StandBooking{},      // Struct for the stand booking table
+		&models.APIKey{},            // Struct for the API key table
+		&models.Role{},              // Struct for the role table
+		&models.AccessRequest{},     // Struct for the access request table
 	)
 	if err != nil {
 		logger.ErrorfWithCaller("Failed to migrate database: %v", err)
 		return err
 	}
 
+	if err := migrateUserRoles(DB); err != nil {
+		logger.ErrorfWithCaller("Failed to migrate user roles: %v", err)
+		return err
+	}
+
 	logger.InfoWithCaller("Database migration completed successfully")
 	return nil
 }
+
+// migrateUserRoles переносит роли из прежней колонки users.role (роли через запятую) в таблицу user_roles
+// и удаляет колонку
+func migrateUserRoles(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&models.User{}, "role") {
+		return nil
+	}
+
+	var rows []struct {
+		ID   int64
+		Role string
+	}
+	if err := db.Table("users").Select("id, role").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range rows {
+			var roles []models.Role
+			for _, name := range strings.Split(row.Role, ",") {
+				if name = strings.TrimSpace(name); name == "" {
+					continue
+				}
+				role := models.Role{Name: name}
+				if err := tx.Where(role).FirstOrCreate(&role).Error; err != nil {
+					return err
+				}
+				roles = append(roles, role)
+			}
+			if len(roles) == 0 {
+				continue
+			}
+			if err := tx.Model(&models.User{ID: row.ID}).Association("Roles").Append(roles); err != nil {
+				return err
+			}
+		}
+		logger.InfofWithCaller("Роли %d пользователей перенесены в таблицу user_roles", len(rows))
+		return tx.Migrator().DropColumn(&models.User{}, "role")
+	})
+}
+
+// dedupePendingAccessRequests отклоняет лишние заявки на доступ на рассмотрении, оставляя последнюю заявку
+// пользователя, чтобы миграция смогла создать уникальный индекс idx_access_request_pending
+func dedupePendingAccessRequests(db *gorm.DB) error {
+	if !db.Migrator().HasTable(&models.AccessRequest{}) || db.Migrator().HasIndex(&models.AccessRequest{}, "idx_access_request_pending") {
+		return nil
+	}
+	result := db.Exec(`UPDATE access_requests SET status = ?, decision_reason = ? WHERE status = ? AND id NOT IN (
+		SELECT MAX(id) FROM access_requests WHERE status = ? GROUP BY user_id)`,
+		models.AccessRequestDenied, "повторная заявка", models.AccessRequestPending, models.AccessRequestPending)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		logger.InfofWithCaller("Отклонено %d повторных заявок на доступ", result.RowsAffected)
+	}
+	return nil
+}
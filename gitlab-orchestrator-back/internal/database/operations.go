@@ -7,11 +7,28 @@ import (
 	"gitlab-orchestrator-back/internal"
 	"gitlab-orchestrator-back/internal/logger"
 	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/rbac"
+	"strings"
 	"time"
 
 	"gorm.io/gorm"
 )
 
+// ErrStandNotFound возвращается, если стенда с указанным именем нет в БД
+var ErrStandNotFound = errors.New("stand not found")
+
+// ErrNoPreviousDeployment возвращается, если у продукта нет версии для отката
+var ErrNoPreviousDeployment = errors.New("no previous deployment to roll back to")
+
+// ErrWarmStandTaken возвращается, если готовый стенд из пула уже выдан другому запросу
+var ErrWarmStandTaken = errors.New("warm stand is already taken")
+
+// ErrAccessRequestDecided возвращается, если по заявке на доступ уже принято решение
+var ErrAccessRequestDecided = errors.New("access request is already decided")
+
+// ErrAccessRequestPending возвращается, если у пользователя уже есть заявка на доступ на рассмотрении
+var ErrAccessRequestPending = errors.New("access request is already pending")
+
 // GetNotifications получает неотправленные уведомления
 func GetNotifications() ([]models.StepState, error) {
 	logger.InfoWithCaller("Получение списка неотправленных уведомлений")
@@ -69,13 +86,13 @@ func CreateStepNotify(step models.Step, status string, tx *gorm.DB) error {
 	stepState := models.StepState{
 		StandName: step.Pipeline.Stand.Name,
 		StepName:  step.Name,
-		UserID:    step.Pipeline.Stand.UserID,
 		Status:    status,
+		Kind:      models.NotifyKindStep,
 		Order:     step.Order,
 	}
 
-	// Создаем новую запись
-	if err := tx.Create(&stepState).Error; err != nil {
+	// Уведомление получают автор стенда и участники его команды
+	if err := CreateStandMembersNotify(step.Pipeline.Stand, stepState, tx); err != nil {
 		logger.ErrorfWithCaller("Ошибка при создании уведомления для шага %d: %v", step.ID, err)
 		return fmt.Errorf("ошибка при создании состояния шага: %v", err)
 	}
@@ -133,19 +150,29 @@ func CreateStand(stand models.Stand, tx *gorm.DB) error {
 	return nil
 }
 
-func UpdateStandPipeline(standName string, pipelineID int, tx *gorm.DB) error {
-	var pipeline models.Pipeline
-	if err := tx.Where("name = ?", standName).First(&pipeline).Error; err != nil {
+// UpdateStandPipeline привязывает пайплайн GitLab к пайплайну стенда и создает его шаги
+func UpdateStandPipeline(pipelineID uint, gitlabPipelineID int, tx *gorm.DB) error {
+	pipeline, err := GetPipelineByID(pipelineID, tx)
+	if err != nil {
 		return fmt.Errorf("failed to find pipeline: %v", err)
 	}
 
-	pipeline.GitlabPipelineID = pipelineID
-	if err := tx.Save(&pipeline).Error; err != nil {
+	pipeline.GitlabPipelineID = gitlabPipelineID
+	if err := tx.Save(pipeline).Error; err != nil {
 		return fmt.Errorf("failed to update pipeline: %v", err)
 	}
 
-	// Создаем базовые шаги для пайплайна
-	steps := internal.PopulateSteps(pipeline.ID)
+	// Шаги могли быть созданы предыдущей попыткой
+	var stepsCount int64
+	if err := tx.Model(&models.Step{}).Where("pipeline_id = ?", pipeline.ID).Count(&stepsCount).Error; err != nil {
+		return fmt.Errorf("failed to count steps: %v", err)
+	}
+	if stepsCount > 0 {
+		return nil
+	}
+
+	// Создаем шаги для stage, которые выполняет пайплайн
+	steps := internal.PopulateSteps(pipeline.ID, internal.PipelineStages(*pipeline))
 
 	// Сохраняем шаги в БД
 	for _, step := range steps {
@@ -157,11 +184,12 @@ func UpdateStandPipeline(standName string, pipelineID int, tx *gorm.DB) error {
 	return nil
 }
 
-func CreateStandPipeline(standName string, tx *gorm.DB) error {
+// CreateStandPipeline создает новый пайплайн для стенда
+func CreateStandPipeline(standName string, tx *gorm.DB) (models.Pipeline, error) {
 	// Получаем стенд по имени
 	stand, err := GetStandByName(standName, tx)
 	if err != nil {
-		return fmt.Errorf("failed to get stand: %v", err)
+		return models.Pipeline{}, fmt.Errorf("failed to get stand: %v", err)
 	}
 
 	// Создаем новый пайплайн
@@ -169,16 +197,50 @@ func CreateStandPipeline(standName string, tx *gorm.DB) error {
 		Name:      stand.Name,
 		StandID:   stand.ID,
 		Status:    "pending",
+		Ref:       stand.Ref,
+		Kind:      models.PipelineKindProvision,
 		CreatedAt: time.Now(),
 	}
 
+	// Закрепленные версии продуктов передаются в пайплайн переменными
+	var products models.ProductList
+	if err := json.Unmarshal(stand.Products, &products); err != nil {
+		return models.Pipeline{}, fmt.Errorf("failed to parse stand products: %v", err)
+	}
+	variables := internal.ProvisionVariables(products)
+
+	// Пересоздание может выполнять только часть stage
+	stages, err := internal.StandStages(*stand)
+	if err != nil {
+		return models.Pipeline{}, err
+	}
+	if len(stages) > 0 {
+		if variables == nil {
+			variables = make(map[string]string)
+		}
+		variables[internal.VarDeployStages] = strings.Join(stages, ",")
+	}
+
+	if variables != nil {
+		variablesJSON, err := json.Marshal(variables)
+		if err != nil {
+			return models.Pipeline{}, err
+		}
+		pipeline.Variables = variablesJSON
+	}
+
 	// Сохраняем пайплайн в БД
-	_, err = CreatePipeline(pipeline, tx)
+	pipeline, err = CreatePipeline(pipeline, tx)
 	if err != nil {
-		return fmt.Errorf("failed to create pipeline: %v", err)
+		return models.Pipeline{}, fmt.Errorf("failed to create pipeline: %v", err)
 	}
 
-	return nil
+	// Прошлые пайплайны стенда остаются в истории
+	if err := tx.Model(stand).Update("current_pipeline_id", pipeline.ID).Error; err != nil {
+		return models.Pipeline{}, fmt.Errorf("failed to set current pipeline: %v", err)
+	}
+
+	return pipeline, nil
 }
 
 func GetAllSubos() ([]models.Subos, error) {
@@ -189,23 +251,47 @@ func GetAllSubos() ([]models.Subos, error) {
 	return subos, nil
 }
 
-// CreateUser creates a new user in the database
-func CreateUser(user *models.User) error {
-	result := DB.Create(user)
-	return result.Error
+// CreateUser создает пользователя с ролями. Роли должны быть получены через GetRoles
+func CreateUser(user *models.User, tx *gorm.DB) error {
+	if err := tx.Omit("Roles.*").Create(user).Error; err != nil {
+		return fmt.Errorf("ошибка при создании пользователя %d: %v", user.ID, err)
+	}
+	return nil
+}
+
+// GetRoles возвращает роли с именами names, создавая отсутствующие
+func GetRoles(names []string, tx *gorm.DB) ([]models.Role, error) {
+	roles := make([]models.Role, 0, len(names))
+	for _, name := range names {
+		role := models.Role{Name: name}
+		if err := tx.Where(role).FirstOrCreate(&role).Error; err != nil {
+			return nil, fmt.Errorf("ошибка при получении роли %s: %v", name, err)
+		}
+		roles = append(roles, role)
+	}
+	return roles, nil
 }
 
 func GetProductsFromStand(stand string, tx *gorm.DB) ([]string, error) {
+	products, err := GetStandProducts(stand, tx)
+	if err != nil {
+		return nil, err
+	}
+	return products.Codes(), nil
+}
+
+// GetStandProducts возвращает продукты стенда вместе с закрепленными версиями
+func GetStandProducts(stand string, tx *gorm.DB) (models.ProductList, error) {
 	var standInfo models.Stand
 	result := tx.Where("name = ?", stand).First(&standInfo)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("stand not found")
+			return nil, ErrStandNotFound
 		}
 		return nil, result.Error
 	}
 
-	var products []string
+	var products models.ProductList
 	if err := json.Unmarshal(standInfo.Products, &products); err != nil {
 		return nil, err
 	}
@@ -215,7 +301,20 @@ func GetProductsFromStand(stand string, tx *gorm.DB) ([]string, error) {
 // GetUserByID retrieves a user by ID from the database
 func GetUserByID(id uint) (*models.User, error) {
 	var user models.User
-	result := DB.First(&user, id)
+	result := DB.Preload("Roles").First(&user, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("user not found")
+		}
+		return nil, result.Error
+	}
+	return &user, nil
+}
+
+// GetUserByName возвращает пользователя по имени без учета регистра и начального @
+func GetUserByName(name string) (*models.User, error) {
+	var user models.User
+	result := DB.Preload("Roles").Where("LOWER(name) = LOWER(?)", strings.TrimPrefix(name, "@")).First(&user)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
 			return nil, errors.New("user not found")
@@ -228,23 +327,65 @@ func GetUserByID(id uint) (*models.User, error) {
 // GetAllUsers retrieves all users from the database
 func GetAllUsers() ([]models.User, error) {
 	var users []models.User
-	result := DB.Find(&users)
+	result := DB.Preload("Roles").Find(&users)
 	if result.Error != nil {
 		return nil, result.Error
 	}
 	return users, nil
 }
 
-// UpdateUser updates an existing user in the database
-func UpdateUser(user *models.User) error {
-	result := DB.Save(user)
-	return result.Error
+// UpdateUser сохраняет имя пользователя и заменяет его роли на user.Roles
+func UpdateUser(user *models.User, tx *gorm.DB) error {
+	if err := tx.Model(user).Update("name", user.Name).Error; err != nil {
+		return fmt.Errorf("ошибка при обновлении пользователя %d: %v", user.ID, err)
+	}
+	if err := tx.Model(user).Association("Roles").Replace(user.Roles); err != nil {
+		return fmt.Errorf("ошибка при обновлении ролей пользователя %d: %v", user.ID, err)
+	}
+	return nil
 }
 
-// DeleteUser deletes a user from the database
-func DeleteUser(id uint) error {
-	result := DB.Unscoped().Delete(&models.User{}, id) // Добавлено Unscoped() для жесткого удаления
-	return result.Error
+// DeleteUser отзывает роли пользователя, удаляет его участие в командах, доступы, бронирования
+// и уведомления, а действующие изменения квоты завершает. Расписания пользователя нужно удалить
+// заранее. Если у пользователя были стенды или изменения квоты, запись пользователя остается
+// ради истории, иначе удаляется. Возвращает true, если запись удалена
+func DeleteUser(user *models.User, tx *gorm.DB) (bool, error) {
+	if err := tx.Model(user).Association("Roles").Clear(); err != nil {
+		return false, fmt.Errorf("ошибка при отзыве ролей пользователя %d: %v", user.ID, err)
+	}
+	for _, model := range []any{&models.TeamMember{}, &models.StandCollaborator{}, &models.StandBooking{}, &models.StepState{}} {
+		if err := tx.Unscoped().Where("user_id = ?", user.ID).Delete(model).Error; err != nil {
+			return false, fmt.Errorf("ошибка при удалении данных пользователя %d: %v", user.ID, err)
+		}
+	}
+	now := time.Now()
+	if err := tx.Model(&models.QuotaGrant{}).
+		Where("user_id = ? AND (expires_at IS NULL OR expires_at > ?)", user.ID, now).
+		Update("expires_at", now).Error; err != nil {
+		return false, fmt.Errorf("ошибка при завершении изменений квоты пользователя %d: %v", user.ID, err)
+	}
+
+	var stands, grants int64
+	if err := tx.Unscoped().Model(&models.Stand{}).Where("user_id = ?", user.ID).Count(&stands).Error; err != nil {
+		return false, err
+	}
+	if err := tx.Model(&models.QuotaGrant{}).Where("user_id = ?", user.ID).Count(&grants).Error; err != nil {
+		return false, err
+	}
+	if stands > 0 || grants > 0 {
+		return false, nil
+	}
+	if err := tx.Unscoped().Delete(&models.User{}, user.ID).Error; err != nil {
+		return false, fmt.Errorf("ошибка при удалении пользователя %d: %v", user.ID, err)
+	}
+	return true, nil
+}
+
+// CountUserStands возвращает число действующих стендов автора userID
+func CountUserStands(userID uint, tx *gorm.DB) (int64, error) {
+	var count int64
+	err := tx.Model(&models.Stand{}).Where("user_id = ?", userID).Count(&count).Error
+	return count, err
 }
 
 // GetAllStands retrieves all stands from the database
@@ -263,7 +404,7 @@ func GetStandByName(name string, tx *gorm.DB) (*models.Stand, error) {
 	result := tx.Where("name = ?", name).First(&stand)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("stand not found")
+			return nil, ErrStandNotFound
 		}
 		return nil, result.Error
 	}
@@ -288,7 +429,7 @@ func GetStatusStandByName(name string, tx *gorm.DB) (string, error) {
 	result := tx.Where("name = ?", name).First(&stand)
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return "", errors.New("stand not found")
+			return "", ErrStandNotFound
 		}
 		return "nil", result.Error
 	}
@@ -339,7 +480,7 @@ func GetStandWithSteps(standID uint) (*models.Stand, error) {
 
 	if result.Error != nil {
 		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
-			return nil, errors.New("stand not found")
+			return nil, ErrStandNotFound
 		}
 		return nil, result.Error
 	}
@@ -368,3 +509,1254 @@ func GetStepByStandName(standName string, tx *gorm.DB) ([]models.Step, error) {
 	}
 	return step, nil
 }
+
+// CreateStandNotify создает уведомление о событии стенда, не привязанном к шагу пайплайна
+func CreateStandNotify(stand models.Stand, stepName string, status string, message string, tx *gorm.DB) error {
+	logger.InfofWithCaller("Создание уведомления для стенда %s со статусом %s", stand.Name, status)
+
+	stepState := models.StepState{
+		StandName: stand.Name,
+		StepName:  stepName,
+		Status:    status,
+		Kind:      models.NotifyKindStand,
+		Message:   message,
+	}
+
+	if err := CreateStandMembersNotify(stand, stepState, tx); err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании уведомления для стенда %s: %v", stand.Name, err)
+		return err
+	}
+	return nil
+}
+
+// GetProvisionActions возвращает действия подготовки стенда в порядке выполнения
+func GetProvisionActions(standID uint, tx *gorm.DB) ([]models.ProvisionAction, error) {
+	var actions []models.ProvisionAction
+	if err := tx.Where("stand_id = ?", standID).Order("\"order\" asc").Find(&actions).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении действий подготовки стенда: %v", err)
+	}
+	return actions, nil
+}
+
+// SaveProvisionAction записывает выполненное действие подготовки стенда
+func SaveProvisionAction(action models.ProvisionAction, tx *gorm.DB) error {
+	if err := tx.Where("stand_id = ? AND name = ?", action.StandID, action.Name).
+		Delete(&models.ProvisionAction{}).Error; err != nil {
+		return fmt.Errorf("ошибка при удалении предыдущей записи действия %s: %v", action.Name, err)
+	}
+	if err := tx.Create(&action).Error; err != nil {
+		return fmt.Errorf("ошибка при сохранении действия %s: %v", action.Name, err)
+	}
+	return nil
+}
+
+// MarkProvisionActionCreated отмечает, что начатое действие подготовки стенда создает ресурс.
+// Отметка ставится до обращения к GitLab, чтобы после сбоя ресурс считался созданным стендом
+func MarkProvisionActionCreated(standID uint, name string, tx *gorm.DB) error {
+	if err := tx.Model(&models.ProvisionAction{}).Where("stand_id = ? AND name = ?", standID, name).
+		Update("created", true).Error; err != nil {
+		return fmt.Errorf("ошибка при обновлении действия %s: %v", name, err)
+	}
+	return nil
+}
+
+// UpdateProvisionActionStatus обновляет статус действия подготовки стенда
+func UpdateProvisionActionStatus(status string, action *models.ProvisionAction, tx *gorm.DB) error {
+	if err := tx.Model(action).Update("status", status).Error; err != nil {
+		return fmt.Errorf("ошибка при обновлении статуса действия %s: %v", action.Name, err)
+	}
+	return nil
+}
+
+// ClearProvisionActions удаляет записи о действиях подготовки стенда
+func ClearProvisionActions(standID uint, tx *gorm.DB) error {
+	if err := tx.Where("stand_id = ?", standID).Delete(&models.ProvisionAction{}).Error; err != nil {
+		return fmt.Errorf("ошибка при удалении действий подготовки стенда: %v", err)
+	}
+	return nil
+}
+
+// GetPipelineByID retrieves a pipeline by ID from the database
+func GetPipelineByID(id uint, tx *gorm.DB) (*models.Pipeline, error) {
+	var pipeline models.Pipeline
+	result := tx.First(&pipeline, id)
+	if result.Error != nil {
+		if errors.Is(result.Error, gorm.ErrRecordNotFound) {
+			return nil, errors.New("pipeline not found")
+		}
+		return nil, result.Error
+	}
+	return &pipeline, nil
+}
+
+// IsGitlabPipelineTracked проверяет, записан ли пайплайн GitLab в БД
+func IsGitlabPipelineTracked(gitlabPipelineID int, tx *gorm.DB) (bool, error) {
+	var count int64
+	if err := tx.Model(&models.Pipeline{}).Where("gitlab_pipeline_id = ?", gitlabPipelineID).Count(&count).Error; err != nil {
+		return false, fmt.Errorf("ошибка при поиске пайплайна GitLab %d: %v", gitlabPipelineID, err)
+	}
+	return count > 0, nil
+}
+
+// DeletePipeline удаляет пайплайн вместе с его шагами и джобами
+func DeletePipeline(pipelineID uint, tx *gorm.DB) error {
+	if err := tx.Where("step_id IN (?)", tx.Model(&models.Step{}).Select("id").Where("pipeline_id = ?", pipelineID)).
+		Delete(&models.Job{}).Error; err != nil {
+		return fmt.Errorf("ошибка при удалении джоб пайплайна %d: %v", pipelineID, err)
+	}
+	if err := tx.Where("pipeline_id = ?", pipelineID).Delete(&models.Step{}).Error; err != nil {
+		return fmt.Errorf("ошибка при удалении шагов пайплайна %d: %v", pipelineID, err)
+	}
+	if err := tx.Delete(&models.Pipeline{}, pipelineID).Error; err != nil {
+		return fmt.Errorf("ошибка при удалении пайплайна %d: %v", pipelineID, err)
+	}
+	// Текущим снова становится последний оставшийся пайплайн стенда
+	latest := tx.Model(&models.Pipeline{}).Select("COALESCE(MAX(id), 0)").
+		Where("stand_id = stands.id AND deleted_at IS NULL")
+	if err := tx.Model(&models.Stand{}).Where("current_pipeline_id = ?", pipelineID).
+		Update("current_pipeline_id", latest).Error; err != nil {
+		return fmt.Errorf("ошибка при обновлении текущего пайплайна: %v", err)
+	}
+	return nil
+}
+
+// GetStandPipelines возвращает пайплайны стенда с шагами, начиная с последнего
+func GetStandPipelines(standID uint, tx *gorm.DB) ([]models.Pipeline, error) {
+	var pipelines []models.Pipeline
+	if err := tx.Where("stand_id = ?", standID).
+		Preload("Steps", func(db *gorm.DB) *gorm.DB { return db.Order("\"order\" asc") }).
+		Order("id desc").Find(&pipelines).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении пайплайнов стенда %d: %v", standID, err)
+	}
+	return pipelines, nil
+}
+
+// GetStepsByPipelineID retrieves all steps for a pipeline ordered by execution order
+func GetStepsByPipelineID(pipelineID uint, tx *gorm.DB) ([]models.Step, error) {
+	var steps []models.Step
+	result := tx.Where("pipeline_id = ?", pipelineID).Order("\"order\" asc").Find(&steps)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return steps, nil
+}
+
+// GetManagedStandNames возвращает имена стендов, которым принадлежат ресурсы в GitLab
+func GetManagedStandNames(tx *gorm.DB) ([]string, error) {
+	var names []string
+	// Ресурсы стендов в статусе failed уже должны быть удалены компенсацией
+	if err := tx.Model(&models.Stand{}).Where("status != ?", "failed").Pluck("name", &names).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении имен стендов: %v", err)
+	}
+	return names, nil
+}
+
+// SyncOrphanResources сохраняет результат сканирования ресурсов одного типа
+func SyncOrphanResources(kind string, names []string, seenAt time.Time, tx *gorm.DB) error {
+	for _, name := range names {
+		var orphan models.OrphanResource
+		err := tx.Where("kind = ? AND name = ?", kind, name).First(&orphan).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			orphan = models.OrphanResource{Kind: kind, Name: name, FirstSeenAt: seenAt, LastSeenAt: seenAt}
+			if err := tx.Create(&orphan).Error; err != nil {
+				return fmt.Errorf("ошибка при сохранении ресурса %s %s: %v", kind, name, err)
+			}
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка при получении ресурса %s %s: %v", kind, name, err)
+		}
+
+		updates := map[string]interface{}{"last_seen_at": seenAt}
+		if orphan.RemovedAt != nil {
+			// Ресурс появился снова после удаления, отсчитываем период ожидания заново
+			updates["first_seen_at"] = seenAt
+			updates["removed_at"] = nil
+		}
+		if err := tx.Model(&orphan).Updates(updates).Error; err != nil {
+			return fmt.Errorf("ошибка при обновлении ресурса %s %s: %v", kind, name, err)
+		}
+	}
+
+	// Ресурсы, которые больше не являются осиротевшими, забываем
+	query := tx.Where("kind = ? AND removed_at IS NULL", kind)
+	if len(names) > 0 {
+		query = query.Where("name NOT IN ?", names)
+	}
+	if err := query.Delete(&models.OrphanResource{}).Error; err != nil {
+		return fmt.Errorf("ошибка при удалении устаревших записей %s: %v", kind, err)
+	}
+	return nil
+}
+
+// GetOrphanResources возвращает осиротевшие ресурсы GitLab
+func GetOrphanResources(includeRemoved bool, tx *gorm.DB) ([]models.OrphanResource, error) {
+	var orphans []models.OrphanResource
+	query := tx.Order("kind asc, name asc")
+	if !includeRemoved {
+		query = query.Where("removed_at IS NULL")
+	}
+	if err := query.Find(&orphans).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении осиротевших ресурсов: %v", err)
+	}
+	return orphans, nil
+}
+
+// MarkOrphanResourceRemoved помечает осиротевший ресурс как удаленный из GitLab
+func MarkOrphanResourceRemoved(orphan *models.OrphanResource, tx *gorm.DB) error {
+	if err := tx.Model(orphan).Update("removed_at", time.Now()).Error; err != nil {
+		return fmt.Errorf("ошибка при обновлении ресурса %s %s: %v", orphan.Kind, orphan.Name, err)
+	}
+	return nil
+}
+
+// ImportStand сохраняет стенд, перенесенный из GitLab, вместе с пайплайном, шагами и джобами
+func ImportStand(stand *models.Stand, pipeline *models.Pipeline, jobs []models.Job, tx *gorm.DB) error {
+	if err := CreateStand(*stand, tx); err != nil {
+		return err
+	}
+	imported, err := GetStandByName(stand.Name, tx)
+	if err != nil {
+		return err
+	}
+	*stand = *imported
+
+	if pipeline == nil {
+		return nil
+	}
+
+	pipeline.StandID = stand.ID
+	created, err := CreatePipeline(*pipeline, tx)
+	if err != nil {
+		return fmt.Errorf("failed to create pipeline: %v", err)
+	}
+	*pipeline = created
+
+	steps := internal.PopulateSteps(pipeline.ID, nil)
+	for i := range steps {
+		if err := tx.Create(&steps[i]).Error; err != nil {
+			return fmt.Errorf("failed to create step: %v", err)
+		}
+	}
+
+	jobsProcess, err := internal.ProcessJobs(internal.JobsToMap(jobs), steps)
+	if err != nil {
+		return fmt.Errorf("failed to process jobs: %v", err)
+	}
+	if len(jobsProcess) > 0 {
+		if err := CreateJob(jobsProcess, tx); err != nil {
+			return fmt.Errorf("failed to create jobs: %v", err)
+		}
+	}
+
+	// Статусы шагов и пайплайна определяются реальным состоянием джоб
+	var stepStatuses []string
+	for _, step := range steps {
+		var jobStatuses []string
+		for _, job := range jobsProcess {
+			if job.StepID == step.ID {
+				jobStatuses = append(jobStatuses, job.Status)
+			}
+		}
+		stepStatus := internal.StepStatusFromJobs(jobStatuses)
+		if err := UpdateStepStatus(stepStatus, &step, tx); err != nil {
+			return err
+		}
+		stepStatuses = append(stepStatuses, stepStatus)
+	}
+
+	pipelineStatus := internal.AggregateStatus(stepStatuses)
+	if err := UpdatePipelineStatus(pipelineStatus, pipeline, tx); err != nil {
+		return err
+	}
+	if err := UpdateStandStatus(pipelineStatus, stand, tx); err != nil {
+		return err
+	}
+	return tx.Model(stand).Update("current_pipeline_id", pipeline.ID).Error
+}
+
+// CreateNotify создает уведомление произвольного типа
+func CreateNotify(notification models.StepState, tx *gorm.DB) error {
+	if err := tx.Create(&notification).Error; err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании уведомления %s для пользователя %d: %v",
+			notification.Kind, notification.UserID, err)
+		return fmt.Errorf("ошибка при создании уведомления: %v", err)
+	}
+	return nil
+}
+
+// GetUsersWithPermission возвращает пользователей, роли которых дают право permission
+func GetUsersWithPermission(permission string, tx *gorm.DB) ([]models.User, error) {
+	var users []models.User
+	if err := tx.Preload("Roles").Find(&users).Error; err != nil {
+		return nil, err
+	}
+
+	var result []models.User
+	for _, user := range users {
+		if rbac.Can(user, permission) {
+			result = append(result, user)
+		}
+	}
+	return result, nil
+}
+
+// GetStandsByStatus возвращает стенды с указанным статусом
+func GetStandsByStatus(status string, tx *gorm.DB) ([]models.Stand, error) {
+	var stands []models.Stand
+	if err := tx.Where("status = ?", status).Order("created_at asc").Find(&stands).Error; err != nil {
+		return nil, err
+	}
+	return stands, nil
+}
+
+// GetStepByID возвращает шаг по его ID в БД
+func GetStepByID(id uint, tx *gorm.DB) (*models.Step, error) {
+	var step models.Step
+	if err := tx.First(&step, id).Error; err != nil {
+		return nil, fmt.Errorf("шаг %d не найден: %v", id, err)
+	}
+	return &step, nil
+}
+
+// GetStandByPipelineID возвращает стенд, которому принадлежит пайплайн
+func GetStandByPipelineID(pipelineID uint, tx *gorm.DB) (*models.Stand, error) {
+	var stand models.Stand
+	if err := tx.Joins("JOIN pipelines ON pipelines.stand_id = stands.id").
+		Where("pipelines.id = ?", pipelineID).First(&stand).Error; err != nil {
+		return nil, fmt.Errorf("стенд пайплайна %d не найден: %v", pipelineID, err)
+	}
+	return &stand, nil
+}
+
+// CreateJobApprovalNotify уведомляет автора стенда, участников его команды и администраторов о джобе, ожидающей решения
+func CreateJobApprovalNotify(stand models.Stand, step models.Step, job models.Job, tx *gorm.DB) error {
+	recipients := make(map[uint]bool)
+	members, err := GetStandMembers(stand, tx)
+	if err != nil {
+		return err
+	}
+	for _, userID := range members {
+		recipients[userID] = true
+	}
+	admins, err := GetUsersWithPermission(rbac.StandOperateAny, tx)
+	if err != nil {
+		return err
+	}
+	for _, admin := range admins {
+		recipients[uint(admin.ID)] = true
+	}
+
+	for userID := range recipients {
+		if err := CreateNotify(models.StepState{
+			StandName: stand.Name,
+			StepName:  step.Name,
+			UserID:    userID,
+			Status:    "awaiting_approval",
+			Kind:      models.NotifyKindJobApproval,
+			JobID:     job.ID,
+			Order:     step.Order,
+			Message:   fmt.Sprintf("Джоба %s (stage %s) ожидает решения", job.Name, job.Stage),
+		}, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetSchedules возвращает расписания пользователя userID, при userID = 0 — все расписания
+func GetSchedules(userID uint, tx *gorm.DB) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	query := tx.Order("id asc")
+	if userID != 0 {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении расписаний: %v", err)
+	}
+	return schedules, nil
+}
+
+// GetScheduleByID возвращает расписание по ID
+func GetScheduleByID(id uint, tx *gorm.DB) (*models.Schedule, error) {
+	var schedule models.Schedule
+	if err := tx.First(&schedule, id).Error; err != nil {
+		return nil, fmt.Errorf("расписание %d не найдено: %v", id, err)
+	}
+	return &schedule, nil
+}
+
+// GetDueSchedules возвращает активные расписания, время запуска которых наступило
+func GetDueSchedules(now time.Time, tx *gorm.DB) ([]models.Schedule, error) {
+	var schedules []models.Schedule
+	if err := tx.Where("paused = ? AND next_run_at <= ?", false, now).
+		Order("next_run_at asc").Find(&schedules).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении расписаний к запуску: %v", err)
+	}
+	return schedules, nil
+}
+
+// RebuildStand ставит существующий стенд в очередь на повторную подготовку с новыми продуктами и веткой.
+// Пустой stages — пайплайн выполнит все stage. При recreateBranch подготовка удалит ветку стенда
+// и создаст ее заново из ref
+func RebuildStand(stand *models.Stand, products models.ProductList, ref string, stages []string, recreateBranch bool, tx *gorm.DB) error {
+	updates := map[string]interface{}{
+		"status":             "created",
+		"provision_attempts": 0,
+		"stages":             nil,
+		"recreate_branch":    recreateBranch,
+	}
+	if len(stages) > 0 {
+		stagesJSON, err := json.Marshal(stages)
+		if err != nil {
+			return err
+		}
+		updates["stages"] = stagesJSON
+	}
+	if len(products) > 0 {
+		productsJSON, err := json.Marshal(products)
+		if err != nil {
+			return err
+		}
+		updates["products"] = productsJSON
+	}
+	if ref != "" {
+		updates["ref"] = ref
+	}
+
+	// Действия прошлой подготовки не должны пропускать шаги новой
+	if err := ClearProvisionActions(stand.ID, tx); err != nil {
+		return err
+	}
+	if err := tx.Model(stand).Updates(updates).Error; err != nil {
+		return fmt.Errorf("ошибка при постановке стенда %s на пересоздание: %v", stand.Name, err)
+	}
+	return nil
+}
+
+// UpdateStandDeployments записывает версии продуктов, развернутые успешно завершенным пайплайном
+func UpdateStandDeployments(stand *models.Stand, pipeline models.Pipeline, tx *gorm.DB) error {
+	deployments := make(map[string]string)
+	if len(stand.Deployments) > 0 {
+		if err := json.Unmarshal(stand.Deployments, &deployments); err != nil {
+			return fmt.Errorf("ошибка при разборе версий продуктов стенда %s: %v", stand.Name, err)
+		}
+	}
+
+	variables := make(map[string]string)
+	if len(pipeline.Variables) > 0 {
+		if err := json.Unmarshal(pipeline.Variables, &variables); err != nil {
+			return fmt.Errorf("ошибка при разборе переменных пайплайна %d: %v", pipeline.ID, err)
+		}
+	}
+
+	deployed := make(map[string]string)
+	switch pipeline.Kind {
+	case models.PipelineKindUpgrade:
+		code := variables[internal.VarUpgradeProduct]
+		deployed[code] = internal.DeploymentImage(code, variables[internal.VarUpgradeTag])
+		deployments[code] = deployed[code]
+	default:
+		products, err := GetStandProducts(stand.Name, tx)
+		if err != nil {
+			return err
+		}
+		// Полная подготовка разворачивает ровно продукты стенда в закрепленных версиях
+		deployments = make(map[string]string)
+		for _, product := range products {
+			deployments[product.Code] = internal.DeploymentImage(product.Code, product.Version)
+		}
+		deployed = deployments
+	}
+
+	// Пайплайн может быть учтен и планировщиком, и сверкой, запись создается один раз
+	for code, image := range deployed {
+		record := models.DeploymentHistory{StandID: stand.ID, Product: code, PipelineID: pipeline.ID}
+		if err := tx.Where(record).Attrs(models.DeploymentHistory{Image: image}).FirstOrCreate(&record).Error; err != nil {
+			return fmt.Errorf("ошибка при сохранении истории версий продукта %s стенда %s: %v", code, stand.Name, err)
+		}
+	}
+
+	deploymentsJSON, err := json.Marshal(deployments)
+	if err != nil {
+		return err
+	}
+	if err := tx.Model(stand).Update("deployments", deploymentsJSON).Error; err != nil {
+		return fmt.Errorf("ошибка при обновлении версий продуктов стенда %s: %v", stand.Name, err)
+	}
+	return nil
+}
+
+// GetDeploymentHistory возвращает историю развертываний продукта на стенде, начиная с последнего
+func GetDeploymentHistory(standID uint, product string, tx *gorm.DB) ([]models.DeploymentHistory, error) {
+	var history []models.DeploymentHistory
+	if err := tx.Where("stand_id = ? AND product = ?", standID, product).Order("id desc").Find(&history).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении истории версий продукта %s: %v", product, err)
+	}
+	return history, nil
+}
+
+// GetRollbackImage возвращает образ продукта, к которому нужно откатить стенд.
+// После неудачного обновления продукта это последний успешно развернутый образ,
+// иначе — предыдущий образ из истории, отличный от текущего
+func GetRollbackImage(stand *models.Stand, product string, tx *gorm.DB) (string, error) {
+	deployments := make(map[string]string)
+	if len(stand.Deployments) > 0 {
+		if err := json.Unmarshal(stand.Deployments, &deployments); err != nil {
+			return "", fmt.Errorf("ошибка при разборе версий продуктов стенда %s: %v", stand.Name, err)
+		}
+	}
+	current := deployments[product]
+
+	failed := false
+	if current != "" {
+		var pipelines []models.Pipeline
+		if err := tx.Where("stand_id = ?", stand.ID).Order("id desc").Find(&pipelines).Error; err != nil {
+			return "", fmt.Errorf("ошибка при получении пайплайнов стенда %d: %v", stand.ID, err)
+		}
+		var err error
+		if failed, err = lastUpgradeFailed(pipelines, product); err != nil {
+			return "", err
+		}
+	}
+
+	history, err := GetDeploymentHistory(stand.ID, product, tx)
+	if err != nil {
+		return "", err
+	}
+	return rollbackImage(current, failed, history)
+}
+
+// rollbackImage выбирает образ для отката: текущий образ, если обновление не удалось, иначе последний
+// образ из истории (от новых к старым), отличный от текущего
+func rollbackImage(current string, upgradeFailed bool, history []models.DeploymentHistory) (string, error) {
+	if current != "" && upgradeFailed {
+		return current, nil
+	}
+	for _, record := range history {
+		if record.Image != current {
+			return record.Image, nil
+		}
+	}
+	return "", ErrNoPreviousDeployment
+}
+
+// lastUpgradeFailed проверяет по пайплайнам стенда (от новых к старым), что последний пайплайн, затронувший продукт, —
+// неудачное обновление этого продукта. Пайплайн подготовки разворачивает все продукты заново, на нем поиск заканчивается
+func lastUpgradeFailed(pipelines []models.Pipeline, product string) (bool, error) {
+	for _, pipeline := range pipelines {
+		if pipeline.Kind != models.PipelineKindUpgrade {
+			return false, nil
+		}
+		if len(pipeline.Variables) == 0 {
+			continue
+		}
+		variables := make(map[string]string)
+		if err := json.Unmarshal(pipeline.Variables, &variables); err != nil {
+			return false, fmt.Errorf("ошибка при разборе переменных пайплайна %d: %v", pipeline.ID, err)
+		}
+		if variables[internal.VarUpgradeProduct] == product {
+			return pipeline.Status != "success", nil
+		}
+	}
+	return false, nil
+}
+
+// CreateUpgradeNotify создает уведомление владельцу стенда о результате обновления продукта
+func CreateUpgradeNotify(stand models.Stand, product string, stepName string, status string, message string, tx *gorm.DB) error {
+	stepState := models.StepState{
+		StandName: stand.Name,
+		StepName:  stepName,
+		Status:    status,
+		Kind:      models.NotifyKindUpgrade,
+		Product:   product,
+		Message:   message,
+	}
+	if err := CreateStandMembersNotify(stand, stepState, tx); err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании уведомления об обновлении стенда %s: %v", stand.Name, err)
+		return err
+	}
+	return nil
+}
+
+// FindFleetStands возвращает стенды, подходящие под условия массовой операции
+func FindFleetStands(filter models.FleetFilter, tx *gorm.DB) ([]models.Stand, error) {
+	query := tx.Model(&models.Stand{})
+	if filter.OwnerID != 0 {
+		query = query.Where("user_id = ?", filter.OwnerID)
+	}
+	if filter.Type != "" {
+		query = query.Where("type = ?", filter.Type)
+	}
+
+	var stands []models.Stand
+	if err := query.Order("name asc").Find(&stands).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при поиске стендов для массовой операции: %v", err)
+	}
+
+	// Продукты и метки хранятся в JSON, поэтому проверяются после выборки
+	var result []models.Stand
+	for _, stand := range stands {
+		var products models.ProductList
+		if err := json.Unmarshal(stand.Products, &products); err != nil {
+			logger.ErrorfWithCaller("Некорректный список продуктов стенда %s: %v", stand.Name, err)
+			continue
+		}
+		if filter.Product != "" && !internal.ContainsString(products.Codes(), filter.Product) {
+			continue
+		}
+
+		labels := make(map[string]string)
+		if len(stand.Labels) > 0 {
+			if err := json.Unmarshal(stand.Labels, &labels); err != nil {
+				logger.ErrorfWithCaller("Некорректные метки стенда %s: %v", stand.Name, err)
+				continue
+			}
+		}
+		matched := true
+		for key, value := range filter.Labels {
+			if labels[key] != value {
+				matched = false
+				break
+			}
+		}
+		if matched {
+			result = append(result, stand)
+		}
+	}
+	return result, nil
+}
+
+// CreateFleetOperation создает массовую операцию и ее стенды, разбитые на пачки по BatchSize
+func CreateFleetOperation(operation *models.FleetOperation, stands []models.Stand, tx *gorm.DB) error {
+	if err := tx.Create(operation).Error; err != nil {
+		return fmt.Errorf("ошибка при создании массовой операции: %v", err)
+	}
+
+	targets := make([]models.FleetTarget, 0, len(stands))
+	for i, stand := range stands {
+		targets = append(targets, models.FleetTarget{
+			OperationID: operation.ID,
+			StandID:     stand.ID,
+			StandName:   stand.Name,
+			Batch:       i/operation.BatchSize + 1,
+			Status:      "pending",
+		})
+	}
+	if len(targets) > 0 {
+		if err := tx.Create(&targets).Error; err != nil {
+			return fmt.Errorf("ошибка при создании стендов массовой операции %d: %v", operation.ID, err)
+		}
+	}
+	operation.Targets = targets
+	return nil
+}
+
+// GetFleetOperations возвращает массовые операции со стендами, начиная с последней
+func GetFleetOperations(tx *gorm.DB) ([]models.FleetOperation, error) {
+	var operations []models.FleetOperation
+	if err := tx.Preload("Targets", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	}).Order("id desc").Find(&operations).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении массовых операций: %v", err)
+	}
+	return operations, nil
+}
+
+// GetFleetOperationByID возвращает массовую операцию со стендами
+func GetFleetOperationByID(id uint, tx *gorm.DB) (*models.FleetOperation, error) {
+	var operation models.FleetOperation
+	if err := tx.Preload("Targets", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	}).First(&operation, id).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, fmt.Errorf("fleet operation %d not found", id)
+		}
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// GetFleetOperationsByStatus возвращает массовые операции в статусах statuses
+func GetFleetOperationsByStatus(statuses []string, tx *gorm.DB) ([]models.FleetOperation, error) {
+	var operations []models.FleetOperation
+	if err := tx.Preload("Targets", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	}).Where("status IN ?", statuses).Order("id asc").Find(&operations).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении массовых операций: %v", err)
+	}
+	return operations, nil
+}
+
+// CreateFleetNotify создает уведомление инициатору массовой операции
+func CreateFleetNotify(operation models.FleetOperation, status string, message string, tx *gorm.DB) error {
+	stepState := models.StepState{
+		StepName: fmt.Sprintf("Массовое обновление %d", operation.ID),
+		UserID:   operation.UserID,
+		Status:   status,
+		Kind:     models.NotifyKindFleet,
+		Product:  operation.Product,
+		Message:  message,
+	}
+	if err := tx.Create(&stepState).Error; err != nil {
+		return fmt.Errorf("ошибка при создании уведомления о массовой операции %d: %v", operation.ID, err)
+	}
+	return nil
+}
+
+// GetResourcePools возвращает все пулы ресурсов
+func GetResourcePools(tx *gorm.DB) ([]models.ResourcePool, error) {
+	var pools []models.ResourcePool
+	if err := tx.Order("name asc").Find(&pools).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении пулов ресурсов: %v", err)
+	}
+	return pools, nil
+}
+
+// GetResourcePoolByName возвращает пул ресурсов по имени
+func GetResourcePoolByName(name string, tx *gorm.DB) (*models.ResourcePool, error) {
+	var pool models.ResourcePool
+	if err := tx.Where("name = ?", name).First(&pool).Error; err != nil {
+		return nil, err
+	}
+	return &pool, nil
+}
+
+// SaveResourcePool создает или обновляет пул ресурсов
+func SaveResourcePool(pool *models.ResourcePool, tx *gorm.DB) error {
+	if err := tx.Save(pool).Error; err != nil {
+		return fmt.Errorf("ошибка при сохранении пула ресурсов %s: %v", pool.Name, err)
+	}
+	return nil
+}
+
+// GetRegions возвращает регионы, в которых есть включенные пулы ресурсов
+func GetRegions(tx *gorm.DB) ([]string, error) {
+	var regions []string
+	if err := tx.Model(&models.ResourcePool{}).Where("disabled = ?", false).
+		Distinct().Order("region asc").Pluck("region", &regions).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении регионов: %v", err)
+	}
+	return regions, nil
+}
+
+// GetPlacedStands возвращает стенды, занимающие ресурсы пулов
+func GetPlacedStands(tx *gorm.DB) ([]models.Stand, error) {
+	var stands []models.Stand
+	if err := tx.Where("pool <> ''").Order("name asc").Find(&stands).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении размещенных стендов: %v", err)
+	}
+	return stands, nil
+}
+
+// GetQueuedStands возвращает стенды, ожидающие ресурсов для подготовки
+func GetQueuedStands(tx *gorm.DB) ([]models.Stand, error) {
+	var stands []models.Stand
+	if err := tx.Where("status = ? AND pool = '' AND queue_reason <> ''", "created").
+		Order("created_at asc").Find(&stands).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении стендов в очереди: %v", err)
+	}
+	return stands, nil
+}
+
+// AssignStandPool размещает стенд в пуле ресурсов и снимает причину ожидания
+func AssignStandPool(stand *models.Stand, pool string, tx *gorm.DB) error {
+	if err := tx.Model(stand).Updates(map[string]interface{}{"pool": pool, "queue_reason": ""}).Error; err != nil {
+		return fmt.Errorf("ошибка при размещении стенда %s в пуле %s: %v", stand.Name, pool, err)
+	}
+	return nil
+}
+
+// UpdateStandQueueReason сохраняет причину, по которой стенд ждет в очереди
+func UpdateStandQueueReason(stand *models.Stand, reason string, tx *gorm.DB) error {
+	if err := tx.Model(stand).Update("queue_reason", reason).Error; err != nil {
+		return fmt.Errorf("ошибка при обновлении причины ожидания стенда %s: %v", stand.Name, err)
+	}
+	return nil
+}
+
+// CountWarmStands возвращает количество готовых и готовящихся стендов пула типа standType
+func CountWarmStands(standType string, tx *gorm.DB) (int64, error) {
+	var count int64
+	if err := tx.Model(&models.Stand{}).Where("warm = ? AND type = ? AND status <> ?", true, standType, "failed").
+		Count(&count).Error; err != nil {
+		return 0, fmt.Errorf("ошибка при подсчете готовых стендов типа %s: %v", standType, err)
+	}
+	return count, nil
+}
+
+// FindWarmStand возвращает самый старый готовый стенд пула с типом standType, подготовленный из ref.
+// При непустом region стенд должен быть размещен в пуле ресурсов этого региона
+func FindWarmStand(standType string, ref string, region string, tx *gorm.DB) (*models.Stand, error) {
+	query := tx.Where("warm = ? AND type = ? AND ref = ? AND status = ?", true, standType, ref, "success")
+	if region != "" {
+		query = query.Where("pool IN (?)", tx.Model(&models.ResourcePool{}).Select("name").Where("region = ?", region))
+	}
+
+	var stand models.Stand
+	if err := query.Order("created_at asc").First(&stand).Error; err != nil {
+		return nil, err
+	}
+	return &stand, nil
+}
+
+// ClaimWarmStand передает готовый стенд пула владельцу userID с продуктами products, метками labels
+// и сроком жизни до expiresAt. Если стенд уже выдан другому запросу, возвращает ErrWarmStandTaken
+func ClaimWarmStand(stand *models.Stand, userID uint, teamID *uint, products []byte, labels []byte, expiresAt *time.Time, tx *gorm.DB) error {
+	result := tx.Model(&models.Stand{}).Where("id = ? AND warm = ?", stand.ID, true).Updates(map[string]interface{}{
+		"warm":       false,
+		"user_id":    userID,
+		"team_id":    teamID,
+		"products":   products,
+		"labels":     labels,
+		"expires_at": expiresAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("ошибка при выдаче готового стенда %s: %v", stand.Name, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrWarmStandTaken
+	}
+	stand.Warm = false
+	stand.UserID = userID
+	stand.TeamID = teamID
+	stand.Products = products
+	stand.Labels = labels
+	stand.ExpiresAt = expiresAt
+	return nil
+}
+
+// GetQuotaStands возвращает личные стенды пользователя, которые учитываются в его квоте.
+// Стенды команд учитываются в квотах команд
+func GetQuotaStands(userID uint, tx *gorm.DB) ([]models.Stand, error) {
+	var stands []models.Stand
+	if err := tx.Where("user_id = ? AND team_id IS NULL AND warm = ? AND status NOT IN ?", userID, false, []string{"failed", "rejected"}).
+		Order("name asc").Find(&stands).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении стендов пользователя %d: %v", userID, err)
+	}
+	return stands, nil
+}
+
+// GetActiveQuotaGrants возвращает действующие на момент now изменения квоты пользователя
+func GetActiveQuotaGrants(userID uint, now time.Time, tx *gorm.DB) ([]models.QuotaGrant, error) {
+	var grants []models.QuotaGrant
+	if err := tx.Where("user_id = ? AND (expires_at IS NULL OR expires_at > ?)", userID, now).
+		Order("id asc").Find(&grants).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении изменений квоты пользователя %d: %v", userID, err)
+	}
+	return grants, nil
+}
+
+// CreateQuotaGrant сохраняет изменение квоты и уведомляет пользователя
+func CreateQuotaGrant(grant *models.QuotaGrant, message string, tx *gorm.DB) error {
+	if err := tx.Create(grant).Error; err != nil {
+		return fmt.Errorf("ошибка при сохранении изменения квоты пользователя %d: %v", grant.UserID, err)
+	}
+	return CreateNotify(models.StepState{
+		StepName: "Квота",
+		UserID:   grant.UserID,
+		Status:   "success",
+		Kind:     models.NotifyKindQuota,
+		Message:  message,
+	}, tx)
+}
+
+// GetExpiredStands возвращает стенды с истекшим сроком жизни, владельцы которых еще не уведомлены
+func GetExpiredStands(now time.Time, tx *gorm.DB) ([]models.Stand, error) {
+	var stands []models.Stand
+	if err := tx.Where("expires_at <= ? AND expiry_notified = ? AND status NOT IN ?", now, false, []string{"failed", "rejected"}).
+		Find(&stands).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении стендов с истекшим сроком жизни: %v", err)
+	}
+	return stands, nil
+}
+
+// NotifyStandExpired уведомляет владельца об окончании срока жизни стенда
+func NotifyStandExpired(stand *models.Stand, tx *gorm.DB) error {
+	if err := tx.Model(stand).Update("expiry_notified", true).Error; err != nil {
+		return fmt.Errorf("ошибка при обновлении стенда %s: %v", stand.Name, err)
+	}
+	return CreateStandMembersNotify(*stand, models.StepState{
+		StandName: stand.Name,
+		StepName:  "Срок жизни стенда " + stand.Name,
+		Status:    "error",
+		Kind:      models.NotifyKindQuota,
+		Message:   fmt.Sprintf("Срок жизни стенда истек %s", stand.ExpiresAt.Format("02.01.2006 15:04")),
+	}, tx)
+}
+
+// ExtendStand переносит окончание срока жизни стенда на expiresAt
+func ExtendStand(stand *models.Stand, expiresAt time.Time, tx *gorm.DB) error {
+	if err := tx.Model(stand).Updates(map[string]interface{}{
+		"expires_at":      expiresAt,
+		"expiry_notified": false,
+	}).Error; err != nil {
+		return fmt.Errorf("ошибка при продлении стенда %s: %v", stand.Name, err)
+	}
+	return nil
+}
+
+// GetStandMembers возвращает пользователей, которые получают уведомления о стенде:
+// автора, участников команды стенда и соавторов
+func GetStandMembers(stand models.Stand, tx *gorm.DB) ([]uint, error) {
+	members := []uint{stand.UserID}
+	seen := map[uint]bool{stand.UserID: true}
+
+	var userIDs []uint
+	if stand.TeamID != nil {
+		if err := tx.Model(&models.TeamMember{}).Where("team_id = ?", *stand.TeamID).Order("id asc").
+			Pluck("user_id", &userIDs).Error; err != nil {
+			return nil, fmt.Errorf("ошибка при получении участников команды стенда %s: %v", stand.Name, err)
+		}
+	}
+	var collaborators []uint
+	if err := tx.Model(&models.StandCollaborator{}).Where("stand_id = ?", stand.ID).Order("id asc").
+		Pluck("user_id", &collaborators).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении соавторов стенда %s: %v", stand.Name, err)
+	}
+
+	for _, userID := range append(userIDs, collaborators...) {
+		if !seen[userID] {
+			seen[userID] = true
+			members = append(members, userID)
+		}
+	}
+	return members, nil
+}
+
+// CreateStandMembersNotify создает уведомление о стенде автору, участникам команды стенда и соавторам
+func CreateStandMembersNotify(stand models.Stand, notification models.StepState, tx *gorm.DB) error {
+	members, err := GetStandMembers(stand, tx)
+	if err != nil {
+		return err
+	}
+	for _, userID := range members {
+		notification.ID = 0
+		notification.UserID = userID
+		if err := CreateNotify(notification, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// GetTeams возвращает команды с участниками, при userID — только команды пользователя
+func GetTeams(userID uint, tx *gorm.DB) ([]models.Team, error) {
+	query := tx.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	}).Order("name asc")
+	if userID != 0 {
+		query = query.Where("id IN (?)", tx.Model(&models.TeamMember{}).Select("team_id").Where("user_id = ?", userID))
+	}
+
+	var teams []models.Team
+	if err := query.Find(&teams).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении команд: %v", err)
+	}
+	return teams, nil
+}
+
+// GetTeamByName возвращает команду с участниками по имени
+func GetTeamByName(name string, tx *gorm.DB) (*models.Team, error) {
+	var team models.Team
+	if err := tx.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	}).Where("name = ?", name).First(&team).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// GetTeamByID возвращает команду с участниками по ID
+func GetTeamByID(id uint, tx *gorm.DB) (*models.Team, error) {
+	var team models.Team
+	if err := tx.Preload("Members", func(db *gorm.DB) *gorm.DB {
+		return db.Order("id asc")
+	}).First(&team, id).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// CreateTeam создает команду вместе с участниками
+func CreateTeam(team *models.Team, tx *gorm.DB) error {
+	if err := tx.Create(team).Error; err != nil {
+		return fmt.Errorf("ошибка при создании команды %s: %v", team.Name, err)
+	}
+	return nil
+}
+
+// SaveTeam сохраняет описание и квоту команды без изменения участников
+func SaveTeam(team *models.Team, tx *gorm.DB) error {
+	if err := tx.Omit("Members").Save(team).Error; err != nil {
+		return fmt.Errorf("ошибка при сохранении команды %s: %v", team.Name, err)
+	}
+	return nil
+}
+
+// DeleteTeam удаляет команду и ее участников
+func DeleteTeam(team *models.Team, tx *gorm.DB) error {
+	if err := tx.Where("team_id = ?", team.ID).Delete(&models.TeamMember{}).Error; err != nil {
+		return fmt.Errorf("ошибка при удалении участников команды %s: %v", team.Name, err)
+	}
+	if err := tx.Delete(team).Error; err != nil {
+		return fmt.Errorf("ошибка при удалении команды %s: %v", team.Name, err)
+	}
+	return nil
+}
+
+// SaveTeamMember добавляет пользователя в команду или меняет его роль
+func SaveTeamMember(teamID uint, userID uint, role string, tx *gorm.DB) error {
+	member := models.TeamMember{TeamID: teamID, UserID: userID}
+	if err := tx.Where(member).Assign(models.TeamMember{Role: role}).FirstOrCreate(&member).Error; err != nil {
+		return fmt.Errorf("ошибка при сохранении участника %d команды %d: %v", userID, teamID, err)
+	}
+	return nil
+}
+
+// DeleteTeamMember исключает пользователя из команды
+func DeleteTeamMember(teamID uint, userID uint, tx *gorm.DB) error {
+	if err := tx.Where("team_id = ? AND user_id = ?", teamID, userID).Delete(&models.TeamMember{}).Error; err != nil {
+		return fmt.Errorf("ошибка при удалении участника %d команды %d: %v", userID, teamID, err)
+	}
+	return nil
+}
+
+// GetTeamStands возвращает стенды команды, которые учитываются в ее квоте
+func GetTeamStands(teamID uint, tx *gorm.DB) ([]models.Stand, error) {
+	var stands []models.Stand
+	if err := tx.Where("team_id = ? AND status NOT IN ?", teamID, []string{"failed", "rejected"}).
+		Order("name asc").Find(&stands).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении стендов команды %d: %v", teamID, err)
+	}
+	return stands, nil
+}
+
+// GetStandCollaborators возвращает соавторов стенда
+func GetStandCollaborators(standID uint, tx *gorm.DB) ([]models.StandCollaborator, error) {
+	var collaborators []models.StandCollaborator
+	if err := tx.Where("stand_id = ?", standID).Order("id asc").Find(&collaborators).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении соавторов стенда %d: %v", standID, err)
+	}
+	return collaborators, nil
+}
+
+// GetStandCollaborator возвращает соавтора стенда по ID пользователя
+func GetStandCollaborator(standID uint, userID uint, tx *gorm.DB) (*models.StandCollaborator, error) {
+	var collaborator models.StandCollaborator
+	if err := tx.Where("stand_id = ? AND user_id = ?", standID, userID).First(&collaborator).Error; err != nil {
+		return nil, err
+	}
+	return &collaborator, nil
+}
+
+// SaveStandCollaborator выдает пользователю доступ к стенду или меняет его право
+func SaveStandCollaborator(standID uint, userID uint, permission string, grantedBy uint, tx *gorm.DB) error {
+	collaborator := models.StandCollaborator{StandID: standID, UserID: userID}
+	if err := tx.Where(collaborator).Assign(models.StandCollaborator{Permission: permission, GrantedBy: grantedBy}).
+		FirstOrCreate(&collaborator).Error; err != nil {
+		return fmt.Errorf("ошибка при выдаче доступа к стенду %d пользователю %d: %v", standID, userID, err)
+	}
+	return nil
+}
+
+// DeleteStandCollaborator отзывает доступ пользователя к стенду
+func DeleteStandCollaborator(standID uint, userID uint, tx *gorm.DB) error {
+	if err := tx.Where("stand_id = ? AND user_id = ?", standID, userID).Delete(&models.StandCollaborator{}).Error; err != nil {
+		return fmt.Errorf("ошибка при отзыве доступа к стенду %d у пользователя %d: %v", standID, userID, err)
+	}
+	return nil
+}
+
+// TransferStand передает стенд новому автору. Доступ нового автора как соавтора больше не нужен
+func TransferStand(stand *models.Stand, userID uint, tx *gorm.DB) error {
+	if err := tx.Model(stand).Update("user_id", userID).Error; err != nil {
+		return fmt.Errorf("ошибка при передаче стенда %s пользователю %d: %v", stand.Name, userID, err)
+	}
+	stand.UserID = userID
+	return DeleteStandCollaborator(stand.ID, userID, tx)
+}
+
+// GetStandBookings возвращает бронирования стенда, которые заканчиваются позже since, в порядке начала
+func GetStandBookings(standID uint, since time.Time, tx *gorm.DB) ([]models.StandBooking, error) {
+	var bookings []models.StandBooking
+	if err := tx.Where("stand_id = ? AND ends_at > ?", standID, since).Order("starts_at asc").Find(&bookings).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении бронирований стенда %d: %v", standID, err)
+	}
+	return bookings, nil
+}
+
+// GetOverlappingBookings возвращает бронирования стенда, пересекающиеся с интервалом [startsAt, endsAt)
+func GetOverlappingBookings(standID uint, startsAt time.Time, endsAt time.Time, tx *gorm.DB) ([]models.StandBooking, error) {
+	var bookings []models.StandBooking
+	if err := tx.Where("stand_id = ? AND starts_at < ? AND ends_at > ?", standID, endsAt, startsAt).
+		Order("starts_at asc").Find(&bookings).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при проверке бронирований стенда %d: %v", standID, err)
+	}
+	return bookings, nil
+}
+
+// GetActiveBooking возвращает бронирование стенда, действующее в момент now
+func GetActiveBooking(standID uint, now time.Time, tx *gorm.DB) (*models.StandBooking, error) {
+	var booking models.StandBooking
+	if err := tx.Where("stand_id = ? AND starts_at <= ? AND ends_at > ?", standID, now, now).First(&booking).Error; err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// GetStandBookingByID возвращает бронирование по ID
+func GetStandBookingByID(id uint, tx *gorm.DB) (*models.StandBooking, error) {
+	var booking models.StandBooking
+	if err := tx.First(&booking, id).Error; err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// CreateStandBooking сохраняет бронирование стенда
+func CreateStandBooking(booking *models.StandBooking, tx *gorm.DB) error {
+	if err := tx.Create(booking).Error; err != nil {
+		return fmt.Errorf("ошибка при бронировании стенда %d: %v", booking.StandID, err)
+	}
+	return nil
+}
+
+// CancelStandBooking завершает начавшееся бронирование в момент now, еще не начавшееся удаляет
+func CancelStandBooking(booking *models.StandBooking, now time.Time, tx *gorm.DB) error {
+	var err error
+	if booking.StartsAt.After(now) {
+		err = tx.Delete(booking).Error
+	} else {
+		err = tx.Model(booking).Update("ends_at", now).Error
+	}
+	if err != nil {
+		return fmt.Errorf("ошибка при отмене бронирования %d: %v", booking.ID, err)
+	}
+	return nil
+}
+
+// GetAPIKeys возвращает все API-ключи, включая отозванные
+func GetAPIKeys(tx *gorm.DB) ([]models.APIKey, error) {
+	var keys []models.APIKey
+	if err := tx.Order("id asc").Find(&keys).Error; err != nil {
+		return nil, fmt.Errorf("ошибка при получении API-ключей: %v", err)
+	}
+	return keys, nil
+}
+
+// GetAPIKeyByHash возвращает API-ключ по хешу
+func GetAPIKeyByHash(hash string, tx *gorm.DB) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := tx.Where("hash = ?", hash).First(&key).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// GetAPIKeyByID возвращает API-ключ по ID
+func GetAPIKeyByID(id uint, tx *gorm.DB) (*models.APIKey, error) {
+	var key models.APIKey
+	if err := tx.First(&key, id).Error; err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// CreateAPIKey сохраняет новый API-ключ
+func CreateAPIKey(key *models.APIKey, tx *gorm.DB) error {
+	if err := tx.Create(key).Error; err != nil {
+		return fmt.Errorf("ошибка при создании API-ключа %s: %v", key.Name, err)
+	}
+	return nil
+}
+
+// TouchAPIKey отмечает время последнего использования API-ключа
+func TouchAPIKey(key *models.APIKey, now time.Time, tx *gorm.DB) error {
+	if err := tx.Model(key).Update("last_used_at", now).Error; err != nil {
+		return fmt.Errorf("ошибка при обновлении API-ключа %d: %v", key.ID, err)
+	}
+	return nil
+}
+
+// RevokeAPIKey отзывает API-ключ и выпущенные по нему токены
+func RevokeAPIKey(key *models.APIKey, now time.Time, tx *gorm.DB) error {
+	if err := tx.Model(key).Update("revoked_at", now).Error; err != nil {
+		return fmt.Errorf("ошибка при отзыве API-ключа %d: %v", key.ID, err)
+	}
+	return nil
+}
+
+// CreateAccessRequest сохраняет заявку на доступ
+func CreateAccessRequest(request *models.AccessRequest, tx *gorm.DB) error {
+	if err := tx.Create(request).Error; err != nil {
+		// Одновременные заявки пользователя отсекает частичный уникальный индекс по заявкам pending
+		if translator, ok := tx.Dialector.(gorm.ErrorTranslator); ok && errors.Is(translator.Translate(err), gorm.ErrDuplicatedKey) {
+			return ErrAccessRequestPending
+		}
+		return fmt.Errorf("ошибка при создании заявки на доступ пользователя %d: %v", request.UserID, err)
+	}
+	return nil
+}
+
+// GetAccessRequests возвращает заявки на доступ со статусом status, пустой status — все заявки
+func GetAccessRequests(status string, tx *gorm.DB) ([]models.AccessRequest, error) {
+	var requests []models.AccessRequest
+	query := tx.Order("created_at asc")
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+	if err := query.Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	return requests, nil
+}
+
+// GetAccessRequestByID возвращает заявку на доступ по ID
+func GetAccessRequestByID(id uint, tx *gorm.DB) (*models.AccessRequest, error) {
+	var request models.AccessRequest
+	if err := tx.First(&request, id).Error; err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
+
+// HasPendingAccessRequest проверяет, ждет ли решения заявка пользователя userID
+func HasPendingAccessRequest(userID int64, tx *gorm.DB) (bool, error) {
+	var count int64
+	err := tx.Model(&models.AccessRequest{}).
+		Where("user_id = ? AND status = ?", userID, models.AccessRequestPending).
+		Count(&count).Error
+	return count > 0, err
+}
+
+// GetLastDeniedAccessRequest возвращает последнюю отклоненную заявку пользователя userID или nil
+func GetLastDeniedAccessRequest(userID int64, tx *gorm.DB) (*models.AccessRequest, error) {
+	var requests []models.AccessRequest
+	if err := tx.Where("user_id = ? AND status = ?", userID, models.AccessRequestDenied).
+		Order("decided_at desc").Limit(1).Find(&requests).Error; err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, nil
+	}
+	return &requests[0], nil
+}
+
+// DecideAccessRequest сохраняет решение по заявке, если оно еще не принято другим администратором
+func DecideAccessRequest(request *models.AccessRequest, tx *gorm.DB) error {
+	result := tx.Model(request).Where("status = ?", models.AccessRequestPending).Updates(map[string]interface{}{
+		"status":          request.Status,
+		"role":            request.Role,
+		"decided_by":      request.DecidedBy,
+		"decision_reason": request.DecisionReason,
+		"decided_at":      request.DecidedAt,
+	})
+	if result.Error != nil {
+		return fmt.Errorf("ошибка при сохранении решения по заявке %d: %v", request.ID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		return ErrAccessRequestDecided
+	}
+	return nil
+}
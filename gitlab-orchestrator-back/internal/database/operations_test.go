@@ -0,0 +1,113 @@
+package database
+
+import (
+	"errors"
+	"gitlab-orchestrator-back/internal/models"
+	"testing"
+)
+
+// upgradePipeline возвращает пайплайн обновления продукта product со статусом status
+func upgradePipeline(id uint, product string, status string) models.Pipeline {
+	return models.Pipeline{
+		ID:        id,
+		Kind:      models.PipelineKindUpgrade,
+		Status:    status,
+		Variables: []byte(`{"UPGRADE_PRODUCT": "` + product + `", "UPGRADE_TAG": "2.0"}`),
+	}
+}
+
+func TestLastUpgradeFailed(t *testing.T) {
+	provision := models.Pipeline{ID: 1, Kind: models.PipelineKindProvision, Status: "success"}
+
+	tests := []struct {
+		name      string
+		pipelines []models.Pipeline // от новых к старым
+		want      bool
+		wantErr   bool
+	}{
+		{name: "нет пайплайнов"},
+		{name: "последний пайплайн — подготовка", pipelines: []models.Pipeline{provision}},
+		{
+			name:      "неудачное обновление продукта",
+			pipelines: []models.Pipeline{upgradePipeline(3, "api", "failed"), provision},
+			want:      true,
+		},
+		{
+			name:      "успешное обновление продукта",
+			pipelines: []models.Pipeline{upgradePipeline(3, "api", "success"), provision},
+		},
+		{
+			name:      "обновление другого продукта пропускается",
+			pipelines: []models.Pipeline{upgradePipeline(4, "web", "success"), upgradePipeline(3, "api", "error"), provision},
+			want:      true,
+		},
+		{
+			name:      "неудачное обновление до пересоздания не учитывается",
+			pipelines: []models.Pipeline{provision, upgradePipeline(0, "api", "failed")},
+		},
+		{
+			name:      "пайплайн без переменных пропускается",
+			pipelines: []models.Pipeline{{ID: 4, Kind: models.PipelineKindUpgrade, Status: "failed"}, upgradePipeline(3, "api", "running")},
+			want:      true,
+		},
+		{
+			name:      "некорректные переменные",
+			pipelines: []models.Pipeline{{ID: 4, Kind: models.PipelineKindUpgrade, Variables: []byte("{")}},
+			wantErr:   true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := lastUpgradeFailed(tt.pipelines, "api")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("lastUpgradeFailed() ошибка = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("lastUpgradeFailed() = %v, ожидалось %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRollbackImage(t *testing.T) {
+	// История от новых образов к старым
+	history := []models.DeploymentHistory{
+		{ID: 3, Image: "api:3.0"},
+		{ID: 2, Image: "api:2.0"},
+		{ID: 1, Image: "api:1.0"},
+	}
+
+	tests := []struct {
+		name          string
+		current       string
+		upgradeFailed bool
+		history       []models.DeploymentHistory
+		want          string
+		wantErr       error
+	}{
+		{name: "предыдущий образ из истории", current: "api:3.0", history: history, want: "api:2.0"},
+		{name: "после неудачного обновления — текущий образ", current: "api:3.0", upgradeFailed: true, history: history, want: "api:3.0"},
+		{
+			name:    "повторные развертывания текущего образа пропускаются",
+			current: "api:2.0",
+			history: []models.DeploymentHistory{{ID: 3, Image: "api:2.0"}, {ID: 2, Image: "api:2.0"}, {ID: 1, Image: "api:1.0"}},
+			want:    "api:1.0",
+		},
+		{name: "текущий образ неизвестен", history: history, want: "api:3.0"},
+		{name: "в истории только текущий образ", current: "api:3.0", history: history[:1], wantErr: ErrNoPreviousDeployment},
+		{name: "история пуста", current: "api:3.0", wantErr: ErrNoPreviousDeployment},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := rollbackImage(tt.current, tt.upgradeFailed, tt.history)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("rollbackImage() ошибка = %v, ожидалась %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("rollbackImage() = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
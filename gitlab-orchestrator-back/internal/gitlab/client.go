@@ -8,6 +8,7 @@ import (
 	"gitlab-orchestrator-back/internal/models"
 	"io"
 	"net/http"
+	neturl "net/url"
 	"strconv"
 	"strings"
 	"time"
@@ -34,9 +35,28 @@ func NewClient() *Client {
 	}
 }
 
+// JobInfo описывает состояние джобы в GitLab
+type JobInfo struct {
+	ID         int        `json:"id"`
+	Name       string     `json:"name"`
+	Stage      string     `json:"stage"`
+	Status     string     `json:"status"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
+// PipelineInfo описывает состояние пайплайна в GitLab
+type PipelineInfo struct {
+	ID         int        `json:"id"`
+	Ref        string     `json:"ref"`
+	Status     string     `json:"status"`
+	StartedAt  *time.Time `json:"started_at"`
+	FinishedAt *time.Time `json:"finished_at"`
+}
+
 type Gitlab interface {
 	CloneBranch(branchName string, refBranch string) error
-	RunPipeline(branchName string) (int, error)
+	RunPipeline(branchName string, variables map[string]string) (int, error)
 	RunJob(jobID int) error
 	GetJobStatus(jobID int) (string, error)
 	GetJobsFromPipeline(pipelineID int) ([]models.Job, error)
@@ -46,6 +66,20 @@ type Gitlab interface {
 	CreateEnvironmentIntoRepository(branchName string) error
 	CreateVariablesIntoEnvironment(branchName string, variables []string) error
 	UpdateVariablesIntoEnvironment(branchName string, variables []string) error
+	DeleteBranch(branchName string) error
+	DeleteEnvironment(branchName string) error
+	DeleteVariablesFromEnvironment(branchName string) error
+	CancelPipeline(pipelineID int) error
+	GetJob(jobID int) (JobInfo, error)
+	GetPipeline(pipelineID int) (PipelineInfo, error)
+	ListBranches() ([]string, error)
+	ListEnvironments() ([]string, error)
+	ListVariableScopes(key string) ([]string, error)
+	GetVariablesFromEnvironment(branchName string) (string, error)
+	GetLatestPipeline(ref string) (PipelineInfo, error)
+	GetJobTrace(jobID int) (string, error)
+	GetJobArtifact(jobID int, artifactPath string) ([]byte, error)
+	LintCI(ref string) (LintResult, error)
 }
 
 // RunJob запускает конкретную джобу в GitLab
@@ -354,11 +388,16 @@ func (c *Client) CreateEnvironmentIntoRepository(branchName string) error {
 	return nil
 }
 
-func (c *Client) RunPipeline(branchName string) (int, error) {
-	logger.InfofWithCaller("Запуск пайплайна для ветки %s", branchName)
+func (c *Client) RunPipeline(branchName string, variables map[string]string) (int, error) {
+	logger.InfofWithCaller("Запуск пайплайна для ветки %s с переменными %v", branchName, variables)
 	url := c.BaseUrl + "/projects/" + strconv.Itoa(c.ProjectID) + "/trigger/pipeline?ref=" + branchName + "&token=" + c.TriggerPipelineToken
 
-	req, err := http.NewRequest("POST", url, nil)
+	form := neturl.Values{}
+	for key, value := range variables {
+		form.Set(fmt.Sprintf("variables[%s]", key), value)
+	}
+
+	req, err := http.NewRequest("POST", url, strings.NewReader(form.Encode()))
 	if err != nil {
 		logger.ErrorfWithCaller("Ошибка при создании запроса: %v", err)
 		return 0, err
@@ -469,3 +508,483 @@ func (c *Client) CloneBranch(branchName string, refBranch string) error {
 	logger.InfofWithCaller("Ветка %s успешно клонирована из %s", branchName, refBranch)
 	return nil
 }
+
+// DeleteBranch удаляет ветку стенда из репозитория
+func (c *Client) DeleteBranch(branchName string) error {
+	logger.InfofWithCaller("Удаление ветки %s", branchName)
+	url := c.BaseUrl + "/projects/" + strconv.Itoa(c.ProjectID) + "/repository/branches/" + branchName
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании запроса: %v", err)
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.PrivateToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при отправке запроса: %v", err)
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.ErrorWithCaller("Не удалось закрыть тело ответа")
+		}
+	}(resp.Body)
+
+	if resp.StatusCode == http.StatusNotFound {
+		logger.InfofWithCaller("Ветка %s уже удалена", branchName)
+		return nil
+	}
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		logger.ErrorfWithCaller("Ошибка при удалении ветки: %s Тело: %s", resp.Status, string(body))
+		return fmt.Errorf("failed to delete branch: %s Body:%s", resp.Status, string(body))
+	}
+
+	logger.InfofWithCaller("Ветка %s успешно удалена", branchName)
+	return nil
+}
+
+// getEnvironmentID возвращает ID окружения с точным совпадением имени или 0, если окружения нет
+func (c *Client) getEnvironmentID(branchName string) (int, error) {
+	url := c.BaseUrl + "/projects/" + strconv.Itoa(c.ProjectID) + "/environments?name=" + branchName
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании запроса: %v", err)
+		return 0, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.PrivateToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при отправке запроса: %v", err)
+		return 0, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.ErrorWithCaller("Не удалось закрыть тело ответа")
+		}
+	}(resp.Body)
+
+	if resp.StatusCode >= 400 {
+		logger.ErrorfWithCaller("Ошибка при получении окружения: %s", resp.Status)
+		return 0, fmt.Errorf("failed to get environment: %s", resp.Status)
+	}
+
+	var environments []struct {
+		ID   int    `json:"id"`
+		Name string `json:"name"`
+	}
+	if err = json.NewDecoder(resp.Body).Decode(&environments); err != nil {
+		logger.ErrorfWithCaller("Ошибка при декодировании ответа: %v", err)
+		return 0, err
+	}
+
+	for _, environment := range environments {
+		if environment.Name == branchName {
+			return environment.ID, nil
+		}
+	}
+	return 0, nil
+}
+
+// DeleteEnvironment останавливает и удаляет окружение стенда
+func (c *Client) DeleteEnvironment(branchName string) error {
+	logger.InfofWithCaller("Удаление окружения %s", branchName)
+	environmentID, err := c.getEnvironmentID(branchName)
+	if err != nil {
+		return err
+	}
+	if environmentID == 0 {
+		logger.InfofWithCaller("Окружение %s уже удалено", branchName)
+		return nil
+	}
+
+	baseUrl := c.BaseUrl + "/projects/" + strconv.Itoa(c.ProjectID) + "/environments/" + strconv.Itoa(environmentID)
+	for _, request := range []struct {
+		method string
+		url    string
+	}{
+		{method: "POST", url: baseUrl + "/stop"}, // GitLab не удаляет активные окружения
+		{method: "DELETE", url: baseUrl},
+	} {
+		req, err := http.NewRequest(request.method, request.url, nil)
+		if err != nil {
+			logger.ErrorfWithCaller("Ошибка при создании запроса: %v", err)
+			return err
+		}
+		req.Header.Set("PRIVATE-TOKEN", c.PrivateToken)
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			logger.ErrorfWithCaller("Ошибка при отправке запроса: %v", err)
+			return err
+		}
+		body, _ := io.ReadAll(resp.Body)
+		if err = resp.Body.Close(); err != nil {
+			logger.ErrorWithCaller("Не удалось закрыть тело ответа")
+		}
+
+		if resp.StatusCode >= 400 {
+			logger.ErrorfWithCaller("Ошибка при удалении окружения: %s Тело: %s", resp.Status, string(body))
+			return fmt.Errorf("failed to delete environment: %s Body:%s", resp.Status, string(body))
+		}
+	}
+
+	logger.InfofWithCaller("Окружение %s успешно удалено", branchName)
+	return nil
+}
+
+// DeleteVariablesFromEnvironment удаляет переменную PRODUCTS окружения стенда
+func (c *Client) DeleteVariablesFromEnvironment(branchName string) error {
+	logger.InfofWithCaller("Удаление переменных окружения %s", branchName)
+	url := c.BaseUrl + "/projects/" + strconv.Itoa(c.ProjectID) + "/variables/PRODUCTS?filter[environment_scope]=" + branchName
+	req, err := http.NewRequest("DELETE", url, nil)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании запроса: %v", err)
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.PrivateToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при отправке запроса: %v", err)
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.ErrorWithCaller("Не удалось закрыть тело ответа")
+		}
+	}(resp.Body)
+
+	if resp.StatusCode == http.StatusNotFound {
+		logger.InfofWithCaller("Переменные окружения %s уже удалены", branchName)
+		return nil
+	}
+
+	if resp.StatusCode >= 400 {
+		logger.ErrorfWithCaller("Ошибка при удалении переменных: %s", resp.Status)
+		return fmt.Errorf("failed to delete vars: %s", resp.Status)
+	}
+
+	logger.InfofWithCaller("Переменные окружения %s успешно удалены", branchName)
+	return nil
+}
+
+// CancelPipeline отменяет пайплайн в GitLab
+func (c *Client) CancelPipeline(pipelineID int) error {
+	logger.InfofWithCaller("Отмена пайплайна %d", pipelineID)
+	url := fmt.Sprintf("%s/projects/%d/pipelines/%d/cancel", c.BaseUrl, c.ProjectID, pipelineID)
+	req, err := http.NewRequest("POST", url, nil)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании запроса: %v", err)
+		return err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.PrivateToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при отправке запроса: %v", err)
+		return err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.ErrorWithCaller("Не удалось закрыть тело ответа")
+		}
+	}(resp.Body)
+
+	if resp.StatusCode >= 400 {
+		body, _ := io.ReadAll(resp.Body)
+		logger.ErrorfWithCaller("Ошибка при отмене пайплайна: %s Тело: %s", resp.Status, string(body))
+		return fmt.Errorf("failed to cancel pipeline: %s Body:%s", resp.Status, string(body))
+	}
+
+	logger.InfofWithCaller("Пайплайн %d успешно отменен", pipelineID)
+	return nil
+}
+
+// GetJob получает состояние джобы из GitLab
+func (c *Client) GetJob(jobID int) (JobInfo, error) {
+	logger.DebugfWithCaller("Получение джобы %d", jobID)
+	url := fmt.Sprintf("%s/projects/%d/jobs/%d", c.BaseUrl, c.ProjectID, jobID)
+
+	var job JobInfo
+	if err := c.getJSON(url, &job); err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении джобы %d: %v", jobID, err)
+		return JobInfo{}, err
+	}
+	return job, nil
+}
+
+// GetPipeline получает состояние пайплайна из GitLab
+func (c *Client) GetPipeline(pipelineID int) (PipelineInfo, error) {
+	logger.DebugfWithCaller("Получение пайплайна %d", pipelineID)
+	url := fmt.Sprintf("%s/projects/%d/pipelines/%d", c.BaseUrl, c.ProjectID, pipelineID)
+
+	var pipeline PipelineInfo
+	if err := c.getJSON(url, &pipeline); err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении пайплайна %d: %v", pipelineID, err)
+		return PipelineInfo{}, err
+	}
+	return pipeline, nil
+}
+
+// getJSON выполняет GET-запрос к GitLab и декодирует ответ в target
+func (c *Client) getJSON(url string, target interface{}) error {
+	body, err := c.getRaw(url)
+	if err != nil {
+		return err
+	}
+	if err := json.Unmarshal(body, target); err != nil {
+		return fmt.Errorf("ошибка при декодировании ответа: %v", err)
+	}
+	return nil
+}
+
+// getRaw выполняет GET-запрос к GitLab и возвращает тело ответа
+func (c *Client) getRaw(url string) ([]byte, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("PRIVATE-TOKEN", c.PrivateToken)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		err := Body.Close()
+		if err != nil {
+			logger.ErrorWithCaller("Не удалось закрыть тело ответа")
+		}
+	}(resp.Body)
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении ответа: %v", err)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("unexpected response: %s Body: %s", resp.Status, string(body))
+	}
+	return body, nil
+}
+
+// GetJobTrace получает лог джобы из GitLab
+func (c *Client) GetJobTrace(jobID int) (string, error) {
+	logger.DebugfWithCaller("Получение лога джобы %d", jobID)
+	url := fmt.Sprintf("%s/projects/%d/jobs/%d/trace", c.BaseUrl, c.ProjectID, jobID)
+
+	trace, err := c.getRaw(url)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении лога джобы %d: %v", jobID, err)
+		return "", err
+	}
+	return string(trace), nil
+}
+
+// GetJobArtifact получает файл artifactPath из артефактов джобы
+func (c *Client) GetJobArtifact(jobID int, artifactPath string) ([]byte, error) {
+	logger.DebugfWithCaller("Получение артефакта %s джобы %d", artifactPath, jobID)
+	url := fmt.Sprintf("%s/projects/%d/jobs/%d/artifacts/%s", c.BaseUrl, c.ProjectID, jobID, artifactPath)
+
+	artifact, err := c.getRaw(url)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении артефакта %s джобы %d: %v", artifactPath, jobID, err)
+		return nil, err
+	}
+	return artifact, nil
+}
+
+// ListBranches возвращает имена всех веток репозитория
+func (c *Client) ListBranches() ([]string, error) {
+	logger.InfoWithCaller("Получение списка веток")
+	var names []string
+	err := c.getAllPages(c.BaseUrl+"/projects/"+strconv.Itoa(c.ProjectID)+"/repository/branches", func(decoder *json.Decoder) error {
+		var branches []struct {
+			Name string `json:"name"`
+		}
+		if err := decoder.Decode(&branches); err != nil {
+			return err
+		}
+		for _, branch := range branches {
+			names = append(names, branch.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении списка веток: %v", err)
+		return nil, err
+	}
+	return names, nil
+}
+
+// ListEnvironments возвращает имена всех окружений проекта
+func (c *Client) ListEnvironments() ([]string, error) {
+	logger.InfoWithCaller("Получение списка окружений")
+	var names []string
+	err := c.getAllPages(c.BaseUrl+"/projects/"+strconv.Itoa(c.ProjectID)+"/environments", func(decoder *json.Decoder) error {
+		var environments []struct {
+			Name string `json:"name"`
+		}
+		if err := decoder.Decode(&environments); err != nil {
+			return err
+		}
+		for _, environment := range environments {
+			names = append(names, environment.Name)
+		}
+		return nil
+	})
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении списка окружений: %v", err)
+		return nil, err
+	}
+	return names, nil
+}
+
+// ListVariableScopes возвращает окружения, для которых задана переменная key
+func (c *Client) ListVariableScopes(key string) ([]string, error) {
+	logger.InfofWithCaller("Получение окружений переменной %s", key)
+	var scopes []string
+	err := c.getAllPages(c.BaseUrl+"/projects/"+strconv.Itoa(c.ProjectID)+"/variables", func(decoder *json.Decoder) error {
+		var variables []struct {
+			Key              string `json:"key"`
+			EnvironmentScope string `json:"environment_scope"`
+		}
+		if err := decoder.Decode(&variables); err != nil {
+			return err
+		}
+		for _, variable := range variables {
+			if variable.Key == key {
+				scopes = append(scopes, variable.EnvironmentScope)
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении переменных: %v", err)
+		return nil, err
+	}
+	return scopes, nil
+}
+
+// getAllPages обходит все страницы списка GitLab, передавая каждую страницу в decode
+func (c *Client) getAllPages(url string, decode func(decoder *json.Decoder) error) error {
+	page := "1"
+	for page != "" {
+		req, err := http.NewRequest("GET", url+"?per_page=100&page="+page, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("PRIVATE-TOKEN", c.PrivateToken)
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+
+		if resp.StatusCode >= 400 {
+			body, _ := io.ReadAll(resp.Body)
+			_ = resp.Body.Close()
+			return fmt.Errorf("unexpected response: %s Body: %s", resp.Status, string(body))
+		}
+
+		err = decode(json.NewDecoder(resp.Body))
+		if closeErr := resp.Body.Close(); closeErr != nil {
+			logger.ErrorWithCaller("Не удалось закрыть тело ответа")
+		}
+		if err != nil {
+			return fmt.Errorf("ошибка при декодировании ответа: %v", err)
+		}
+
+		page = resp.Header.Get("X-Next-Page")
+	}
+	return nil
+}
+
+// GetVariablesFromEnvironment возвращает значение переменной PRODUCTS окружения или пустую строку, если переменной нет
+func (c *Client) GetVariablesFromEnvironment(branchName string) (string, error) {
+	logger.InfofWithCaller("Получение переменных окружения %s", branchName)
+	url := c.BaseUrl + "/projects/" + strconv.Itoa(c.ProjectID) + "/variables/PRODUCTS?filter[environment_scope]=" + branchName
+
+	exist, err := c.CheckVariablesIntoEnvironment(branchName)
+	if err != nil || !exist {
+		return "", err
+	}
+
+	var variable struct {
+		Value string `json:"value"`
+	}
+	if err := c.getJSON(url, &variable); err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении переменных окружения %s: %v", branchName, err)
+		return "", err
+	}
+	return variable.Value, nil
+}
+
+// GetLatestPipeline возвращает последний пайплайн для ref или пустой PipelineInfo, если пайплайнов нет
+func (c *Client) GetLatestPipeline(ref string) (PipelineInfo, error) {
+	logger.InfofWithCaller("Получение последнего пайплайна для %s", ref)
+	url := c.BaseUrl + "/projects/" + strconv.Itoa(c.ProjectID) + "/pipelines?order_by=id&sort=desc&per_page=1&ref=" + ref
+
+	var pipelines []PipelineInfo
+	if err := c.getJSON(url, &pipelines); err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении пайплайнов для %s: %v", ref, err)
+		return PipelineInfo{}, err
+	}
+	if len(pipelines) == 0 {
+		return PipelineInfo{}, nil
+	}
+	// Список пайплайнов не содержит времени запуска и завершения
+	return c.GetPipeline(pipelines[0].ID)
+}
+
+// LintJob описывает джобу, которую создаст пайплайн по результатам проверки CI
+type LintJob struct {
+	Name  string `json:"name"`
+	Stage string `json:"stage"`
+}
+
+// LintResult описывает результат проверки конфигурации CI
+type LintResult struct {
+	Valid    bool      `json:"valid"`
+	Errors   []string  `json:"errors"`
+	Warnings []string  `json:"warnings"`
+	Jobs     []LintJob `json:"jobs"`
+}
+
+// Stages возвращает stage, в которых пайплайн создаст джобы
+func (r LintResult) Stages() []string {
+	var stages []string
+	seen := make(map[string]bool)
+	for _, job := range r.Jobs {
+		if !seen[job.Stage] {
+			seen[job.Stage] = true
+			stages = append(stages, job.Stage)
+		}
+	}
+	return stages
+}
+
+// LintCI проверяет .gitlab-ci.yml ветки ref через CI lint API с имитацией создания пайплайна
+func (c *Client) LintCI(ref string) (LintResult, error) {
+	logger.InfofWithCaller("Проверка конфигурации CI для %s", ref)
+	query := neturl.Values{}
+	query.Set("content_ref", ref)
+	query.Set("dry_run", "true")
+	query.Set("dry_run_ref", ref)
+	query.Set("include_jobs", "true")
+	url := fmt.Sprintf("%s/projects/%d/ci/lint?%s", c.BaseUrl, c.ProjectID, query.Encode())
+
+	var result LintResult
+	if err := c.getJSON(url, &result); err != nil {
+		logger.ErrorfWithCaller("Ошибка при проверке конфигурации CI для %s: %v", ref, err)
+		return LintResult{}, err
+	}
+	return result, nil
+}
@@ -0,0 +1,271 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/rbac"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// maxAccessReasonLength наибольшая длина обоснования заявки на доступ в символах
+const maxAccessReasonLength = 500
+
+// AccessRequestBody тело заявки на доступ к боту
+type AccessRequestBody struct {
+	UserID int64  `json:"userID"` // Telegram ID заявителя
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// AccessDecisionRequest тело решения по заявке на доступ
+type AccessDecisionRequest struct {
+	AdminID int64  `json:"adminID"`
+	Role    string `json:"role"` // Роль при одобрении, по умолчанию user
+	Reason  string `json:"reason"`
+}
+
+// CreateAccessRequest обработчик для создания заявки на доступ
+// @Summary Запросить доступ к боту
+// @Description Создает заявку неизвестного пользователя Telegram на доступ и отправляет администраторам с правом users:manage карточку с решением. После отказа новую заявку можно подать только через ACCESS_REQUEST_COOLDOWN. Доступно только ключу бота
+// @Tags access
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.AccessRequest
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Failure 429 {object} map[string]string
+// @Router /access-requests [post]
+func (h *Handler) CreateAccessRequest(c echo.Context) error {
+	var body AccessRequestBody
+	if err := c.Bind(&body); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	body.Reason = strings.TrimSpace(body.Reason)
+	if body.UserID <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "userID is required"})
+	}
+	if body.Reason == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "reason is required"})
+	}
+	if utf8.RuneCountInString(body.Reason) > maxAccessReasonLength {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("reason must not exceed %d characters", maxAccessReasonLength)})
+	}
+	body.Name = strings.TrimPrefix(strings.TrimSpace(body.Name), "@")
+	if body.Name == "" {
+		body.Name = strconv.FormatInt(body.UserID, 10)
+	}
+
+	if user, err := database.GetUserByID(uint(body.UserID)); err == nil && len(user.Roles) > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "user already has access"})
+	}
+	pending, err := database.HasPendingAccessRequest(body.UserID, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if pending {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "access request is already pending"})
+	}
+	denied, err := database.GetLastDeniedAccessRequest(body.UserID, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	// После отказа новая заявка принимается только через ACCESS_REQUEST_COOLDOWN
+	if denied != nil && denied.DecidedAt != nil {
+		if retryAt := denied.DecidedAt.Add(config.Config.AccessRequestCooldown); time.Now().Before(retryAt) {
+			return c.JSON(http.StatusTooManyRequests, map[string]string{
+				"error": fmt.Sprintf("access request was denied, try again after %s", retryAt.Format("02.01.2006 15:04")),
+			})
+		}
+	}
+
+	request := models.AccessRequest{
+		UserID: body.UserID,
+		Name:   body.Name,
+		Reason: body.Reason,
+		Status: models.AccessRequestPending,
+	}
+	tx := database.DB.Begin()
+	if err := database.CreateAccessRequest(&request, tx); err != nil {
+		tx.Rollback()
+		if errors.Is(err, database.ErrAccessRequestPending) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := notifyAccessApprovers(request, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Пользователь %s (%d) запросил доступ: %s", request.Name, request.UserID, request.Reason)
+	return c.JSON(http.StatusOK, request)
+}
+
+// GetAccessRequests обработчик для получения заявок на доступ
+// @Summary Получить заявки на доступ
+// @Description Возвращает заявки на доступ со статусом status (по умолчанию pending, all — все). Доступно пользователям с правом users:manage
+// @Tags access
+// @Accept json
+// @Produce json
+// @Param status query string false "pending / approved / denied / all"
+// @Success 200 {array} models.AccessRequest
+// @Failure 403 {object} map[string]string
+// @Router /access-requests [get]
+func (h *Handler) GetAccessRequests(c echo.Context) error {
+	status := c.QueryParam("status")
+	switch status {
+	case "":
+		status = models.AccessRequestPending
+	case "all":
+		status = ""
+	}
+
+	requests, err := database.GetAccessRequests(status, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, requests)
+}
+
+// ApproveAccessRequest обработчик для одобрения заявки на доступ
+// @Summary Одобрить заявку на доступ
+// @Description Добавляет заявителя с ролью role (по умолчанию user) и уведомляет его. Доступно пользователям с правом users:manage
+// @Tags access
+// @Accept json
+// @Produce json
+// @Param id path int true "ID заявки"
+// @Success 200 {object} models.AccessRequest
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /access-requests/{id}/approve [post]
+func (h *Handler) ApproveAccessRequest(c echo.Context) error {
+	return h.decideAccessRequest(c, true)
+}
+
+// DenyAccessRequest обработчик для отклонения заявки на доступ
+// @Summary Отклонить заявку на доступ
+// @Description Отклоняет заявку. Заявителя еще нет среди пользователей, поэтому о решении ему сообщает сервис, отклонивший заявку. Доступно пользователям с правом users:manage
+// @Tags access
+// @Accept json
+// @Produce json
+// @Param id path int true "ID заявки"
+// @Success 200 {object} models.AccessRequest
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /access-requests/{id}/deny [post]
+func (h *Handler) DenyAccessRequest(c echo.Context) error {
+	return h.decideAccessRequest(c, false)
+}
+
+func (h *Handler) decideAccessRequest(c echo.Context, approve bool) error {
+	var body AccessDecisionRequest
+	if err := c.Bind(&body); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	body.AdminID = actingUser(c, body.AdminID)
+	requestID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid access request ID"})
+	}
+	request, err := database.GetAccessRequestByID(uint(requestID), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "access request not found"})
+	}
+	if request.Status != models.AccessRequestPending {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("access request is already %s", request.Status)})
+	}
+
+	now := time.Now()
+	request.Status, request.DecidedBy, request.DecisionReason, request.DecidedAt = models.AccessRequestDenied, uint(body.AdminID), body.Reason, &now
+	if approve {
+		if body.Role == "" {
+			body.Role = "user"
+		}
+		roleNames, err := normalizeRoles([]string{body.Role})
+		if err != nil {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		request.Status, request.Role = models.AccessRequestApproved, roleNames[0]
+	}
+
+	tx := database.DB.Begin()
+	if err := database.DecideAccessRequest(request, tx); err != nil {
+		tx.Rollback()
+		if errors.Is(err, database.ErrAccessRequestDecided) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+		}
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if approve {
+		if err := grantAccess(*request, tx); err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Администратор %d принял решение %s по заявке на доступ %d пользователя %s (%d)",
+		body.AdminID, request.Status, request.ID, request.Name, request.UserID)
+	return c.JSON(http.StatusOK, request)
+}
+
+// grantAccess добавляет заявителя с одобренной ролью. Уведомление о роли сообщает ему о решении
+func grantAccess(request models.AccessRequest, tx *gorm.DB) error {
+	user := models.User{ID: request.UserID, Name: request.Name}
+	existing, err := database.GetUserByID(uint(request.UserID))
+	if err == nil {
+		// Пользователь остался без ролей после удаления, сохраняем его прежнее имя
+		user.Name = existing.Name
+	}
+	if err := saveUserRoles(&user, []string{request.Role}, existing != nil, tx); err != nil {
+		return err
+	}
+	return notifyRoles(user, "Заявка на доступ к боту одобрена", tx)
+}
+
+// notifyAccessApprovers отправляет заявку на доступ пользователям с правом users:manage
+func notifyAccessApprovers(request models.AccessRequest, tx *gorm.DB) error {
+	admins, err := database.GetUsersWithPermission(rbac.UsersManage, tx)
+	if err != nil {
+		return err
+	}
+	if len(admins) == 0 {
+		logger.WarnfWithCaller("Нет администраторов для заявки на доступ %d пользователя %d", request.ID, request.UserID)
+		return nil
+	}
+
+	for _, admin := range admins {
+		if err := database.CreateNotify(models.StepState{
+			StepName:  "Заявка на доступ",
+			UserID:    uint(admin.ID),
+			Status:    models.AccessRequestPending,
+			Kind:      models.NotifyKindAccessRequest,
+			RequestID: request.ID,
+			Message:   fmt.Sprintf("Пользователь: %s (%d)\nОбоснование: %s", request.Name, request.UserID, request.Reason),
+		}, tx); err != nil {
+			return err
+		}
+	}
+	return nil
+}
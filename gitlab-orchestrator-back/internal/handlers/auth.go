@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"fmt"
+	"gitlab-orchestrator-back/internal/auth"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// TokenRequest тело запроса на выпуск токена пользователя
+type TokenRequest struct {
+	UserID int64 `json:"userID"`
+}
+
+// TokenResponse токен пользователя и срок его действия
+type TokenResponse struct {
+	Token     string    `json:"token"`
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+// APIKeyRequest тело запроса на создание API-ключа
+type APIKeyRequest struct {
+	UserID        int64  `json:"userID"`
+	Name          string `json:"name"`
+	DurationHours int    `json:"durationHours"` // Срок действия ключа, 0 — бессрочный
+	OwnerID       int64  `json:"ownerID"`       // Владелец ключа, по умолчанию создатель
+	Impersonate   bool   `json:"impersonate"`   // Ключ бота без владельца, действует от имени пользователя из запроса
+}
+
+// APIKeyResponse созданный API-ключ. Сам ключ возвращается только один раз
+type APIKeyResponse struct {
+	models.APIKey
+	Key string `json:"key"`
+}
+
+// IssueToken обработчик для выпуска токена пользователя
+// @Summary Выпустить токен пользователя
+// @Description Выпускает короткоживущий подписанный токен, с которым запросы выполняются от имени пользователя. Доступно только ключу бота (impersonate)
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Success 200 {object} TokenResponse
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /auth/token [post]
+func (h *Handler) IssueToken(c echo.Context) error {
+	var request TokenRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	// Пользователь без ролей остается после удаления ради истории, токен ему не выдается
+	if user, err := database.GetUserByID(uint(request.UserID)); err != nil || len(user.Roles) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+
+	principal, _ := c.Get(auth.ContextKey).(auth.Principal)
+	token, expiresAt, err := auth.IssueToken(request.UserID, principal.KeyID, time.Now())
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Выпущен токен пользователя %d по ключу %q", request.UserID, principal.KeyName)
+	return c.JSON(http.StatusOK, TokenResponse{Token: token, ExpiresAt: expiresAt})
+}
+
+// GetAPIKeys обработчик для получения API-ключей
+// @Summary Получить API-ключи
+// @Description Возвращает все API-ключи без самих ключей. Доступно пользователям с правом keys:manage
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param userID query int true "ID администратора"
+// @Success 200 {array} models.APIKey
+// @Failure 403 {object} map[string]string
+// @Router /admin/keys [get]
+func (h *Handler) GetAPIKeys(c echo.Context) error {
+	keys, err := database.GetAPIKeys(database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, keys)
+}
+
+// CreateAPIKey обработчик для создания API-ключа
+// @Summary Создать API-ключ
+// @Description Создает API-ключ сервиса. Ключ действует только от имени владельца (ownerID, по умолчанию создатель), а ключ бота (impersonate) — от имени пользователя из запроса и выпускает токены. Ключ возвращается только в ответе, хранится лишь его хеш. Доступно пользователям с правом keys:manage
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} APIKeyResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/keys [post]
+func (h *Handler) CreateAPIKey(c echo.Context) error {
+	var request APIKeyRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	if request.Name == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "name is required"})
+	}
+	if request.DurationHours < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "durationHours must not be negative"})
+	}
+	if request.Impersonate && request.OwnerID != 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "an impersonating key cannot have an owner"})
+	}
+	if !request.Impersonate {
+		if request.OwnerID == 0 {
+			request.OwnerID = request.UserID
+		}
+		if owner, err := database.GetUserByID(uint(request.OwnerID)); err != nil || len(owner.Roles) == 0 {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("owner %d must be a user with roles", request.OwnerID)})
+		}
+	}
+
+	key, prefix, hash, err := auth.GenerateKey()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	apiKey := models.APIKey{
+		Name:        request.Name,
+		Prefix:      prefix,
+		Hash:        hash,
+		CreatedBy:   uint(request.UserID),
+		OwnerID:     request.OwnerID,
+		Impersonate: request.Impersonate,
+	}
+	if request.DurationHours > 0 {
+		expiresAt := time.Now().Add(time.Duration(request.DurationHours) * time.Hour)
+		apiKey.ExpiresAt = &expiresAt
+	}
+	if err := database.CreateAPIKey(&apiKey, database.DB); err != nil {
+		return c.JSON(http.StatusConflict, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Администратор %d создал API-ключ %s (%s...), владелец %d, ключ бота: %t",
+		request.UserID, apiKey.Name, apiKey.Prefix, apiKey.OwnerID, apiKey.Impersonate)
+	return c.JSON(http.StatusOK, APIKeyResponse{APIKey: apiKey, Key: key})
+}
+
+// RevokeAPIKey обработчик для отзыва API-ключа
+// @Summary Отозвать API-ключ
+// @Description Отзывает API-ключ и выпущенные по нему токены. Доступно пользователям с правом keys:manage
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path int true "ID ключа"
+// @Param userID query int true "ID администратора"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/keys/{id} [delete]
+func (h *Handler) RevokeAPIKey(c echo.Context) error {
+	userID := actingUser(c, queryInt(c, "userID"))
+	keyID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid key ID"})
+	}
+	apiKey, err := database.GetAPIKeyByID(uint(keyID), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "API key not found"})
+	}
+	if apiKey.RevokedAt != nil {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("API key %s is already revoked", apiKey.Name)})
+	}
+
+	if err := database.RevokeAPIKey(apiKey, time.Now(), database.DB); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Администратор %d отозвал API-ключ %s", userID, apiKey.Name)
+	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("API-ключ %s отозван", apiKey.Name)})
+}
+
+// actingUser возвращает пользователя, от имени которого выполняется запрос. С токеном или ключом владельца это всегда
+// пользователь токена или владелец, а пользователь из запроса (adminID или userID) учитывается только для ключа бота
+func actingUser(c echo.Context, requested int64) int64 {
+	if principal, ok := c.Get(auth.ContextKey).(auth.Principal); ok && !principal.Service() {
+		return principal.UserID
+	}
+	return requested
+}
+
+// queryInt возвращает числовой query-параметр, 0 — если он не задан или некорректен
+func queryInt(c echo.Context, name string) int64 {
+	value, _ := strconv.ParseInt(c.QueryParam(name), 10, 64)
+	return value
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/rbac"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// bookingTimeFormat формат времени бронирования в сообщениях
+const bookingTimeFormat = "02.01.2006 15:04"
+
+// BookingRequest тело запроса на бронирование или блокировку стенда
+type BookingRequest struct {
+	UserID    int64      `json:"userID"`
+	StartsAt  *time.Time `json:"startsAt"` // Начало бронирования, пусто — сейчас
+	EndsAt    time.Time  `json:"endsAt"`
+	Reason    string     `json:"reason"`
+	Exclusive bool       `json:"exclusive"` // Блокировка: другим пользователям операции со стендом запрещены
+}
+
+// BookingResponse бронирование стенда с именем держателя
+type BookingResponse struct {
+	models.StandBooking
+	Name   string `json:"name"`
+	Active bool   `json:"active"` // Бронирование действует сейчас
+}
+
+// GetStandBookings обработчик для получения бронирований стенда
+// @Summary Получить бронирования стенда
+// @Description Возвращает действующее и будущие бронирования стенда в порядке начала
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {array} BookingResponse
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/bookings [get]
+func (h *Handler) GetStandBookings(c echo.Context) error {
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	now := time.Now()
+	bookings, err := database.GetStandBookings(stand.ID, now, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	result := make([]BookingResponse, 0, len(bookings))
+	for _, booking := range bookings {
+		result = append(result, BookingResponse{
+			StandBooking: booking,
+			Name:         userName(booking.UserID),
+			Active:       !booking.StartsAt.After(now),
+		})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// CreateStandBooking обработчик для бронирования или блокировки стенда
+// @Summary Забронировать стенд
+// @Description Бронирует стенд на интервал времени, при exclusive — блокирует операции со стендом для других пользователей. Интервал не должен пересекаться с бронированиями других пользователей. Доступно пользователям с правом operate на стенд
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} models.StandBooking
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stands/{name}/bookings [post]
+func (h *Handler) CreateStandBooking(c echo.Context) error {
+	var request BookingRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	now := time.Now()
+	startsAt := now
+	if request.StartsAt != nil && request.StartsAt.After(now) {
+		startsAt = *request.StartsAt
+	}
+	if !request.EndsAt.After(startsAt) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "endsAt must be after startsAt and in the future"})
+	}
+
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if status, msg := checkStandAccess(request.UserID, *stand, models.PermissionOperate); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+
+	overlapping, err := database.GetOverlappingBookings(stand.ID, startsAt, request.EndsAt, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	for _, booking := range overlapping {
+		if booking.UserID != uint(request.UserID) {
+			return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("stand %s is booked by %s from %s to %s",
+				stand.Name, userName(booking.UserID), booking.StartsAt.Format(bookingTimeFormat), booking.EndsAt.Format(bookingTimeFormat))})
+		}
+	}
+
+	booking := models.StandBooking{
+		StandID:   stand.ID,
+		UserID:    uint(request.UserID),
+		Reason:    request.Reason,
+		Exclusive: request.Exclusive,
+		StartsAt:  startsAt,
+		EndsAt:    request.EndsAt,
+	}
+	if err := database.CreateStandBooking(&booking, database.DB); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Стенд %s забронирован пользователем %d с %s до %s (блокировка: %t)", stand.Name, request.UserID,
+		startsAt.Format(time.RFC3339), request.EndsAt.Format(time.RFC3339), request.Exclusive)
+	return c.JSON(http.StatusOK, booking)
+}
+
+// UnlockStand обработчик для снятия текущего бронирования или блокировки стенда
+// @Summary Снять блокировку стенда
+// @Description Завершает действующее бронирование стенда. Чужое бронирование могут снять пользователи с правом admin на стенд
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/unlock [post]
+func (h *Handler) UnlockStand(c echo.Context) error {
+	var request BookingRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+
+	now := time.Now()
+	booking, err := database.GetActiveBooking(stand.ID, now, database.DB)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("stand %s is not booked now", stand.Name)})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return cancelBooking(c, *stand, booking, request.UserID, now)
+}
+
+// DeleteStandBooking обработчик для отмены бронирования стенда
+// @Summary Отменить бронирование стенда
+// @Description Отменяет будущее бронирование или завершает действующее. Чужое бронирование могут отменить пользователи с правом admin на стенд
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Param id path int true "ID бронирования"
+// @Param userID query int true "ID пользователя"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/bookings/{id} [delete]
+func (h *Handler) DeleteStandBooking(c echo.Context) error {
+	userID := actingUser(c, queryInt(c, "userID"))
+	bookingID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "invalid booking ID"})
+	}
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	booking, err := database.GetStandBookingByID(uint(bookingID), database.DB)
+	if err != nil || booking.StandID != stand.ID {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "booking not found"})
+	}
+	return cancelBooking(c, *stand, booking, userID, time.Now())
+}
+
+// cancelBooking отменяет бронирование от имени userID: свое бронирование можно отменить всегда, чужое — с правом admin на стенд
+func cancelBooking(c echo.Context, stand models.Stand, booking *models.StandBooking, userID int64, now time.Time) error {
+	if booking.UserID != uint(userID) {
+		if status, msg := checkStandAccess(userID, stand, models.PermissionAdmin); status != http.StatusOK {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+	}
+	if err := database.CancelStandBooking(booking, now, database.DB); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Бронирование %d стенда %s отменено пользователем %d", booking.ID, stand.Name, userID)
+	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Бронирование стенда %s пользователем %s снято", stand.Name, userName(booking.UserID))})
+}
+
+// checkStandBooking проверяет, не забронирован ли стенд другим пользователем. Блокировка запрещает операцию всем,
+// кроме держателя и администраторов, а при обычном бронировании держатель получает предупреждение об операции
+func checkStandBooking(userID int64, stand models.Stand, action string) (int, string) {
+	booking, err := database.GetActiveBooking(stand.ID, time.Now(), database.DB)
+	if errors.Is(err, gorm.ErrRecordNotFound) || (err == nil && booking.UserID == uint(userID)) {
+		return http.StatusOK, ""
+	}
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+
+	user, err := database.GetUserByID(uint(userID))
+	if err != nil {
+		return http.StatusForbidden, "user not found"
+	}
+	if booking.Blocks(uint(userID)) && !rbac.Can(*user, rbac.StandAdminAny) {
+		return http.StatusLocked, fmt.Sprintf("stand %s is locked by %s until %s: %s",
+			stand.Name, userName(booking.UserID), booking.EndsAt.Format(bookingTimeFormat), booking.Reason)
+	}
+
+	if err := database.CreateNotify(models.StepState{
+		StandName: stand.Name,
+		StepName:  "Бронирование стенда " + stand.Name,
+		UserID:    booking.UserID,
+		Status:    StatusError,
+		Kind:      models.NotifyKindBooking,
+		Message:   fmt.Sprintf("Пользователь %s выполнил %s во время вашего бронирования", user.Name, action),
+	}, database.DB); err != nil {
+		logger.ErrorfWithCaller("Ошибка при предупреждении держателя бронирования стенда %s: %v", stand.Name, err)
+	}
+	return http.StatusOK, ""
+}
+
+// userName возвращает имя пользователя или его ID, если пользователь не найден
+func userName(userID uint) string {
+	if user, err := database.GetUserByID(userID); err == nil {
+		return user.Name
+	}
+	return strconv.FormatUint(uint64(userID), 10)
+}
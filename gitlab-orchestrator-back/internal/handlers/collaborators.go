@@ -0,0 +1,247 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/quota"
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ShareRequest тело запроса на выдачу доступа к стенду или передачу стенда
+type ShareRequest struct {
+	UserID     int64  `json:"userID"`
+	User       string `json:"user"`       // ID или имя пользователя, которому выдается доступ, @ допускается
+	Permission string `json:"permission"` // view / operate / admin, по умолчанию operate
+}
+
+// CollaboratorResponse соавтор стенда с именем пользователя
+type CollaboratorResponse struct {
+	models.StandCollaborator
+	Name string `json:"name"`
+}
+
+// GetStandCollaborators обработчик для получения соавторов стенда
+// @Summary Получить соавторов стенда
+// @Description Возвращает пользователей, которым выдан доступ к стенду, и их права
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {array} CollaboratorResponse
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/collaborators [get]
+func (h *Handler) GetStandCollaborators(c echo.Context) error {
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	collaborators, err := database.GetStandCollaborators(stand.ID, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	result := make([]CollaboratorResponse, 0, len(collaborators))
+	for _, collaborator := range collaborators {
+		response := CollaboratorResponse{StandCollaborator: collaborator}
+		if user, err := database.GetUserByID(collaborator.UserID); err == nil {
+			response.Name = user.Name
+		}
+		result = append(result, response)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// ShareStand обработчик для выдачи доступа к стенду
+// @Summary Выдать доступ к стенду
+// @Description Выдает пользователю право на стенд или меняет его: view — уведомления, operate — управление стендом, admin — также выдача доступа и передача стенда. Доступно пользователям с правом admin на стенд
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/collaborators [post]
+func (h *Handler) ShareStand(c echo.Context) error {
+	var request ShareRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	if request.Permission == "" {
+		request.Permission = models.PermissionOperate
+	}
+	if !models.ValidPermission(request.Permission) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "permission must be view, operate or admin"})
+	}
+
+	stand, target, status, err := findShareTarget(c.Param("name"), request)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	if uint(target.ID) == stand.UserID {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("%s already owns stand %s", target.Name, stand.Name)})
+	}
+
+	message := fmt.Sprintf("Вам выдан доступ %s к стенду %s", request.Permission, stand.Name)
+	tx := database.DB.Begin()
+	if err := database.SaveStandCollaborator(stand.ID, uint(target.ID), request.Permission, uint(request.UserID), tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := database.CreateNotify(models.StepState{
+		StandName: stand.Name,
+		StepName:  "Доступ к стенду " + stand.Name,
+		UserID:    uint(target.ID),
+		Status:    StatusSuccess,
+		Kind:      models.NotifyKindAccess,
+		Message:   message,
+	}, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Пользователь %d выдал доступ %s к стенду %s пользователю %d", request.UserID, request.Permission, stand.Name, target.ID)
+	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Пользователю %s выдан доступ %s к стенду %s", target.Name, request.Permission, stand.Name)})
+}
+
+// UnshareStand обработчик для отзыва доступа к стенду
+// @Summary Отозвать доступ к стенду
+// @Description Отзывает право пользователя на стенд. Доступно пользователям с правом admin на стенд и самому соавтору
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Param memberID path string true "ID или имя соавтора"
+// @Param userID query int true "ID пользователя, выполняющего действие"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/collaborators/{memberID} [delete]
+func (h *Handler) UnshareStand(c echo.Context) error {
+	userID := actingUser(c, queryInt(c, "userID"))
+	member, err := resolveUser(c.Param("memberID"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("user %s not found", c.Param("memberID"))})
+	}
+	memberID := member.ID
+
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	// Соавтор может отказаться от доступа сам
+	if userID != memberID {
+		if status, msg := checkStandAccess(userID, *stand, models.PermissionAdmin); status != http.StatusOK {
+			return c.JSON(status, map[string]string{"error": msg})
+		}
+	}
+	if _, err := database.GetStandCollaborator(stand.ID, uint(memberID), database.DB); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user is not a collaborator of the stand"})
+	}
+
+	if err := database.DeleteStandCollaborator(stand.ID, uint(memberID), database.DB); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Пользователь %d отозвал доступ к стенду %s у пользователя %d", userID, stand.Name, memberID)
+	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Доступ к стенду %s отозван", stand.Name)})
+}
+
+// TransferStand обработчик для передачи стенда другому пользователю
+// @Summary Передать стенд
+// @Description Делает пользователя автором стенда. Личный стенд должен поместиться в квоту нового автора. Доступно пользователям с правом admin на стенд
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/transfer [post]
+func (h *Handler) TransferStand(c echo.Context) error {
+	var request ShareRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+
+	stand, target, status, err := findShareTarget(c.Param("name"), request)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	if uint(target.ID) == stand.UserID {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("%s already owns stand %s", target.Name, stand.Name)})
+	}
+	// Стенд команды остается в квоте команды
+	if stand.TeamID == nil {
+		status, err := quota.GetStatus(*target, database.DB)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		if _, err := status.Check(stand.Type, 0); err != nil {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("%s: %v", target.Name, err)})
+		}
+	}
+
+	previousOwner := stand.UserID
+	message := fmt.Sprintf("Стенд %s передан пользователю %s", stand.Name, target.Name)
+	tx := database.DB.Begin()
+	if err := database.TransferStand(stand, uint(target.ID), tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := database.CreateStandMembersNotify(*stand, models.StepState{
+		StandName: stand.Name,
+		StepName:  "Доступ к стенду " + stand.Name,
+		Status:    StatusSuccess,
+		Kind:      models.NotifyKindAccess,
+		Message:   message,
+	}, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Стенд %s передан от пользователя %d пользователю %d (инициатор %d)", stand.Name, previousOwner, target.ID, request.UserID)
+	return c.JSON(http.StatusOK, map[string]string{"message": message})
+}
+
+// findShareTarget находит стенд и пользователя из запроса и проверяет, что инициатор может выдавать доступ к стенду
+func findShareTarget(standName string, request ShareRequest) (*models.Stand, *models.User, int, error) {
+	stand, err := database.GetStandByName(standName, database.DB)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, err
+	}
+	if status, msg := checkStandAccess(request.UserID, *stand, models.PermissionAdmin); status != http.StatusOK {
+		return nil, nil, status, errors.New(msg)
+	}
+	target, err := resolveUser(request.User)
+	if err != nil {
+		return nil, nil, http.StatusNotFound, fmt.Errorf("user %s not found", request.User)
+	}
+	return stand, target, http.StatusOK, nil
+}
+
+// resolveUser находит пользователя по ID или имени
+func resolveUser(ref string) (*models.User, error) {
+	if id, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		return database.GetUserByID(uint(id))
+	}
+	return database.GetUserByName(ref)
+}
@@ -0,0 +1,251 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/rbac"
+	"gitlab-orchestrator-back/internal/scheduler"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// FleetRequest тело запроса на массовое обновление продукта
+type FleetRequest struct {
+	UserID      int64             `json:"userID"`
+	Product     string            `json:"product"`
+	Tag         string            `json:"tag"`
+	OwnerID     uint              `json:"ownerID"`
+	Type        string            `json:"type"`
+	Labels      map[string]string `json:"labels"`
+	BatchSize   int               `json:"batchSize"`
+	MaxFailures int               `json:"maxFailures"`
+	DryRun      bool              `json:"dryRun"` // Только показать подходящие стенды
+}
+
+// FleetOperationStatus массовая операция со сводкой по стендам
+type FleetOperationStatus struct {
+	models.FleetOperation
+	Progress scheduler.FleetProgress `json:"progress"`
+}
+
+func newFleetOperationStatus(operation models.FleetOperation) FleetOperationStatus {
+	return FleetOperationStatus{FleetOperation: operation, Progress: scheduler.Progress(operation.Targets)}
+}
+
+// CreateFleetOperation обработчик для запуска массового обновления продукта
+// @Summary Запустить массовое обновление
+// @Description Выбирает стенды по продукту, владельцу, типу и меткам и обновляет на них продукт пачками по batchSize. При maxFailures ошибок операция останавливается
+// @Tags fleet
+// @Accept json
+// @Produce json
+// @Success 200 {object} FleetOperationStatus
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /fleet [post]
+func (h *Handler) CreateFleetOperation(c echo.Context) error {
+	var request FleetRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	if request.Product == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "product is required"})
+	}
+	if request.BatchSize <= 0 {
+		request.BatchSize = config.Config.FleetBatchSize
+	}
+	if request.MaxFailures < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "maxFailures cannot be negative"})
+	}
+
+	filter := models.FleetFilter{
+		Product: request.Product,
+		OwnerID: request.OwnerID,
+		Type:    request.Type,
+		Labels:  request.Labels,
+	}
+	stands, err := database.FindFleetStands(filter, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if len(stands) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "no stands match the filter"})
+	}
+
+	filterJSON, err := json.Marshal(filter)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	operation := models.FleetOperation{
+		UserID:      uint(request.UserID),
+		Product:     request.Product,
+		Tag:         request.Tag,
+		Filter:      filterJSON,
+		BatchSize:   request.BatchSize,
+		MaxFailures: request.MaxFailures,
+		Status:      scheduler.FleetStatusRunning,
+	}
+
+	if request.DryRun {
+		for i, stand := range stands {
+			operation.Targets = append(operation.Targets, models.FleetTarget{
+				StandID:   stand.ID,
+				StandName: stand.Name,
+				Batch:     i/operation.BatchSize + 1,
+				Status:    StatusPending,
+			})
+		}
+		return c.JSON(http.StatusOK, newFleetOperationStatus(operation))
+	}
+
+	tx := database.DB.Begin()
+	if err := database.CreateFleetOperation(&operation, stands, tx); err != nil {
+		tx.Rollback()
+		logger.ErrorfWithCaller("Ошибка при создании массовой операции: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Запущена массовая операция %d: %s:%s на %d стендах пачками по %d",
+		operation.ID, operation.Product, operation.Tag, len(stands), operation.BatchSize)
+	return c.JSON(http.StatusOK, newFleetOperationStatus(operation))
+}
+
+// GetFleetOperations обработчик для получения массовых операций
+// @Summary Получить массовые операции
+// @Description Возвращает массовые операции со стендами и сводкой, начиная с последней
+// @Tags fleet
+// @Accept json
+// @Produce json
+// @Success 200 {array} FleetOperationStatus
+// @Failure 500 {object} map[string]string
+// @Router /fleet [get]
+func (h *Handler) GetFleetOperations(c echo.Context) error {
+	operations, err := database.GetFleetOperations(database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	result := make([]FleetOperationStatus, 0, len(operations))
+	for _, operation := range operations {
+		result = append(result, newFleetOperationStatus(operation))
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetFleetOperation обработчик для получения хода массовой операции
+// @Summary Получить массовую операцию
+// @Description Возвращает массовую операцию со статусами стендов и сводкой
+// @Tags fleet
+// @Accept json
+// @Produce json
+// @Param id path int true "ID операции"
+// @Success 200 {object} FleetOperationStatus
+// @Failure 404 {object} map[string]string
+// @Router /fleet/{id} [get]
+func (h *Handler) GetFleetOperation(c echo.Context) error {
+	operationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid operation ID"})
+	}
+	operation, err := database.GetFleetOperationByID(uint(operationID), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, newFleetOperationStatus(*operation))
+}
+
+// ControlFleetOperation обработчик для приостановки, возобновления и прерывания массовой операции
+// @Summary Управлять массовой операцией
+// @Description action: pause — не запускать новые пачки, resume — продолжить, abort — отменить незапущенные стенды. Уже запущенные пайплайны доходят до конца
+// @Tags fleet
+// @Accept json
+// @Produce json
+// @Param id path int true "ID операции"
+// @Param action path string true "pause, resume или abort"
+// @Success 200 {object} FleetOperationStatus
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /fleet/{id}/{action} [post]
+func (h *Handler) ControlFleetOperation(c echo.Context) error {
+	var request struct {
+		UserID int64 `json:"userID"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	operationID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid operation ID"})
+	}
+	operation, err := database.GetFleetOperationByID(uint(operationID), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+
+	action := c.Param("action")
+	var from, to string
+	switch action {
+	case "pause":
+		from, to = scheduler.FleetStatusRunning, scheduler.FleetStatusPaused
+	case "resume":
+		from, to = scheduler.FleetStatusPaused, scheduler.FleetStatusRunning
+	case "abort":
+		from, to = "", scheduler.FleetStatusAborted
+	default:
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "action must be pause, resume or abort"})
+	}
+	finished := operation.Status != scheduler.FleetStatusRunning && operation.Status != scheduler.FleetStatusPaused
+	if finished || (from != "" && operation.Status != from) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("operation is %s, cannot %s", operation.Status, action)})
+	}
+
+	tx := database.DB.Begin()
+	updates := map[string]interface{}{"status": to}
+	if action == "abort" {
+		updates["finished_at"] = time.Now()
+		updates["message"] = fmt.Sprintf("Прервано пользователем %d", request.UserID)
+		if err := tx.Model(&models.FleetTarget{}).
+			Where("operation_id = ? AND status = ?", operation.ID, StatusPending).
+			Updates(map[string]interface{}{"status": scheduler.TargetStatusCanceled, "finished_at": time.Now()}).Error; err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+	if err := tx.Model(operation).Updates(updates).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Массовая операция %d: %s пользователем %d", operation.ID, action, request.UserID)
+	operation, err = database.GetFleetOperationByID(operation.ID, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, newFleetOperationStatus(*operation))
+}
+
+// checkPermission разрешает действие пользователям, роли которых дают право permission
+func checkPermission(userID int64, permission string) (int, string) {
+	user, err := database.GetUserByID(uint(userID))
+	if err != nil || !rbac.Can(*user, permission) {
+		return http.StatusForbidden, fmt.Sprintf("%s permission is required", permission)
+	}
+	return http.StatusOK, ""
+}
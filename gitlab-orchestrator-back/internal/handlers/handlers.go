@@ -1,21 +1,40 @@
 package handlers
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/admission"
 	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/gitlab"
 	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/rbac"
+	"gitlab-orchestrator-back/internal/registry"
+	"gitlab-orchestrator-back/internal/upgrade"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
 )
 
 const (
-	StatusProcess = "in_process"
-	StatusPending = "pending"
+	StatusProcess          = "in_process"
+	StatusPending          = "pending"
+	StatusSuccess          = "success"
+	StatusError            = "error"
+	StatusCreated          = "created"
+	StatusAwaitingApproval = "awaiting_approval"
+	StatusRejected         = "rejected"
 )
 
 type Handler struct {
+	Gitlab   gitlab.Gitlab
+	Registry registry.TagLister // Реестр образов для проверки версий продуктов, nil — версии не проверяются
 }
 
 // GetNotification обработчик для получения первого неотправленного уведомления
@@ -80,13 +99,14 @@ func (h *Handler) UpdateNotification(c echo.Context) error {
 
 // GetAllUsers обработчик для получения всех пользователей
 // @Summary Получить всех пользователей
-// @Description Получает список всех пользователей из базы данных
+// @Description Получает список всех пользователей из базы данных. Доступно пользователям с правом users:manage, а по /auth/users — ключу бота для списка допущенных пользователей
 // @Tags users
 // @Accept json
 // @Produce json
 // @Success 200 {array} models.User
 // @Failure 500 {object} map[string]string
 // @Router /users [get]
+// @Router /auth/users [get]
 func (h *Handler) GetAllUsers(c echo.Context) error {
 	logger.InfoWithCaller("Запрос на получение списка пользователей")
 
@@ -152,13 +172,30 @@ func (h *Handler) GetAllSubos(c echo.Context) error {
 	return c.JSON(http.StatusOK, subos)
 }
 
-func (h *Handler) CreateStand(c echo.Context) error {
-	var request struct {
-		NameStand string   `json:"nameStand"`
-		Products  []string `json:"products"`
-		UserID    int64    `json:"userID"`
-		Ref       string   `json:"ref"`
+// GetProductVersions обработчик для получения доступных версий продукта
+// @Summary Получить версии продукта
+// @Description Возвращает теги образа продукта из реестра. Если реестр не настроен, возвращает пустой список
+// @Tags subos
+// @Accept json
+// @Produce json
+// @Param code path string true "Код продукта"
+// @Success 200 {array} string
+// @Failure 502 {object} map[string]string
+// @Router /subos/{code}/versions [get]
+func (h *Handler) GetProductVersions(c echo.Context) error {
+	if h.Registry == nil {
+		return c.JSON(http.StatusOK, []string{})
+	}
+	tags, err := h.Registry.ListTags(c.Param("code"))
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении версий продукта %s: %v", c.Param("code"), err)
+		return c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
 	}
+	return c.JSON(http.StatusOK, tags)
+}
+
+func (h *Handler) CreateStand(c echo.Context) error {
+	var request internal.StandRequest
 	tx := *database.DB.Begin()
 
 	if err := c.Bind(&request); err != nil {
@@ -166,26 +203,55 @@ func (h *Handler) CreateStand(c echo.Context) error {
 		tx.Rollback()
 		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
 	}
+	request.UserID = actingUser(c, request.UserID)
 
-	standModel, err := internal.PopulateStand(request)
-	if err != nil {
-		logger.ErrorfWithCaller("Ошибка при заполнении модели стенда: %v", err)
+	if err := registry.ValidateVersions(h.Registry, request.Products); err != nil {
+		logger.ErrorfWithCaller("Некорректные версии продуктов стенда %s: %v", request.NameStand, err)
+		tx.Rollback()
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	needApproval, status, message := admission.Check(&request)
+	if status != http.StatusOK {
 		tx.Rollback()
-		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to create stand"})
+		return c.JSON(status, map[string]string{"error": message})
+	}
+	if !needApproval {
+		// Подходящий готовый стенд из пула выдается сразу, без подготовки ВМ
+		warmStand, pipeline, err := h.claimWarmStand(request, &tx)
+		if err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+		}
+		if warmStand != nil {
+			if err := upgrade.Commit(h.Gitlab, pipeline, &tx); err != nil {
+				return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+			}
+			// Готовый стенд сохраняет свое имя, запрошенное имя не используется
+			message := fmt.Sprintf("Готовый стенд %s выдан вместо стенда %s, на него устанавливаются продукты", warmStand.Name, request.NameStand)
+			return c.JSON(http.StatusOK, map[string]string{"message": message, "nameStand": warmStand.Name})
+		}
 	}
 
-	// Create the stand and get the created instance with ID
-	if err = database.CreateStand(standModel, &tx); err != nil {
-		logger.ErrorfWithCaller("Ошибка при создании стенда: %v", err)
+	if _, err := admission.Create(request, needApproval, &tx); err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании стенда %s: %v", request.NameStand, err)
 		tx.Rollback()
 		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
 	}
+
+	if needApproval {
+		tx.Commit()
+
+		message := fmt.Sprintf("Стенд %s отправлен на согласование администратору", request.NameStand)
+		logger.InfofWithCaller("Stand awaiting approval: %s", request.NameStand)
+		return c.JSON(http.StatusOK, map[string]string{"message": message, "nameStand": request.NameStand})
+	}
 	tx.Commit()
 
-	message := fmt.Sprintf("Стенд %s добавлен в очередь на создание", request.NameStand)
+	message = fmt.Sprintf("Стенд %s добавлен в очередь на создание", request.NameStand)
 	logger.InfofWithCaller("Stand creation queued: %s", request.NameStand)
 
-	return c.JSON(http.StatusOK, map[string]string{"message": message})
+	return c.JSON(http.StatusOK, map[string]string{"message": message, "nameStand": request.NameStand})
 }
 
 // GetAllStands обработчик для получения всех стендов
@@ -204,3 +270,858 @@ func (h *Handler) GetAllStands(c echo.Context) error {
 	}
 	return c.JSON(http.StatusOK, stands)
 }
+
+// GetOrphans обработчик для получения осиротевших ресурсов GitLab
+// @Summary Получить осиротевшие ресурсы GitLab
+// @Description Возвращает ветки, окружения и переменные, названные по схеме стенда, для которых нет стенда
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param all query bool false "Включить уже удаленные ресурсы"
+// @Success 200 {array} models.OrphanResource
+// @Failure 500 {object} map[string]string
+// @Router /admin/orphans [get]
+func (h *Handler) GetOrphans(c echo.Context) error {
+	includeRemoved := c.QueryParam("all") == "true"
+
+	orphans, err := database.GetOrphanResources(includeRemoved, database.DB)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении осиротевших ресурсов: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	return c.JSON(http.StatusOK, orphans)
+}
+
+// ImportStand обработчик для переноса существующего окружения GitLab под управление оркестратора
+// @Summary Импортировать стенд из GitLab
+// @Description Создает стенд, пайплайн, шаги и джобы по существующей ветке и окружению GitLab
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.Stand
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 500 {object} map[string]string
+// @Router /stands/import [post]
+func (h *Handler) ImportStand(c echo.Context) error {
+	var request struct {
+		NameStand string `json:"nameStand"`
+		UserID    int64  `json:"userID"`
+		Ref       string `json:"ref"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	if request.NameStand == "" || request.UserID == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "nameStand and userID are required"})
+	}
+	if request.Ref == "" {
+		request.Ref = "master"
+	}
+
+	if _, err := database.GetUserByID(uint(request.UserID)); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("owner: %v", err)})
+	}
+	if _, err := database.GetStandByName(request.NameStand, database.DB); err == nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "stand with this name already exists"})
+	}
+
+	existBranch, err := h.Gitlab.CheckBranchExist(request.NameStand)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	existEnv, err := h.Gitlab.CheckEnvironmentExist(request.NameStand)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !existBranch || !existEnv {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "branch or environment not found in GitLab"})
+	}
+
+	variables, err := h.Gitlab.GetVariablesFromEnvironment(request.NameStand)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	standModel, err := internal.PopulateStand(internal.StandRequest{
+		NameStand: request.NameStand,
+		Products:  internal.ParseProducts(variables),
+		UserID:    request.UserID,
+		Ref:       request.Ref,
+	})
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при заполнении модели стенда: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": "Failed to import stand"})
+	}
+	// Без пайплайна стенду нечего выполнять
+	standModel.Status = StatusSuccess
+
+	var pipelineModel *models.Pipeline
+	var jobs []models.Job
+	gitlabPipeline, err := h.Gitlab.GetLatestPipeline(request.NameStand)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if gitlabPipeline.ID != 0 {
+		jobs, err = h.Gitlab.GetJobsFromPipeline(gitlabPipeline.ID)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		pipelineModel = &models.Pipeline{
+			Name:             request.NameStand,
+			Status:           StatusPending,
+			GitlabPipelineID: gitlabPipeline.ID,
+			Ref:              gitlabPipeline.Ref,
+			StartedAt:        gitlabPipeline.StartedAt,
+			FinishedAt:       gitlabPipeline.FinishedAt,
+		}
+	}
+
+	tx := database.DB.Begin()
+	if err := database.ImportStand(&standModel, pipelineModel, jobs, tx); err != nil {
+		logger.ErrorfWithCaller("Ошибка при импорте стенда %s: %v", request.NameStand, err)
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Стенд %s импортирован из GitLab со статусом %s", standModel.Name, standModel.Status)
+	return c.JSON(http.StatusOK, standModel)
+}
+
+// checkOwnerAccess разрешает действие над ресурсом владельцу и пользователям с правом permission
+func checkOwnerAccess(userID int64, ownerID uint, permission string) (int, string) {
+	if uint(userID) == ownerID {
+		return http.StatusOK, ""
+	}
+	user, err := database.GetUserByID(uint(userID))
+	if err != nil || !rbac.Can(*user, permission) {
+		return http.StatusForbidden, fmt.Sprintf("only the owner or a user with %s permission can do this", permission)
+	}
+	return http.StatusOK, ""
+}
+
+// checkStandAccess разрешает действие над стендом, если у пользователя есть право permission на стенд
+func checkStandAccess(userID int64, stand models.Stand, permission string) (int, string) {
+	user, err := database.GetUserByID(uint(userID))
+	if err != nil {
+		return http.StatusForbidden, "user not found"
+	}
+	granted, err := standPermission(*user, stand)
+	if err != nil {
+		return http.StatusInternalServerError, err.Error()
+	}
+	if granted == "" || !models.PermissionAllows(granted, permission) {
+		return http.StatusForbidden, fmt.Sprintf("%s permission on stand %s is required", permission, stand.Name)
+	}
+	return http.StatusOK, ""
+}
+
+// standPermission возвращает право пользователя на стенд: автор и пользователи с правом stand:admin:any управляют
+// стендом полностью, владельцы команды стенда получают admin, участники команды и пользователи с правом
+// stand:operate:any — operate, соавторы — выданное им право
+func standPermission(user models.User, stand models.Stand) (string, error) {
+	if stand.UserID == uint(user.ID) || rbac.Can(user, rbac.StandAdminAny) {
+		return models.PermissionAdmin, nil
+	}
+
+	permission := ""
+	if rbac.Can(user, rbac.StandOperateAny) {
+		permission = models.PermissionOperate
+	}
+	if stand.TeamID != nil {
+		team, err := database.GetTeamByID(*stand.TeamID, database.DB)
+		if err != nil {
+			return "", err
+		}
+		if member, exist := team.Member(uint(user.ID)); exist {
+			if member.Role == models.TeamRoleOwner {
+				return models.PermissionAdmin, nil
+			}
+			permission = models.PermissionOperate
+		}
+	}
+
+	collaborator, err := database.GetStandCollaborator(stand.ID, uint(user.ID), database.DB)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return permission, nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if models.PermissionAllows(collaborator.Permission, permission) {
+		permission = collaborator.Permission
+	}
+	return permission, nil
+}
+
+// GetStandApprovals обработчик для получения стендов, ожидающих согласования
+// @Summary Получить стенды на согласовании
+// @Description Возвращает стенды в статусе awaiting_approval
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Success 200 {array} models.Stand
+// @Failure 500 {object} map[string]string
+// @Router /stands/approvals [get]
+func (h *Handler) GetStandApprovals(c echo.Context) error {
+	stands, err := database.GetStandsByStatus(StatusAwaitingApproval, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, stands)
+}
+
+// ApproveStand обработчик для согласования стенда администратором
+// @Summary Согласовать стенд
+// @Description Переводит стенд из awaiting_approval в очередь на создание и уведомляет автора
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stands/{name}/approve [post]
+func (h *Handler) ApproveStand(c echo.Context) error {
+	return h.decideStand(c, true)
+}
+
+// RejectStand обработчик для отклонения стенда администратором
+// @Summary Отклонить стенд
+// @Description Переводит стенд из awaiting_approval в rejected и уведомляет автора с причиной
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stands/{name}/reject [post]
+func (h *Handler) RejectStand(c echo.Context) error {
+	return h.decideStand(c, false)
+}
+
+func (h *Handler) decideStand(c echo.Context, approve bool) error {
+	var request struct {
+		AdminID int64  `json:"adminID"`
+		Reason  string `json:"reason"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.AdminID = actingUser(c, request.AdminID)
+
+	admin, err := database.GetUserByID(uint(request.AdminID))
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "user not found"})
+	}
+
+	tx := database.DB.Begin()
+	stand, err := database.GetStandByName(c.Param("name"), tx)
+	if err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if stand.Status != StatusAwaitingApproval {
+		tx.Rollback()
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("stand is %s, not awaiting approval", stand.Status)})
+	}
+
+	status, notifyStatus, decision := StatusRejected, StatusError, "отклонен"
+	if approve {
+		// Только согласованный стенд попадает в планировщик
+		status, notifyStatus, decision = StatusCreated, StatusSuccess, "согласован"
+	}
+
+	if err := tx.Model(stand).Updates(map[string]interface{}{
+		"status":          status,
+		"decided_by":      uint(admin.ID),
+		"decision_reason": request.Reason,
+	}).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	message := fmt.Sprintf("Стенд %s %s администратором %s", stand.Name, decision, admin.Name)
+	if request.Reason != "" {
+		message += "\nПричина: " + request.Reason
+	}
+	if err := database.CreateStandMembersNotify(*stand, models.StepState{
+		StandName: stand.Name,
+		StepName:  "Согласование",
+		Status:    notifyStatus,
+		Kind:      models.NotifyKindApprovalResult,
+		Message:   message,
+	}, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Стенд %s %s администратором %d", stand.Name, decision, admin.ID)
+	return c.JSON(http.StatusOK, map[string]string{"message": message})
+}
+
+// DecideJob обработчик решения по manual-джобе, требующей согласования
+// @Summary Принять решение по джобе
+// @Description Запускает, пропускает или останавливает manual-джобу, на которой приостановлен шаг стенда. Решение может принять владелец стенда или администратор
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Param id path int true "ID джобы"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /jobs/{id}/decision [post]
+func (h *Handler) DecideJob(c echo.Context) error {
+	var request struct {
+		UserID   int64  `json:"userID"`
+		Decision string `json:"decision"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+
+	jobStatus := map[string]string{
+		models.JobDecisionApprove: "manual",
+		models.JobDecisionSkip:    "skipped",
+		models.JobDecisionAbort:   "canceled",
+	}
+	newStatus, ok := jobStatus[request.Decision]
+	if !ok {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "decision must be approve, skip or abort"})
+	}
+
+	jobID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid job ID"})
+	}
+
+	job, err := database.GetJobByID(uint(jobID))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	step, err := database.GetStepByID(job.StepID, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	stand, err := database.GetStandByPipelineID(step.PipelineID, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	user, err := database.GetUserByID(uint(request.UserID))
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "only the stand owner, its team or an admin can decide on this job"})
+	}
+	if status, msg := checkStandAccess(request.UserID, *stand, models.PermissionOperate); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+	if !job.RequiresApproval || job.Decision != "" || step.Status != StatusAwaitingApproval {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("job %s is not awaiting a decision", job.Name)})
+	}
+
+	pipeline, err := database.GetPipelineByID(step.PipelineID, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if request.Decision == models.JobDecisionAbort {
+		if err := h.Gitlab.CancelPipeline(pipeline.GitlabPipelineID); err != nil {
+			logger.ErrorfWithCaller("Ошибка при отмене пайплайна %d в GitLab: %v", pipeline.GitlabPipelineID, err)
+			return c.JSON(http.StatusBadGateway, map[string]string{"error": err.Error()})
+		}
+	}
+
+	now := time.Now()
+	tx := database.DB.Begin()
+	if err := tx.Model(job).Updates(map[string]interface{}{
+		"status":     newStatus,
+		"decision":   request.Decision,
+		"decided_by": uint(user.ID),
+		"decided_at": &now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	// Возвращаем шаг в очередь планировщика: он запустит джобу, пропустит ее или завершит стенд с ошибкой
+	if err := database.UpdateStepStatus(StatusPending, step, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := database.UpdatePipelineStatus(StatusPending, pipeline, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := database.UpdateStandStatus(StatusPending, stand, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	message := fmt.Sprintf("Решение по джобе %s стенда %s: %s (%s)", job.Name, stand.Name, request.Decision, user.Name)
+	if stand.UserID != uint(user.ID) {
+		if err := database.CreateNotify(models.StepState{
+			StandName: stand.Name,
+			StepName:  step.Name,
+			UserID:    stand.UserID,
+			Status:    StatusSuccess,
+			Kind:      models.NotifyKindApprovalResult,
+			JobID:     job.ID,
+			Message:   message,
+		}, tx); err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Джоба %d стенда %s: решение %s принял пользователь %d", job.ID, stand.Name, request.Decision, user.ID)
+	return c.JSON(http.StatusOK, map[string]string{"message": message})
+}
+
+// GetStepPlan обработчик для получения плана terraform шага
+// @Summary Получить план terraform шага
+// @Description Возвращает сводку изменений по типам ресурсов и полный вывод terraform plan
+// @Tags steps
+// @Accept json
+// @Produce json
+// @Param id path int true "ID шага"
+// @Success 200 {object} map[string]interface{}
+// @Failure 400 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /steps/{id}/plan [get]
+func (h *Handler) GetStepPlan(c echo.Context) error {
+	stepID, err := strconv.ParseUint(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid step ID"})
+	}
+
+	step, err := database.GetStepByID(uint(stepID), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if len(step.PlanSummary) == 0 {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "step has no terraform plan"})
+	}
+
+	return c.JSON(http.StatusOK, map[string]interface{}{
+		"step":    step.Name,
+		"summary": step.PlanSummary,
+		"plan":    step.PlanRaw,
+	})
+}
+
+// RebuildRequest тело запроса на пересоздание или повторную подготовку стенда
+type RebuildRequest struct {
+	UserID int64    `json:"userID"`
+	Ref    string   `json:"ref"`
+	Stages []string `json:"stages"` // Stage из шаблона шагов, пусто — все
+}
+
+// RebuildStand обработчик для пересоздания стенда с новым пайплайном
+// @Summary Пересоздать стенд
+// @Description Заново готовит завершенный стенд, при необходимости из новой ветки и только для выбранных stage. Ветка стенда создается заново из ref. Создается новый пайплайн с шагами и джобами, прошлые пайплайны остаются в истории
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stands/{name}/rebuild [post]
+func (h *Handler) RebuildStand(c echo.Context) error {
+	return h.requeueStand(c, true)
+}
+
+// RetryStand обработчик для повторного запуска пайплайна стенда
+// @Summary Повторить пайплайн стенда
+// @Description Запускает новый пайплайн стенда на существующей ветке, например после исправления конфигурации в ней. Можно выполнить только выбранные stage
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stands/{name}/retry [post]
+func (h *Handler) RetryStand(c echo.Context) error {
+	return h.requeueStand(c, false)
+}
+
+// requeueStand ставит завершенный стенд на повторную подготовку. При rebuild ветка стенда удаляется
+// и создается заново из ref, иначе используется существующая ветка
+func (h *Handler) requeueStand(c echo.Context, rebuild bool) error {
+	var request RebuildRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	if err := internal.ValidateStages(request.Stages); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if status, msg := checkStandAccess(request.UserID, *stand, models.PermissionOperate); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+	if !internal.CanRebuild(stand.Status) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("stand is %s, wait until it finishes", stand.Status)})
+	}
+
+	action := "повторную подготовку"
+	if rebuild {
+		action = "пересоздание"
+	}
+	if status, msg := checkStandBooking(request.UserID, *stand, action); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+
+	ref := stand.Ref
+	if rebuild {
+		if request.Ref != "" {
+			ref = request.Ref
+		}
+	}
+
+	// Ветку пересоздает подготовка стенда, чтобы отказ транзакции не оставил стенд без ветки
+	tx := database.DB.Begin()
+	if err := database.RebuildStand(stand, nil, ref, request.Stages, rebuild, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	stages := "все stage"
+	if len(request.Stages) > 0 {
+		stages = "stage " + strings.Join(request.Stages, ", ")
+	}
+	message := fmt.Sprintf("Стенд %s поставлен в очередь на повторный запуск (%s)", stand.Name, stages)
+	if rebuild {
+		message = fmt.Sprintf("Стенд %s поставлен в очередь на пересоздание из %s (%s)", stand.Name, ref, stages)
+	}
+	logger.InfofWithCaller("Стенд %s поставлен на повторную подготовку пользователем %d: ref %s, %s", stand.Name, request.UserID, ref, stages)
+	return c.JSON(http.StatusOK, map[string]string{"message": message})
+}
+
+// PipelineHistory пайплайн стенда в истории пересозданий
+type PipelineHistory struct {
+	ID               uint         `json:"id"`
+	Current          bool         `json:"current"`
+	Status           string       `json:"status"`
+	Ref              string       `json:"ref"`
+	GitlabPipelineID int          `json:"gitlab_pipeline_id"`
+	CreatedAt        time.Time    `json:"created_at"`
+	StartedAt        *time.Time   `json:"started_at"`
+	FinishedAt       *time.Time   `json:"finished_at"`
+	Steps            []StepStatus `json:"steps"`
+}
+
+// StepStatus краткое состояние шага пайплайна
+type StepStatus struct {
+	ID     uint   `json:"id"`
+	Name   string `json:"name"`
+	Order  int    `json:"order"`
+	Status string `json:"status"`
+}
+
+// GetStandPipelines обработчик для получения истории пайплайнов стенда
+// @Summary Получить историю пайплайнов стенда
+// @Description Возвращает пайплайны стенда с шагами, начиная с последнего
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {array} PipelineHistory
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/pipelines [get]
+func (h *Handler) GetStandPipelines(c echo.Context) error {
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+
+	pipelines, err := database.GetStandPipelines(stand.ID, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	history := make([]PipelineHistory, 0, len(pipelines))
+	for _, pipeline := range pipelines {
+		item := PipelineHistory{
+			ID:               pipeline.ID,
+			Current:          pipeline.ID == stand.CurrentPipelineID,
+			Status:           pipeline.Status,
+			Ref:              pipeline.Ref,
+			GitlabPipelineID: pipeline.GitlabPipelineID,
+			CreatedAt:        pipeline.CreatedAt,
+			StartedAt:        pipeline.StartedAt,
+			FinishedAt:       pipeline.FinishedAt,
+		}
+		for _, step := range pipeline.Steps {
+			item.Steps = append(item.Steps, StepStatus{ID: step.ID, Name: step.Name, Order: step.Order, Status: step.Status})
+		}
+		history = append(history, item)
+	}
+	return c.JSON(http.StatusOK, history)
+}
+
+// UpgradeProduct обработчик для обновления одного продукта на стенде
+// @Summary Обновить продукт на стенде
+// @Description Запускает в GitLab пайплайн, выполняющий только stage helm для продукта code, при необходимости с указанным тегом образа
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Param code path string true "Код продукта"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stands/{name}/products/{code}/upgrade [post]
+func (h *Handler) UpgradeProduct(c echo.Context) error {
+	var request struct {
+		UserID int64  `json:"userID"`
+		Tag    string `json:"tag"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	code := c.Param("code")
+
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if status, msg := checkStandAccess(request.UserID, *stand, models.PermissionOperate); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+	if stand.Status != StatusSuccess && stand.Status != StatusError {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("stand is %s, only ready stands can be upgraded", stand.Status)})
+	}
+	if status, msg := checkStandBooking(request.UserID, *stand, "обновление продукта "+code); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+	products, err := database.GetProductsFromStand(stand.Name, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if !containsProduct(products, code) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": fmt.Sprintf("product %s is not deployed on stand %s", code, stand.Name)})
+	}
+
+	status, err := h.startUpgrade(stand, code, request.Tag)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	image := internal.DeploymentImage(code, request.Tag)
+	logger.InfofWithCaller("Запущено обновление %s на стенде %s пользователем %d", image, stand.Name, request.UserID)
+	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Обновление %s на стенде %s запущено", image, stand.Name)})
+}
+
+// RollbackProduct обработчик для отката продукта на стенде к предыдущей версии
+// @Summary Откатить продукт на стенде
+// @Description Запускает пайплайн обновления продукта code до предыдущего развернутого образа. После неудачного обновления — до последнего успешно развернутого
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Param code path string true "Код продукта"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stands/{name}/products/{code}/rollback [post]
+func (h *Handler) RollbackProduct(c echo.Context) error {
+	var request struct {
+		UserID int64 `json:"userID"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	code := c.Param("code")
+
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if status, msg := checkStandAccess(request.UserID, *stand, models.PermissionOperate); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+	if stand.Status != StatusSuccess && stand.Status != StatusError {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("stand is %s, only ready stands can be rolled back", stand.Status)})
+	}
+	if status, msg := checkStandBooking(request.UserID, *stand, "откат продукта "+code); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+
+	image, err := database.GetRollbackImage(stand, code, database.DB)
+	if errors.Is(err, database.ErrNoPreviousDeployment) {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("product %s has no previous version on stand %s", code, stand.Name)})
+	}
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	tag := strings.TrimPrefix(image, code+":")
+
+	status, err := h.startUpgrade(stand, code, tag)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Запущен откат %s на стенде %s до %s пользователем %d", code, stand.Name, image, request.UserID)
+	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Откат %s на стенде %s до %s запущен", code, stand.Name, image)})
+}
+
+// GetProductHistory обработчик для получения истории версий продукта на стенде
+// @Summary Получить историю версий продукта
+// @Description Возвращает образы продукта, развернутые на стенде, начиная с последнего
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Param code path string true "Код продукта"
+// @Success 200 {array} models.DeploymentHistory
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/products/{code}/history [get]
+func (h *Handler) GetProductHistory(c echo.Context) error {
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	history, err := database.GetDeploymentHistory(stand.ID, c.Param("code"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, history)
+}
+
+// startUpgrade запускает обновление продукта code до тега tag и возвращает HTTP-статус для ответа при ошибке
+func (h *Handler) startUpgrade(stand *models.Stand, code string, tag string) (int, error) {
+	if _, err := upgrade.Start(h.Gitlab, stand, code, tag); err != nil {
+		if errors.Is(err, upgrade.ErrRunPipeline) {
+			return http.StatusBadGateway, err
+		}
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+func containsProduct(products []string, code string) bool {
+	for _, product := range products {
+		if product == code {
+			return true
+		}
+	}
+	return false
+}
+
+// UpdateStandLabels обработчик для изменения типа и меток стенда
+// @Summary Изменить тип и метки стенда
+// @Description Задает тип стенда и заменяет его метки, по которым стенды выбираются для массовых операций
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/labels [put]
+func (h *Handler) UpdateStandLabels(c echo.Context) error {
+	var request struct {
+		UserID int64             `json:"userID"`
+		Type   *string           `json:"type"`
+		Labels map[string]string `json:"labels"`
+	}
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if status, msg := checkStandAccess(request.UserID, *stand, models.PermissionOperate); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+
+	updates := map[string]interface{}{}
+	if request.Type != nil {
+		updates["type"] = *request.Type
+	}
+	if request.Labels != nil {
+		labelsJSON, err := json.Marshal(request.Labels)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		updates["labels"] = labelsJSON
+	}
+	if len(updates) > 0 {
+		if err := database.DB.Model(stand).Updates(updates).Error; err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+
+	logger.InfofWithCaller("Тип и метки стенда %s изменены пользователем %d", stand.Name, request.UserID)
+	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Тип и метки стенда %s обновлены", stand.Name)})
+}
+
+// GetStandDeployments обработчик для получения развернутых версий продуктов стенда
+// @Summary Получить версии продуктов стенда
+// @Description Возвращает развернутые на стенде образы продуктов: код продукта -> образ
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /stands/{name}/deployments [get]
+func (h *Handler) GetStandDeployments(c echo.Context) error {
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+
+	deployments := make(map[string]string)
+	if len(stand.Deployments) > 0 {
+		if err := json.Unmarshal(stand.Deployments, &deployments); err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+	return c.JSON(http.StatusOK, deployments)
+}
@@ -0,0 +1,135 @@
+package handlers
+
+import (
+	"errors"
+	"gitlab-orchestrator-back/internal/capacity"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// PoolRequest тело запроса на создание или изменение пула ресурсов
+type PoolRequest struct {
+	AdminID  int64  `json:"adminID"`
+	Region   string `json:"region"`
+	MaxVMs   int    `json:"maxVMs"`   // 0 — без ограничения
+	MaxVCPU  int    `json:"maxVCPU"`  // 0 — без ограничения
+	MaxRAMGB int    `json:"maxRAMGB"` // 0 — без ограничения
+	Disabled bool   `json:"disabled"`
+}
+
+// QueuedStand стенд, ожидающий ресурсов для подготовки
+type QueuedStand struct {
+	Name   string           `json:"name"`
+	Type   string           `json:"type"`
+	Region string           `json:"region"`
+	Cost   config.StandCost `json:"cost"`
+	Reason string           `json:"reason"`
+}
+
+// PoolsResponse занятость пулов ресурсов и очередь стендов
+type PoolsResponse struct {
+	Pools  []capacity.PoolUsage        `json:"pools"`
+	Costs  map[string]config.StandCost `json:"costs"`
+	Queued []QueuedStand               `json:"queued"`
+}
+
+// GetResourcePools обработчик для получения занятости пулов ресурсов
+// @Summary Получить занятость пулов ресурсов
+// @Description Возвращает пулы с лимитами и занятыми ресурсами, стоимость стендов по типам и стенды, ожидающие ресурсов
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} PoolsResponse
+// @Failure 500 {object} map[string]string
+// @Router /admin/pools [get]
+func (h *Handler) GetResourcePools(c echo.Context) error {
+	usage, err := capacity.Usage(database.DB)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении занятости пулов ресурсов: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	stands, err := database.GetQueuedStands(database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	queued := make([]QueuedStand, 0, len(stands))
+	for _, stand := range stands {
+		queued = append(queued, QueuedStand{
+			Name:   stand.Name,
+			Type:   stand.Type,
+			Region: stand.Region,
+			Cost:   capacity.Cost(stand.Type),
+			Reason: stand.QueueReason,
+		})
+	}
+	return c.JSON(http.StatusOK, PoolsResponse{Pools: usage, Costs: config.Config.StandCosts, Queued: queued})
+}
+
+// SaveResourcePool обработчик для создания или изменения пула ресурсов
+// @Summary Создать или изменить пул ресурсов
+// @Description Задает регион и лимиты пула. Уменьшение лимитов не затрагивает уже размещенные стенды, в отключенный пул новые стенды не размещаются
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя пула"
+// @Success 200 {object} models.ResourcePool
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /admin/pools/{name} [put]
+func (h *Handler) SaveResourcePool(c echo.Context) error {
+	var request PoolRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.AdminID = actingUser(c, request.AdminID)
+	if request.Region == "" {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "region is required"})
+	}
+	if request.MaxVMs < 0 || request.MaxVCPU < 0 || request.MaxRAMGB < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "limits must not be negative"})
+	}
+
+	name := c.Param("name")
+	pool, err := database.GetResourcePoolByName(name, database.DB)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		pool = &models.ResourcePool{Name: name}
+	} else if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	pool.Region = request.Region
+	pool.MaxVMs = request.MaxVMs
+	pool.MaxVCPU = request.MaxVCPU
+	pool.MaxRAMGB = request.MaxRAMGB
+	pool.Disabled = request.Disabled
+
+	if err := database.SaveResourcePool(pool, database.DB); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	logger.InfofWithCaller("Пул ресурсов %s сохранен администратором %d", pool.Name, request.AdminID)
+	return c.JSON(http.StatusOK, pool)
+}
+
+// GetRegions обработчик для получения регионов, в которых можно разместить стенд
+// @Summary Получить регионы
+// @Description Возвращает регионы с включенными пулами ресурсов. Пустой список — емкость не ограничивается и регион не выбирается
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Success 200 {array} string
+// @Failure 500 {object} map[string]string
+// @Router /regions [get]
+func (h *Handler) GetRegions(c echo.Context) error {
+	regions, err := database.GetRegions(database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, regions)
+}
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"fmt"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/quota"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// QuotaGrantRequest тело запроса на изменение квоты пользователя
+type QuotaGrantRequest struct {
+	AdminID       int64  `json:"adminID"`
+	UserID        int64  `json:"userID"`
+	Stands        int    `json:"stands"`
+	VMs           int    `json:"vms"`
+	TTLHours      int    `json:"ttlHours"`
+	DurationHours int    `json:"durationHours"` // Сколько действует изменение, 0 — постоянно
+	Reason        string `json:"reason"`
+}
+
+// ExtendRequest тело запроса на продление срока жизни стенда
+type ExtendRequest struct {
+	UserID int64 `json:"userID"`
+	Hours  int   `json:"hours"`
+}
+
+// GetQuotas обработчик для получения квот всех пользователей
+// @Summary Получить квоты пользователей
+// @Description Возвращает для каждого пользователя квоту его ролей с действующими изменениями и занятые стенды и ВМ
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {array} quota.Status
+// @Failure 500 {object} map[string]string
+// @Router /admin/quotas [get]
+func (h *Handler) GetQuotas(c echo.Context) error {
+	users, err := database.GetAllUsers()
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении пользователей: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	result := make([]quota.Status, 0, len(users))
+	for _, user := range users {
+		status, err := quota.GetStatus(user, database.DB)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		result = append(result, status)
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetUserQuota обработчик для получения квоты пользователя
+// @Summary Получить квоту пользователя
+// @Description Возвращает квоту пользователя с действующими изменениями и занятые стенды и ВМ
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path int true "ID пользователя"
+// @Success 200 {object} quota.Status
+// @Failure 404 {object} map[string]string
+// @Router /users/{id}/quota [get]
+func (h *Handler) GetUserQuota(c echo.Context) error {
+	userID, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+	user, err := database.GetUserByID(uint(userID))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+
+	status, err := quota.GetStatus(*user, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// CreateQuotaGrant обработчик для изменения квоты пользователя
+// @Summary Изменить квоту пользователя
+// @Description Прибавляет к квоте роли пользователя стенды, ВМ и часы срока жизни. При durationHours изменение временное. Пользователь получает уведомление
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Success 200 {object} quota.Status
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /admin/quotas/grants [post]
+func (h *Handler) CreateQuotaGrant(c echo.Context) error {
+	var request QuotaGrantRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.AdminID = actingUser(c, request.AdminID)
+	if request.Stands == 0 && request.VMs == 0 && request.TTLHours == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "nothing to grant"})
+	}
+	if request.DurationHours < 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "durationHours must not be negative"})
+	}
+	user, err := database.GetUserByID(uint(request.UserID))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+
+	grant := models.QuotaGrant{
+		UserID:    uint(request.UserID),
+		Stands:    request.Stands,
+		VMs:       request.VMs,
+		TTLHours:  request.TTLHours,
+		GrantedBy: uint(request.AdminID),
+		Reason:    request.Reason,
+		ExpiresAt: internal.StandExpiresAt(request.DurationHours),
+	}
+	message := fmt.Sprintf("Квота изменена: стенды %+d, ВМ %+d, срок жизни стенда %+d ч", grant.Stands, grant.VMs, grant.TTLHours)
+	if grant.ExpiresAt != nil {
+		message += " до " + grant.ExpiresAt.Format("02.01.2006 15:04")
+	}
+	if grant.Reason != "" {
+		message += "\nПричина: " + grant.Reason
+	}
+
+	tx := database.DB.Begin()
+	if err := database.CreateQuotaGrant(&grant, message, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	logger.InfofWithCaller("Администратор %d изменил квоту пользователя %d: %s", request.AdminID, request.UserID, message)
+
+	status, err := quota.GetStatus(*user, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, status)
+}
+
+// ExtendStand обработчик для продления срока жизни стенда
+// @Summary Продлить стенд
+// @Description Переносит окончание срока жизни стенда на hours часов, но не дальше максимального срока по квоте от текущего момента. Доступно автору, участникам команды стенда и администраторам
+// @Tags stands
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя стенда"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /stands/{name}/extend [post]
+func (h *Handler) ExtendStand(c echo.Context) error {
+	var request ExtendRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	if request.Hours <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "hours must be positive"})
+	}
+
+	stand, err := database.GetStandByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": err.Error()})
+	}
+	if status, msg := checkStandAccess(request.UserID, *stand, models.PermissionOperate); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+	if stand.ExpiresAt == nil {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "stand has no TTL"})
+	}
+
+	status, err := quota.GetStandStatus(*stand, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	now := time.Now()
+	expiresAt := now
+	if stand.ExpiresAt.After(now) {
+		expiresAt = *stand.ExpiresAt
+	}
+	expiresAt = expiresAt.Add(time.Duration(request.Hours) * time.Hour)
+	if limit := status.Limits.TTLHours; limit > 0 && expiresAt.Sub(now) > time.Duration(limit)*time.Hour {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("%v: stand TTL cannot be extended beyond %dh from now",
+			quota.ErrQuotaExceeded, limit)})
+	}
+
+	message := fmt.Sprintf("Срок жизни стенда %s продлен до %s", stand.Name, expiresAt.Format("02.01.2006 15:04"))
+	tx := database.DB.Begin()
+	if err := database.ExtendStand(stand, expiresAt, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := database.CreateStandMembersNotify(*stand, models.StepState{
+		StandName: stand.Name,
+		StepName:  "Срок жизни стенда " + stand.Name,
+		Status:    "success",
+		Kind:      models.NotifyKindQuota,
+		Message:   message,
+	}, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Стенд %s продлен пользователем %d до %s", stand.Name, request.UserID, expiresAt.Format(time.RFC3339))
+	return c.JSON(http.StatusOK, map[string]string{"message": message})
+}
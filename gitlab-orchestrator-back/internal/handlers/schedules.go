@@ -0,0 +1,236 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/admission"
+	"gitlab-orchestrator-back/internal/cron"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/rbac"
+	"gitlab-orchestrator-back/internal/registry"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// ScheduleRequest тело запроса на создание или изменение расписания
+type ScheduleRequest struct {
+	UserID    int64               `json:"userID"`
+	StandName *string             `json:"standName"`
+	Cron      *string             `json:"cron"`
+	TimeZone  *string             `json:"timeZone"`
+	Products  *models.ProductList `json:"products"` // Коды продуктов или объекты {code, version}
+	Ref       *string             `json:"ref"`
+	Paused    *bool               `json:"paused"`
+}
+
+// GetSchedules обработчик для получения расписаний
+// @Summary Получить расписания
+// @Description Возвращает расписания пользователя или все расписания, если userID не указан
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param userID query int false "ID пользователя"
+// @Success 200 {array} models.Schedule
+// @Failure 500 {object} map[string]string
+// @Router /schedules [get]
+func (h *Handler) GetSchedules(c echo.Context) error {
+	userID := actingUser(c, queryInt(c, "userID"))
+
+	schedules, err := database.GetSchedules(uint(userID), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, schedules)
+}
+
+// CreateSchedule обработчик для создания расписания
+// @Summary Создать расписание
+// @Description Создает расписание, по которому стенд создается или пересоздается с заданными продуктами и веткой
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.Schedule
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Router /schedules [post]
+func (h *Handler) CreateSchedule(c echo.Context) error {
+	var request ScheduleRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	if request.StandName == nil || *request.StandName == "" || request.Cron == nil ||
+		request.Products == nil || len(*request.Products) == 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "standName, cron and products are required"})
+	}
+
+	schedule := models.Schedule{
+		StandName: *request.StandName,
+		UserID:    uint(request.UserID),
+		Ref:       "master",
+		TimeZone:  "UTC",
+	}
+	if err := h.applyScheduleRequest(&schedule, request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	if status, msg := checkScheduleStand(schedule); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+
+	if err := database.DB.Create(&schedule).Error; err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании расписания для стенда %s: %v", schedule.StandName, err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Создано расписание %d для стенда %s: %s (%s)", schedule.ID, schedule.StandName, schedule.Cron, schedule.TimeZone)
+	return c.JSON(http.StatusOK, schedule)
+}
+
+// UpdateSchedule обработчик для изменения, приостановки и возобновления расписания
+// @Summary Изменить расписание
+// @Description Изменяет переданные поля расписания. Изменить расписание может владелец или пользователь с правом schedules:manage
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "ID расписания"
+// @Success 200 {object} models.Schedule
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /schedules/{id} [put]
+func (h *Handler) UpdateSchedule(c echo.Context) error {
+	var request ScheduleRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+
+	schedule, status, err := findSchedule(c.Param("id"), request.UserID)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	if request.StandName != nil && *request.StandName != schedule.StandName {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "stand name of a schedule cannot be changed"})
+	}
+	if err := h.applyScheduleRequest(schedule, request); err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+	// Новые продукты и права владельца проверяются так же, как при создании расписания
+	if status, msg := checkScheduleStand(*schedule); status != http.StatusOK {
+		return c.JSON(status, map[string]string{"error": msg})
+	}
+
+	if err := database.DB.Save(schedule).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Расписание %d обновлено пользователем %d", schedule.ID, request.UserID)
+	return c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteSchedule обработчик для удаления расписания
+// @Summary Удалить расписание
+// @Description Удаляет расписание. Стенд, созданный по расписанию, остается
+// @Tags schedules
+// @Accept json
+// @Produce json
+// @Param id path int true "ID расписания"
+// @Param userID query int true "ID пользователя"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /schedules/{id} [delete]
+func (h *Handler) DeleteSchedule(c echo.Context) error {
+	userID := actingUser(c, queryInt(c, "userID"))
+
+	schedule, status, err := findSchedule(c.Param("id"), userID)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	if err := database.DB.Delete(schedule).Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Расписание %d стенда %s удалено пользователем %d", schedule.ID, schedule.StandName, userID)
+	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Расписание стенда %s удалено", schedule.StandName)})
+}
+
+// findSchedule находит расписание и проверяет, что userID может им управлять
+func findSchedule(id string, userID int64) (*models.Schedule, int, error) {
+	scheduleID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return nil, http.StatusBadRequest, fmt.Errorf("invalid schedule ID")
+	}
+	schedule, err := database.GetScheduleByID(uint(scheduleID), database.DB)
+	if err != nil {
+		return nil, http.StatusNotFound, err
+	}
+	if status, msg := checkOwnerAccess(userID, schedule.UserID, rbac.SchedulesManage); status != http.StatusOK {
+		return nil, status, fmt.Errorf("%s", msg)
+	}
+	return schedule, http.StatusOK, nil
+}
+
+// checkScheduleStand проверяет, что расписание не обходит согласование стендов и что его владелец
+// может управлять уже существующим стендом расписания
+func checkScheduleStand(schedule models.Schedule) (int, string) {
+	var products models.ProductList
+	if err := json.Unmarshal(schedule.Products, &products); err != nil {
+		return http.StatusBadRequest, fmt.Sprintf("invalid products: %v", err)
+	}
+	if admission.RequiresApproval(internal.StandRequest{UserID: int64(schedule.UserID), Products: products}) {
+		return http.StatusForbidden, "stands of this user require approval and cannot be scheduled"
+	}
+	if stand, err := database.GetStandByName(schedule.StandName, database.DB); err == nil {
+		return checkStandAccess(int64(schedule.UserID), *stand, models.PermissionOperate)
+	}
+	return http.StatusOK, ""
+}
+
+// applyScheduleRequest переносит переданные поля в расписание и пересчитывает время следующего запуска
+func (h *Handler) applyScheduleRequest(schedule *models.Schedule, request ScheduleRequest) error {
+	if request.Cron != nil {
+		schedule.Cron = *request.Cron
+	}
+	if request.TimeZone != nil && *request.TimeZone != "" {
+		schedule.TimeZone = *request.TimeZone
+	}
+	if request.Ref != nil && *request.Ref != "" {
+		schedule.Ref = *request.Ref
+	}
+	if request.Paused != nil {
+		schedule.Paused = *request.Paused
+	}
+	if request.Products != nil {
+		if len(*request.Products) == 0 {
+			return fmt.Errorf("products cannot be empty")
+		}
+		if err := registry.ValidateVersions(h.Registry, *request.Products); err != nil {
+			return err
+		}
+		productsJSON, err := json.Marshal(*request.Products)
+		if err != nil {
+			return err
+		}
+		schedule.Products = productsJSON
+	}
+
+	parsed, err := cron.Parse(schedule.Cron, schedule.TimeZone)
+	if err != nil {
+		return err
+	}
+	schedule.NextRunAt = nil
+	if next := parsed.Next(time.Now()); !schedule.Paused && !next.IsZero() {
+		schedule.NextRunAt = &next
+	}
+	return nil
+}
@@ -0,0 +1,365 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/quota"
+	"gitlab-orchestrator-back/internal/rbac"
+	"net/http"
+	"regexp"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+var teamNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9-]*$`)
+
+// TeamRequest тело запроса на создание или изменение команды. Лимиты квоты меняют только администраторы
+type TeamRequest struct {
+	UserID      int64  `json:"userID"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	MaxStands   *int   `json:"maxStands"`   // 0 — без ограничения
+	MaxVMs      *int   `json:"maxVMs"`      // 0 — без ограничения
+	MaxTTLHours *int   `json:"maxTTLHours"` // 0 — без ограничения
+}
+
+// TeamMemberRequest тело запроса на добавление участника команды
+type TeamMemberRequest struct {
+	UserID int64  `json:"userID"`
+	Role   string `json:"role"` // owner / member, по умолчанию member
+}
+
+// TeamResponse команда с участниками и использованием ее квоты
+type TeamResponse struct {
+	models.Team
+	Quota quota.Status `json:"quota"`
+}
+
+// GetTeams обработчик для получения команд
+// @Summary Получить команды
+// @Description Возвращает команды с участниками и использованием квоты. С userID — только команды пользователя
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param userID query int false "ID пользователя"
+// @Success 200 {array} TeamResponse
+// @Failure 500 {object} map[string]string
+// @Router /teams [get]
+func (h *Handler) GetTeams(c echo.Context) error {
+	userID := actingUser(c, queryInt(c, "userID"))
+
+	teams, err := database.GetTeams(uint(userID), database.DB)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении команд: %v", err)
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	result := make([]TeamResponse, 0, len(teams))
+	for _, team := range teams {
+		status, err := quota.GetTeamStatus(team, database.DB)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		result = append(result, TeamResponse{Team: team, Quota: status})
+	}
+	return c.JSON(http.StatusOK, result)
+}
+
+// GetTeam обработчик для получения команды
+// @Summary Получить команду
+// @Description Возвращает команду с участниками и использованием квоты
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя команды"
+// @Success 200 {object} TeamResponse
+// @Failure 404 {object} map[string]string
+// @Router /teams/{name} [get]
+func (h *Handler) GetTeam(c echo.Context) error {
+	team, err := database.GetTeamByName(c.Param("name"), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "team not found"})
+	}
+	status, err := quota.GetTeamStatus(*team, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	return c.JSON(http.StatusOK, TeamResponse{Team: *team, Quota: status})
+}
+
+// CreateTeam обработчик для создания команды
+// @Summary Создать команду
+// @Description Создает команду, автор запроса становится ее владельцем. Квота новой команды берется из QUOTAS для роли team
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Success 200 {object} models.Team
+// @Failure 400 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /teams [post]
+func (h *Handler) CreateTeam(c echo.Context) error {
+	var request TeamRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	if !teamNamePattern.MatchString(request.Name) {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "team name must contain only lowercase letters, digits and dashes"})
+	}
+	user, err := database.GetUserByID(uint(request.UserID))
+	if err != nil {
+		return c.JSON(http.StatusForbidden, map[string]string{"error": "user not found"})
+	}
+	if _, err := database.GetTeamByName(request.Name, database.DB); err == nil {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("team %s already exists", request.Name)})
+	}
+
+	limits := config.Config.Quotas[quota.TeamRole]
+	team := models.Team{
+		Name:        request.Name,
+		Description: request.Description,
+		MaxStands:   limits.Stands,
+		MaxVMs:      limits.VMs,
+		MaxTTLHours: limits.TTLHours,
+		Members:     []models.TeamMember{{UserID: uint(user.ID), Role: models.TeamRoleOwner}},
+	}
+	if rbac.Can(*user, rbac.QuotasManage) {
+		applyTeamLimits(&team, request)
+	}
+	if err := database.CreateTeam(&team, database.DB); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Команда %s создана пользователем %d", team.Name, user.ID)
+	return c.JSON(http.StatusOK, team)
+}
+
+// UpdateTeam обработчик для изменения описания и квоты команды
+// @Summary Изменить команду
+// @Description Описание меняют владельцы команды и пользователи с правом teams:manage, лимиты квоты — пользователи с правом quotas:manage
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя команды"
+// @Success 200 {object} models.Team
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /teams/{name} [put]
+func (h *Handler) UpdateTeam(c echo.Context) error {
+	var request TeamRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	team, status, err := findTeam(c.Param("name"), request.UserID)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	if request.MaxStands != nil || request.MaxVMs != nil || request.MaxTTLHours != nil {
+		if status, message := checkPermission(request.UserID, rbac.QuotasManage); status != http.StatusOK {
+			return c.JSON(status, map[string]string{"error": message})
+		}
+		if (request.MaxStands != nil && *request.MaxStands < 0) || (request.MaxVMs != nil && *request.MaxVMs < 0) ||
+			(request.MaxTTLHours != nil && *request.MaxTTLHours < 0) {
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "limits must not be negative"})
+		}
+	}
+
+	if request.Description != "" {
+		team.Description = request.Description
+	}
+	applyTeamLimits(team, request)
+	if err := database.SaveTeam(team, database.DB); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Команда %s изменена пользователем %d", team.Name, request.UserID)
+	return c.JSON(http.StatusOK, team)
+}
+
+// DeleteTeam обработчик для удаления команды
+// @Summary Удалить команду
+// @Description Удаляет команду без стендов. Доступно владельцам команды и администраторам
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя команды"
+// @Param userID query int true "ID пользователя"
+// @Success 200 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /teams/{name} [delete]
+func (h *Handler) DeleteTeam(c echo.Context) error {
+	userID := actingUser(c, queryInt(c, "userID"))
+
+	team, status, err := findTeam(c.Param("name"), userID)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	stands, err := database.GetTeamStands(team.ID, database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if len(stands) > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("team %s still owns %d stands", team.Name, len(stands))})
+	}
+
+	tx := database.DB.Begin()
+	if err := database.DeleteTeam(team, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Команда %s удалена пользователем %d", team.Name, userID)
+	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Команда %s удалена", team.Name)})
+}
+
+// SaveTeamMember обработчик для добавления участника команды или изменения его роли
+// @Summary Добавить участника команды
+// @Description Добавляет пользователя в команду или меняет его роль. Доступно владельцам команды и администраторам
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя команды"
+// @Param memberID path int true "ID пользователя"
+// @Success 200 {object} TeamResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /teams/{name}/members/{memberID} [put]
+func (h *Handler) SaveTeamMember(c echo.Context) error {
+	var request TeamMemberRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.UserID = actingUser(c, request.UserID)
+	if request.Role == "" {
+		request.Role = models.TeamRoleMember
+	}
+	if request.Role != models.TeamRoleOwner && request.Role != models.TeamRoleMember {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "role must be owner or member"})
+	}
+	memberID, err := strconv.ParseInt(c.Param("memberID"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	team, status, err := findTeam(c.Param("name"), request.UserID)
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+	if _, err := database.GetUserByID(uint(memberID)); err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user not found"})
+	}
+	if member, exist := team.Member(uint(memberID)); exist && member.Role == models.TeamRoleOwner &&
+		request.Role != models.TeamRoleOwner && countTeamOwners(*team) == 1 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "team must keep at least one owner"})
+	}
+
+	if err := database.SaveTeamMember(team.ID, uint(memberID), request.Role, database.DB); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Пользователь %d добавлен в команду %s с ролью %s пользователем %d", memberID, team.Name, request.Role, request.UserID)
+	return h.GetTeam(c)
+}
+
+// DeleteTeamMember обработчик для исключения участника из команды
+// @Summary Исключить участника команды
+// @Description Исключает пользователя из команды. Доступно владельцам команды, администраторам и самому участнику
+// @Tags teams
+// @Accept json
+// @Produce json
+// @Param name path string true "Имя команды"
+// @Param memberID path int true "ID пользователя"
+// @Param userID query int true "ID пользователя, выполняющего действие"
+// @Success 200 {object} TeamResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /teams/{name}/members/{memberID} [delete]
+func (h *Handler) DeleteTeamMember(c echo.Context) error {
+	userID := actingUser(c, queryInt(c, "userID"))
+	memberID, err := strconv.ParseInt(c.Param("memberID"), 10, 64)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid user ID"})
+	}
+
+	team, status, err := findTeam(c.Param("name"), userID)
+	if status == http.StatusForbidden && userID == memberID {
+		// Участник может выйти из команды сам
+		team, err = database.GetTeamByName(c.Param("name"), database.DB)
+	}
+	if err != nil {
+		return c.JSON(status, map[string]string{"error": err.Error()})
+	}
+
+	member, exist := team.Member(uint(memberID))
+	if !exist {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": "user is not a member of the team"})
+	}
+	if member.Role == models.TeamRoleOwner && countTeamOwners(*team) == 1 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": "team must keep at least one owner"})
+	}
+
+	if err := database.DeleteTeamMember(team.ID, uint(memberID), database.DB); err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Пользователь %d исключен из команды %s пользователем %d", memberID, team.Name, userID)
+	return h.GetTeam(c)
+}
+
+// findTeam находит команду и проверяет, что userID может ею управлять: владельцы команды и администраторы
+func findTeam(name string, userID int64) (*models.Team, int, error) {
+	team, err := database.GetTeamByName(name, database.DB)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, http.StatusNotFound, fmt.Errorf("team %s not found", name)
+	}
+	if err != nil {
+		return nil, http.StatusInternalServerError, err
+	}
+	if member, exist := team.Member(uint(userID)); exist && member.Role == models.TeamRoleOwner {
+		return team, http.StatusOK, nil
+	}
+	if status, _ := checkPermission(userID, rbac.TeamsManage); status != http.StatusOK {
+		return nil, http.StatusForbidden, fmt.Errorf("only team owners or an admin can do this")
+	}
+	return team, http.StatusOK, nil
+}
+
+// applyTeamLimits переносит в команду лимиты квоты, заданные в запросе
+func applyTeamLimits(team *models.Team, request TeamRequest) {
+	if request.MaxStands != nil {
+		team.MaxStands = *request.MaxStands
+	}
+	if request.MaxVMs != nil {
+		team.MaxVMs = *request.MaxVMs
+	}
+	if request.MaxTTLHours != nil {
+		team.MaxTTLHours = *request.MaxTTLHours
+	}
+}
+
+func countTeamOwners(team models.Team) int {
+	count := 0
+	for _, member := range team.Members {
+		if member.Role == models.TeamRoleOwner {
+			count++
+		}
+	}
+	return count
+}
@@ -0,0 +1,273 @@
+package handlers
+
+import (
+	"fmt"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/rbac"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"gorm.io/gorm"
+)
+
+// UserRequest тело запроса на создание или изменение пользователя
+type UserRequest struct {
+	AdminID int64    `json:"adminID"`
+	UserID  int64    `json:"userID"` // Telegram ID создаваемого пользователя
+	Name    string   `json:"name"`
+	Roles   []string `json:"roles"` // При создании по умолчанию user
+}
+
+// UserResponse пользователь с правами по его ролям
+type UserResponse struct {
+	models.User
+	Permissions []string `json:"permissions"`
+}
+
+// GetUser обработчик для получения пользователя
+// @Summary Получить пользователя
+// @Description Возвращает пользователя, его роли и права. Доступно пользователям с правом users:manage
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "ID или имя пользователя"
+// @Success 200 {object} UserResponse
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id} [get]
+func (h *Handler) GetUser(c echo.Context) error {
+	user, err := resolveUser(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("user %s not found", c.Param("id"))})
+	}
+	return c.JSON(http.StatusOK, newUserResponse(*user))
+}
+
+// CreateUser обработчик для добавления пользователя
+// @Summary Добавить пользователя
+// @Description Добавляет пользователя Telegram с ролями, по умолчанию user. Пользователь без ролей, оставшийся после удаления, получает роли заново. Доступно пользователям с правом users:manage
+// @Tags users
+// @Accept json
+// @Produce json
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /users [post]
+func (h *Handler) CreateUser(c echo.Context) error {
+	var request UserRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.AdminID = actingUser(c, request.AdminID)
+	if request.UserID <= 0 {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "userID is required"})
+	}
+	request.Name = strings.TrimPrefix(strings.TrimSpace(request.Name), "@")
+	if request.Name == "" {
+		request.Name = strconv.FormatInt(request.UserID, 10)
+	}
+	if len(request.Roles) == 0 {
+		request.Roles = []string{"user"}
+	}
+	roleNames, err := normalizeRoles(request.Roles)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+	}
+
+	existing, err := database.GetUserByID(uint(request.UserID))
+	if err == nil && len(existing.Roles) > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("user %s already exists", existing.Name)})
+	}
+
+	user := models.User{ID: request.UserID, Name: request.Name}
+	tx := database.DB.Begin()
+	if err := saveUserRoles(&user, roleNames, existing != nil, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := notifyRoles(user, "Вам выдан доступ к боту", tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Администратор %d добавил пользователя %s (%d) с ролями %v", request.AdminID, user.Name, user.ID, roleNames)
+	return c.JSON(http.StatusOK, newUserResponse(user))
+}
+
+// UpdateUser обработчик для изменения пользователя
+// @Summary Изменить пользователя
+// @Description Меняет имя и роли пользователя, пустые поля не меняются. Пользователь получает уведомление об изменении ролей. Доступно пользователям с правом users:manage
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "ID или имя пользователя"
+// @Success 200 {object} UserResponse
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Router /users/{id} [put]
+func (h *Handler) UpdateUser(c echo.Context) error {
+	var request UserRequest
+	if err := c.Bind(&request); err != nil {
+		logger.ErrorfWithCaller("Ошибка при обработке запроса: %v", err)
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "Invalid request body"})
+	}
+	request.AdminID = actingUser(c, request.AdminID)
+	user, err := resolveUser(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("user %s not found", c.Param("id"))})
+	}
+
+	if name := strings.TrimPrefix(strings.TrimSpace(request.Name), "@"); name != "" {
+		user.Name = name
+	}
+	rolesChanged := len(request.Roles) > 0
+	tx := database.DB.Begin()
+	if rolesChanged {
+		roleNames, err := normalizeRoles(request.Roles)
+		if err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+		}
+		if user.Roles, err = database.GetRoles(roleNames, tx); err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+		if user.ID == request.AdminID && !rbac.Can(*user, rbac.UsersManage) {
+			tx.Rollback()
+			return c.JSON(http.StatusBadRequest, map[string]string{"error": "you cannot revoke your own users:manage permission"})
+		}
+	}
+	if err := database.UpdateUser(user, tx); err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if rolesChanged {
+		if err := notifyRoles(*user, "Ваши роли изменены", tx); err != nil {
+			tx.Rollback()
+			return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Администратор %d изменил пользователя %s (%d), роли: %v", request.AdminID, user.Name, user.ID, user.RoleNames())
+	return c.JSON(http.StatusOK, newUserResponse(*user))
+}
+
+// DeleteUser обработчик для удаления пользователя
+// @Summary Удалить пользователя
+// @Description Отзывает доступ пользователя: роли, участие в командах, доступы к стендам и бронирования, действующие изменения квоты завершаются. Пользователь с действующими стендами или расписаниями не удаляется: стенды нужно передать, расписания удалить. Если у пользователя были стенды или изменения квоты, запись остается без ролей ради истории. Доступно пользователям с правом users:manage
+// @Tags users
+// @Accept json
+// @Produce json
+// @Param id path string true "ID или имя пользователя"
+// @Param adminID query int true "ID администратора"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} map[string]string
+// @Failure 403 {object} map[string]string
+// @Failure 404 {object} map[string]string
+// @Failure 409 {object} map[string]string
+// @Router /users/{id} [delete]
+func (h *Handler) DeleteUser(c echo.Context) error {
+	adminID := actingUser(c, queryInt(c, "adminID"))
+	user, err := resolveUser(c.Param("id"))
+	if err != nil {
+		return c.JSON(http.StatusNotFound, map[string]string{"error": fmt.Sprintf("user %s not found", c.Param("id"))})
+	}
+	if user.ID == adminID {
+		return c.JSON(http.StatusBadRequest, map[string]string{"error": "you cannot delete yourself"})
+	}
+	stands, err := database.CountUserStands(uint(user.ID), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if stands > 0 {
+		return c.JSON(http.StatusConflict, map[string]string{"error": fmt.Sprintf("user %s owns %d stands, transfer them first", user.Name, stands)})
+	}
+	schedules, err := database.GetSchedules(uint(user.ID), database.DB)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if len(schedules) > 0 {
+		items := make([]string, 0, len(schedules))
+		for _, schedule := range schedules {
+			items = append(items, fmt.Sprintf("%d (%s)", schedule.ID, schedule.StandName))
+		}
+		return c.JSON(http.StatusConflict, map[string]string{
+			"error": fmt.Sprintf("user %s owns schedules %s, delete them first", user.Name, strings.Join(items, ", ")),
+		})
+	}
+
+	tx := database.DB.Begin()
+	removed, err := database.DeleteUser(user, tx)
+	if err != nil {
+		tx.Rollback()
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+	if err := tx.Commit().Error; err != nil {
+		return c.JSON(http.StatusInternalServerError, map[string]string{"error": err.Error()})
+	}
+
+	logger.InfofWithCaller("Администратор %d удалил пользователя %s (%d), запись удалена: %t", adminID, user.Name, user.ID, removed)
+	return c.JSON(http.StatusOK, map[string]string{"message": fmt.Sprintf("Пользователь %s удален", user.Name)})
+}
+
+// saveUserRoles создает пользователя с ролями roleNames или, если он уже есть, заменяет его имя и роли
+func saveUserRoles(user *models.User, roleNames []string, exists bool, tx *gorm.DB) error {
+	roles, err := database.GetRoles(roleNames, tx)
+	if err != nil {
+		return err
+	}
+	user.Roles = roles
+	if exists {
+		return database.UpdateUser(user, tx)
+	}
+	return database.CreateUser(user, tx)
+}
+
+// normalizeRoles проверяет роли и убирает повторы
+func normalizeRoles(roles []string) ([]string, error) {
+	var result []string
+	seen := make(map[string]bool)
+	for _, role := range roles {
+		role = strings.ToLower(strings.TrimSpace(role))
+		if role == "" || seen[role] {
+			continue
+		}
+		if !rbac.ValidRole(role) {
+			return nil, fmt.Errorf("unknown role %s, known roles: %s", role, strings.Join(rbac.Roles(), ", "))
+		}
+		seen[role] = true
+		result = append(result, role)
+	}
+	if len(result) == 0 {
+		return nil, fmt.Errorf("at least one role is required")
+	}
+	return result, nil
+}
+
+// notifyRoles уведомляет пользователя о его ролях. По этому уведомлению бот обновляет список пользователей
+func notifyRoles(user models.User, title string, tx *gorm.DB) error {
+	return database.CreateNotify(models.StepState{
+		StepName: title,
+		UserID:   uint(user.ID),
+		Status:   StatusSuccess,
+		Kind:     models.NotifyKindRole,
+		Message:  "Роли: " + strings.Join(user.RoleNames(), ", "),
+	}, tx)
+}
+
+func newUserResponse(user models.User) UserResponse {
+	return UserResponse{User: user, Permissions: rbac.UserPermissions(user)}
+}
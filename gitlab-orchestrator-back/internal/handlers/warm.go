@@ -0,0 +1,64 @@
+package handlers
+
+import (
+	"encoding/json"
+	"errors"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/upgrade"
+
+	"gorm.io/gorm"
+)
+
+// claimWarmStand выдает под запрос готовый стенд из пула того же типа, ветки и региона: передает его
+// пользователю с продуктами и метками из запроса, обновляет переменные окружения и запускает только stage helm.
+// Стенд сохраняет свое имя, так как по нему созданы ветка, окружение и ВМ. Выдача и запущенный пайплайн
+// записываются в tx, которую вызывающий фиксирует через upgrade.Commit, а при ошибке откатывает, оставляя
+// стенд в пуле. Если подходящего стенда нет, возвращает nil
+func (h *Handler) claimWarmStand(request internal.StandRequest, tx *gorm.DB) (*models.Stand, *models.Pipeline, error) {
+	if _, pooled := config.Config.WarmPool[request.Type]; !pooled {
+		return nil, nil, nil
+	}
+	stand, err := database.FindWarmStand(request.Type, request.Ref, request.Region, tx)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil, nil
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	products, err := json.Marshal(request.Products)
+	if err != nil {
+		return nil, nil, err
+	}
+	var labels []byte
+	if len(request.Labels) > 0 {
+		if labels, err = json.Marshal(request.Labels); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	if err := database.ClaimWarmStand(stand, uint(request.UserID), request.Team(), products, labels,
+		internal.StandExpiresAt(request.TTLHours), tx); err != nil {
+		if errors.Is(err, database.ErrWarmStandTaken) {
+			return nil, nil, nil
+		}
+		return nil, nil, err
+	}
+
+	if err = h.Gitlab.UpdateVariablesIntoEnvironment(stand.Name, request.Products.Codes()); err != nil {
+		logger.ErrorfWithCaller("Не удалось обновить переменные готового стенда %s: %v", stand.Name, err)
+		return nil, nil, err
+	}
+	pipeline, err := upgrade.StartDeploy(h.Gitlab, stand, request.Products, tx)
+	if err != nil {
+		logger.ErrorfWithCaller("Не удалось установить продукты на готовый стенд %s: %v", stand.Name, err)
+		return nil, nil, err
+	}
+
+	logger.InfofWithCaller("Готовый стенд %s выдан пользователю %d вместо стенда %s", stand.Name, request.UserID, request.NameStand)
+	return stand, pipeline, nil
+}
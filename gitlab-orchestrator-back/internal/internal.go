@@ -3,34 +3,103 @@ package internal
 import (
 	"encoding/json"
 	"fmt"
+	"gitlab-orchestrator-back/internal/config"
 	"gitlab-orchestrator-back/internal/models"
+	"path"
 	"sort"
 	"strings"
+	"time"
 )
 
-var (
-	stageOrderMap = map[string]int{
-		"terraform": 1,
-		"ansible":   2,
-		"helm":      3,
+// StepTemplate описывает шаг пайплайна стенда и stage GitLab, джобы которого он выполняет
+type StepTemplate struct {
+	Name         string
+	Description  string
+	Order        int
+	Stage        string
+	ApprovalJobs []string // Шаблоны имен manual-джоб, которые запускаются только после решения человека
+	PlanJob      string   // Шаблон имени джобы terraform plan, сводка которой показывается перед apply
+}
+
+var defaultStepTemplates = []StepTemplate{
+	{Name: "Creating vm", Description: "Initial creation step", Order: 1, Stage: "terraform"},
+	{Name: "Executing automation", Description: "Kubernetes installation", Order: 2, Stage: "ansible"},
+	{Name: "Executing helm", Description: "Running helm", Order: 3, Stage: "helm"},
+}
+
+// StepTemplates возвращает шаблоны шагов с правилами согласования джоб из конфигурации
+func StepTemplates() []StepTemplate {
+	templates := make([]StepTemplate, len(defaultStepTemplates))
+	copy(templates, defaultStepTemplates)
+	for i := range templates {
+		templates[i].ApprovalJobs = config.Config.JobApprovalRules[templates[i].Stage]
+		if templates[i].Stage == "terraform" {
+			templates[i].PlanJob = config.Config.TerraformPlanJob
+		}
 	}
-)
+	return templates
+}
+
+// RequiresApproval проверяет, нужно ли решение человека перед запуском джобы jobName
+func (t StepTemplate) RequiresApproval(jobName string) bool {
+	for _, pattern := range t.ApprovalJobs {
+		if matched, err := path.Match(pattern, jobName); err == nil && matched {
+			return true
+		}
+	}
+	return false
+}
+
+// IsPlanJob проверяет, является ли джоба jobName джобой terraform plan шага
+func (t StepTemplate) IsPlanJob(jobName string) bool {
+	if t.PlanJob == "" {
+		return false
+	}
+	matched, err := path.Match(t.PlanJob, jobName)
+	return err == nil && matched
+}
+
+// TemplateByOrder возвращает шаблон шага по его порядковому номеру
+func TemplateByOrder(order int) (StepTemplate, bool) {
+	for _, template := range StepTemplates() {
+		if template.Order == order {
+			return template, true
+		}
+	}
+	return StepTemplate{}, false
+}
+
+// templateByStage возвращает шаблон шага для stage GitLab
+func templateByStage(stage string) (StepTemplate, bool) {
+	for _, template := range StepTemplates() {
+		if template.Stage == stage {
+			return template, true
+		}
+	}
+	return StepTemplate{}, false
+}
 
 func ProcessJobs(jobMap map[string]map[string]models.Job, steps []models.Step) ([]models.Job, error) {
 	var stepID uint
 	var jobResult []models.Job
 
 	for stageName, jobs := range jobMap {
-		stageOrder := stageOrderMap[stageName]
+		template, known := templateByStage(stageName)
 		// TODO: добавить обработку неизвестных стейджей
-		if stageOrder == 0 {
+		if !known {
 			continue // Пропускаем неизвестные stage
 		}
+		stepID = 0
+		excluded := false
 		for _, e := range steps {
-			if e.Order == stageOrder {
+			if e.Order == template.Order {
 				stepID = e.ID
+				excluded = e.Status == "skipped"
 			}
 		}
+		if stepID == 0 {
+			continue // Шаг для stage не создавался в этом пайплайне
+		}
 		// Получаем все имена джобов для сортировки
 		jobNames := make([]string, 0, len(jobs))
 		for jobName := range jobs {
@@ -47,6 +116,12 @@ func ProcessJobs(jobMap map[string]map[string]models.Job, steps []models.Step) (
 			job.Order = jobOrder + 1
 			job.GitlabJobID = int(job.ID)
 			job.ID = 0 // Обнуляем ID, чтобы создать новую запись в базе данных
+			job.RequiresApproval = job.Status == "manual" && template.RequiresApproval(job.Name)
+			if excluded {
+				// Джобы stage, не выбранного для пайплайна, не запускаются
+				job.Status = "skipped"
+				job.RequiresApproval = false
+			}
 			jobResult = append(jobResult, job)
 
 		}
@@ -91,51 +166,227 @@ func extractNumber(s string) int {
 	return 0
 }
 
-func PopulateSteps(pipelineID uint) []models.Step {
+// PopulateSteps создает шаги пайплайна по шаблонам. Шаги stage, не входящих в stages, создаются пропущенными,
+// пустой stages — выполняются все шаги
+func PopulateSteps(pipelineID uint, stages []string) []models.Step {
 	var steps []models.Step
-	defaultSteps := []struct {
-		Name        string
-		Description string
-		Order       int
-		Status      string
-	}{
-		{Name: "Creating vm", Description: "Initial creation step", Order: 1, Status: "pending"},
-		{Name: "Executing automation", Description: "Kubernetes installation", Order: 2, Status: "pending"},
-		{Name: "Executing helm", Description: "Running helm", Order: 3, Status: "pending"},
-	}
 
 	// Create each step
-	for _, stepInfo := range defaultSteps {
+	for _, stepInfo := range StepTemplates() {
 		step := models.Step{
 			Name:        stepInfo.Name,
 			Description: stepInfo.Description,
 			Order:       stepInfo.Order,
 			PipelineID:  pipelineID,
-			Status:      stepInfo.Status,
+			Status:      "pending",
+		}
+		// Шаги невыбранных stage сохраняются, чтобы их джобы были видны как пропущенные
+		if len(stages) > 0 && !ContainsString(stages, stepInfo.Stage) {
+			step.Status = "skipped"
 		}
 		steps = append(steps, step)
 	}
 	return steps
 }
 
-func PopulateStand(req struct {
-	NameStand string   `json:"nameStand"`
-	Products  []string `json:"products"`
-	UserID    int64    `json:"userID"`
-	Ref       string   `json:"ref"`
-}) (models.Stand, error) {
+// StandRequest описывает запрос на создание стенда
+type StandRequest struct {
+	NameStand string             `json:"nameStand"`
+	Products  models.ProductList `json:"products"` // Коды продуктов или объекты {code, version}
+	UserID    int64              `json:"userID"`
+	Ref       string             `json:"ref"`
+	Type      string             `json:"type"`
+	Labels    map[string]string  `json:"labels"`
+	Region    string             `json:"region"`   // Регион пула ресурсов, пусто — любой
+	TTLHours  int                `json:"ttlHours"` // Срок жизни стенда в часах, 0 — максимальный по квоте
+	TeamID    uint               `json:"teamID"`   // Команда, которой будет принадлежать стенд, 0 — личный стенд
+}
+
+// Team возвращает команду стенда для модели, nil — личный стенд
+func (r StandRequest) Team() *uint {
+	if r.TeamID == 0 {
+		return nil
+	}
+	teamID := r.TeamID
+	return &teamID
+}
+
+func PopulateStand(req StandRequest) (models.Stand, error) {
 	// Convert products array to JSON
 	productsJSON, err := json.Marshal(req.Products)
 	if err != nil {
 		return models.Stand{}, err
 	}
 
-	// Populate and return the Stand structure
-	return models.Stand{
+	stand := models.Stand{
 		Name:     req.NameStand,
 		UserID:   uint(req.UserID),
+		TeamID:   req.Team(),
 		Products: productsJSON,
 		Status:   "created",
 		Ref:      req.Ref,
-	}, nil
+		Type:     req.Type,
+		Region:   req.Region,
+	}
+	if len(req.Labels) > 0 {
+		if stand.Labels, err = json.Marshal(req.Labels); err != nil {
+			return models.Stand{}, err
+		}
+	}
+	stand.ExpiresAt = StandExpiresAt(req.TTLHours)
+
+	// Populate and return the Stand structure
+	return stand, nil
+}
+
+// StandExpiresAt возвращает окончание срока жизни стенда, создаваемого сейчас, nil — без ограничения
+func StandExpiresAt(ttlHours int) *time.Time {
+	if ttlHours <= 0 {
+		return nil
+	}
+	expiresAt := time.Now().Add(time.Duration(ttlHours) * time.Hour)
+	return &expiresAt
+}
+
+// StepStatusFromJobs возвращает статус шага по статусам его джоб в GitLab:
+// error при упавшей или отмененной джобе, success, если все джобы завершены, иначе pending
+func StepStatusFromJobs(jobStatuses []string) string {
+	status := "success"
+	for _, jobStatus := range jobStatuses {
+		switch jobStatus {
+		case "success", "skipped":
+		case "failed", "canceled":
+			return "error"
+		default:
+			status = "pending"
+		}
+	}
+	return status
+}
+
+// AggregateStatus возвращает общий статус по статусам шагов или пайплайнов
+func AggregateStatus(statuses []string) string {
+	status := "success"
+	for _, s := range statuses {
+		switch s {
+		case "success", "skipped":
+		case "error":
+			return "error"
+		default:
+			status = "pending"
+		}
+	}
+	return status
+}
+
+// Переменные пайплайна обновления продукта
+const (
+	VarDeployStages    = "DEPLOY_STAGES"
+	VarUpgradeProduct  = "UPGRADE_PRODUCT"
+	VarUpgradeTag      = "UPGRADE_TAG"
+	VarProductVersions = "PRODUCT_VERSIONS"
+)
+
+// UpgradeStages stage, которые выполняет пайплайн обновления продукта
+var UpgradeStages = []string{"helm"}
+
+// PipelineStages возвращает stage, которые выполняет пайплайн, nil — все stage
+func PipelineStages(pipeline models.Pipeline) []string {
+	variables := make(map[string]string)
+	if len(pipeline.Variables) > 0 {
+		if err := json.Unmarshal(pipeline.Variables, &variables); err != nil {
+			return nil
+		}
+	}
+	if variables[VarDeployStages] == "" {
+		return nil
+	}
+	return strings.Split(variables[VarDeployStages], ",")
+}
+
+// StandStages возвращает stage, выбранные для подготовки стенда, nil — все stage
+func StandStages(stand models.Stand) ([]string, error) {
+	var stages []string
+	if len(stand.Stages) > 0 {
+		if err := json.Unmarshal(stand.Stages, &stages); err != nil {
+			return nil, fmt.Errorf("failed to parse stand stages: %v", err)
+		}
+	}
+	return stages, nil
+}
+
+// ValidateStages проверяет, что все stages есть в шаблонах шагов
+func ValidateStages(stages []string) error {
+	for _, stage := range stages {
+		if _, known := templateByStage(stage); !known {
+			return fmt.Errorf("unknown stage %q", stage)
+		}
+	}
+	return nil
+}
+
+// UpgradeVariables возвращает переменные запуска пайплайна обновления продукта code до тега tag
+func UpgradeVariables(code string, tag string) map[string]string {
+	variables := map[string]string{
+		VarDeployStages:   strings.Join(UpgradeStages, ","),
+		VarUpgradeProduct: code,
+	}
+	if tag != "" {
+		variables[VarUpgradeTag] = tag
+	}
+	return variables
+}
+
+// ProvisionVariables возвращает переменные запуска пайплайна подготовки стенда:
+// закрепленные версии продуктов в виде "code:version,...". Без закрепленных версий возвращает nil
+func ProvisionVariables(products models.ProductList) map[string]string {
+	var versions []string
+	for _, product := range products {
+		if product.Version != "" {
+			versions = append(versions, product.Code+":"+product.Version)
+		}
+	}
+	if len(versions) == 0 {
+		return nil
+	}
+	return map[string]string{VarProductVersions: strings.Join(versions, ",")}
+}
+
+// DeploymentImage возвращает запись о развернутой версии продукта
+func DeploymentImage(code string, tag string) string {
+	if tag == "" {
+		tag = "latest"
+	}
+	return code + ":" + tag
+}
+
+// ContainsString проверяет, есть ли value в values
+func ContainsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
+// CanRebuild сообщает, можно ли пересоздать стенд в статусе status: только если работа над ним завершена
+func CanRebuild(status string) bool {
+	switch status {
+	case "success", "error", "failed":
+		return true
+	}
+	return false
+}
+
+// ParseProducts разбирает значение переменной PRODUCTS в список продуктов без закрепленных версий
+func ParseProducts(value string) models.ProductList {
+	codes := strings.FieldsFunc(value, func(r rune) bool {
+		return r == ',' || r == ' ' || r == '[' || r == ']'
+	})
+	products := make(models.ProductList, 0, len(codes))
+	for _, code := range codes {
+		products = append(products, models.Product{Code: code})
+	}
+	return products
 }
@@ -0,0 +1,241 @@
+package middleware
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal/auth"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/rbac"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// keyTouchInterval как часто обновляется время последнего использования API-ключа
+const keyTouchInterval = time.Minute
+
+var (
+	errInvalidKey  = errors.New("invalid or revoked API key")
+	errRevokedUser = errors.New("token or API key user no longer has access")
+)
+
+// AuthMiddleware проверяет API-ключ сервиса или токен пользователя из заголовка Authorization: Bearer.
+// Запрос с токеном или ключом без Impersonate может действовать только от имени пользователя токена или владельца ключа
+func AuthMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if !config.Config.AuthEnabled || c.Path() == "/api/v1/health" {
+			return next(c)
+		}
+
+		credential, found := strings.CutPrefix(c.Request().Header.Get(echo.HeaderAuthorization), "Bearer ")
+		if !found || credential == "" {
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": "authentication required"})
+		}
+		principal, err := authenticate(credential, time.Now())
+		if err != nil {
+			logger.WarnfWithCaller("Отказ в доступе к %s %s: %v", c.Request().Method, c.Request().URL.Path, err)
+			return c.JSON(http.StatusUnauthorized, map[string]string{"error": err.Error()})
+		}
+		if !principal.Service() {
+			if err := checkActingUser(c, principal.UserID); err != nil {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": err.Error()})
+			}
+		}
+
+		c.Set(auth.ContextKey, principal)
+		return next(c)
+	}
+}
+
+// ServiceOnly разрешает маршрут только ключу бота (Impersonate), но не токенам пользователей и ключам с владельцем
+func ServiceOnly(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		if principal, ok := c.Get(auth.ContextKey).(auth.Principal); ok && !principal.Service() {
+			return c.JSON(http.StatusForbidden, map[string]string{"error": "this endpoint requires an impersonating API key"})
+		}
+		return next(c)
+	}
+}
+
+// RequirePermission разрешает маршрут пользователям, роли которых дают право permission. Пользователь берется
+// из токена, владельца ключа или, для ключа бота, из запроса (adminID, иначе userID)
+func RequirePermission(permission string) echo.MiddlewareFunc {
+	return func(next echo.HandlerFunc) echo.HandlerFunc {
+		return func(c echo.Context) error {
+			principal, _ := c.Get(auth.ContextKey).(auth.Principal)
+			userID, found := principal.UserID, !principal.Service()
+			if !found {
+				var err error
+				if userID, found, err = requestActor(c); err != nil {
+					return c.JSON(http.StatusBadRequest, map[string]string{"error": err.Error()})
+				}
+			}
+			if !found {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": "userID is required"})
+			}
+
+			user, err := database.GetUserByID(uint(userID))
+			if err != nil || !rbac.Can(*user, permission) {
+				return c.JSON(http.StatusForbidden, map[string]string{"error": fmt.Sprintf("%s permission is required", permission)})
+			}
+			return next(c)
+		}
+	}
+}
+
+// authenticate находит субъект запроса по API-ключу или токену
+func authenticate(credential string, now time.Time) (auth.Principal, error) {
+	if auth.BootstrapKey(credential) {
+		return auth.Principal{KeyName: "bootstrap"}, nil
+	}
+	if auth.IsKey(credential) {
+		key, err := database.GetAPIKeyByHash(auth.HashKey(credential), database.DB)
+		if err != nil || !key.Active(now) {
+			return auth.Principal{}, errInvalidKey
+		}
+		if key.LastUsedAt == nil || now.Sub(*key.LastUsedAt) > keyTouchInterval {
+			if err := database.TouchAPIKey(key, now, database.DB); err != nil {
+				logger.WarnfWithCaller("%v", err)
+			}
+		}
+		principal := auth.Principal{KeyID: key.ID, KeyName: key.Name}
+		if key.Impersonate {
+			return principal, nil
+		}
+		// Остальные ключи (CI, CLI) действуют только от имени своего владельца
+		principal.UserID = key.Owner()
+		if err := checkPrincipalUser(principal); err != nil {
+			return auth.Principal{}, err
+		}
+		return principal, nil
+	}
+
+	principal, err := auth.ParseToken(credential, now)
+	if err != nil {
+		return auth.Principal{}, err
+	}
+	// Токены отозванного ключа перестают действовать вместе с ним
+	if principal.KeyID != 0 {
+		key, err := database.GetAPIKeyByID(principal.KeyID, database.DB)
+		if err != nil || !key.Active(now) || !key.Impersonate {
+			return auth.Principal{}, errInvalidKey
+		}
+		principal.KeyName = key.Name
+	}
+	if err := checkPrincipalUser(principal); err != nil {
+		return auth.Principal{}, err
+	}
+	return principal, nil
+}
+
+// checkPrincipalUser проверяет, что пользователь токена или владелец ключа существует и у него есть роли:
+// токены и ключи перестают действовать, как только пользователя удаляют или лишают всех ролей
+func checkPrincipalUser(principal auth.Principal) error {
+	user, err := database.GetUserByID(uint(principal.UserID))
+	if err != nil || len(user.Roles) == 0 {
+		return errRevokedUser
+	}
+	return nil
+}
+
+// checkActingUser проверяет, что запрос с токеном или ключом владельца действует только от имени этого пользователя: все adminID,
+// а без adminID и все userID в query и теле должны совпадать с ним. При adminID поле userID обозначает
+// пользователя, над которым выполняется действие. Обработчики сами берут действующего пользователя из токена
+// (handlers.actingUser), проверка отклоняет явные попытки действовать от чужого имени
+func checkActingUser(c echo.Context, userID int64) error {
+	request := c.Request()
+	if hasBody(request) && !strings.HasPrefix(request.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		return errors.New("requests with a user token or an owned API key must have a JSON body")
+	}
+
+	actors, err := requestActors(c)
+	if err != nil {
+		return err
+	}
+	checked := actors.admins
+	if len(checked) == 0 {
+		checked = actors.users
+	}
+	for _, actor := range checked {
+		if actor != userID {
+			return fmt.Errorf("credentials of user %d cannot act as user %d", userID, actor)
+		}
+	}
+	return nil
+}
+
+// requestActor возвращает пользователя, от имени которого выполняется запрос: adminID, иначе userID
+// из query или JSON-тела
+func requestActor(c echo.Context) (int64, bool, error) {
+	actors, err := requestActors(c)
+	if err != nil {
+		return 0, false, err
+	}
+	if len(actors.admins) > 0 {
+		return actors.admins[0], true, nil
+	}
+	if len(actors.users) > 0 {
+		return actors.users[0], true, nil
+	}
+	return 0, false, nil
+}
+
+// actors значения adminID и userID из query и JSON-тела запроса
+type actors struct {
+	admins []int64
+	users  []int64
+}
+
+// requestActors собирает adminID и userID из query и JSON-тела. Тело после чтения восстанавливается для обработчика
+func requestActors(c echo.Context) (actors, error) {
+	var result actors
+	query := c.QueryParams()
+	for param, target := range map[string]*[]int64{"adminID": &result.admins, "userID": &result.users} {
+		for _, value := range query[param] {
+			actor, err := strconv.ParseInt(value, 10, 64)
+			if err != nil {
+				return actors{}, fmt.Errorf("invalid %s %s", param, value)
+			}
+			*target = append(*target, actor)
+		}
+	}
+
+	request := c.Request()
+	if !hasBody(request) || !strings.HasPrefix(request.Header.Get(echo.HeaderContentType), echo.MIMEApplicationJSON) {
+		return result, nil
+	}
+	body, err := io.ReadAll(request.Body)
+	if err != nil {
+		return actors{}, err
+	}
+	request.Body = io.NopCloser(bytes.NewReader(body))
+
+	var fields struct {
+		UserID  *int64 `json:"userID"`
+		AdminID *int64 `json:"adminID"`
+	}
+	// Некорректное тело отклонит сам обработчик
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return result, nil
+	}
+	if fields.AdminID != nil {
+		result.admins = append(result.admins, *fields.AdminID)
+	}
+	if fields.UserID != nil {
+		result.users = append(result.users, *fields.UserID)
+	}
+	return result, nil
+}
+
+// hasBody проверяет, что у запроса есть тело
+func hasBody(request *http.Request) bool {
+	return request.Body != nil && request.Body != http.NoBody && request.ContentLength != 0
+}
@@ -0,0 +1,90 @@
+package middleware
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestCheckActingUser(t *testing.T) {
+	const userID = 42
+
+	tests := []struct {
+		name        string
+		method      string
+		target      string
+		contentType string
+		body        string
+		wantErr     bool
+	}{
+		{name: "без пользователя в запросе", method: http.MethodGet, target: "/stands"},
+		{name: "свой userID в query", method: http.MethodGet, target: "/quotas?userID=42"},
+		{name: "чужой userID в query", method: http.MethodGet, target: "/quotas?userID=7", wantErr: true},
+		{name: "свой adminID и userID цели", method: http.MethodDelete, target: "/teams/qa/members/7?adminID=42&userID=7"},
+		{name: "чужой adminID в query", method: http.MethodGet, target: "/users?adminID=7", wantErr: true},
+		{name: "некорректный userID в query", method: http.MethodGet, target: "/quotas?userID=abc", wantErr: true},
+		{
+			name: "свой userID в теле", method: http.MethodPost, target: "/stands",
+			contentType: echo.MIMEApplicationJSON, body: `{"userID": 42, "nameStand": "demo"}`,
+		},
+		{
+			name: "чужой userID в теле", method: http.MethodPost, target: "/stands",
+			contentType: echo.MIMEApplicationJSON, body: `{"userID": 7, "nameStand": "demo"}`, wantErr: true,
+		},
+		{
+			name: "свой adminID и userID цели в теле", method: http.MethodPost, target: "/admin/quotas/grants",
+			contentType: echo.MIMEApplicationJSON + "; charset=UTF-8", body: `{"adminID": 42, "userID": 7}`,
+		},
+		{
+			name: "чужой adminID в теле при своем в query", method: http.MethodPost, target: "/admin/quotas/grants?adminID=42",
+			contentType: echo.MIMEApplicationJSON, body: `{"adminID": 7}`, wantErr: true,
+		},
+		{
+			name: "чужой userID в query при своем в теле", method: http.MethodPost, target: "/stands?userID=7",
+			contentType: echo.MIMEApplicationJSON, body: `{"userID": 42}`, wantErr: true,
+		},
+		{
+			name: "тело не в JSON", method: http.MethodPost, target: "/stands",
+			contentType: echo.MIMEApplicationForm, body: "userID=7", wantErr: true,
+		},
+		{
+			name: "некорректный JSON отклоняет обработчик", method: http.MethodPost, target: "/stands",
+			contentType: echo.MIMEApplicationJSON, body: `{"userID":`,
+		},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var body io.Reader
+			if tt.body != "" {
+				body = strings.NewReader(tt.body)
+			}
+			request := httptest.NewRequest(tt.method, tt.target, body)
+			if tt.contentType != "" {
+				request.Header.Set(echo.HeaderContentType, tt.contentType)
+			}
+			c := e.NewContext(request, httptest.NewRecorder())
+
+			err := checkActingUser(c, userID)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("checkActingUser() = %v, ожидалась ошибка: %v", err, tt.wantErr)
+			}
+
+			// Обработчик должен получить тело запроса целиком
+			if tt.body != "" && !tt.wantErr {
+				restored, err := io.ReadAll(c.Request().Body)
+				if err != nil {
+					t.Fatalf("чтение тела: %v", err)
+				}
+				if string(restored) != tt.body {
+					t.Errorf("тело после проверки = %q, ожидалось %q", restored, tt.body)
+				}
+			}
+		})
+	}
+}
@@ -1,11 +1,84 @@
 package models
 
 import (
+	"encoding/json"
 	"gorm.io/datatypes"
 	"gorm.io/gorm"
 	"time"
 )
 
+// Типы уведомлений
+const (
+	NotifyKindStep           = "step"            // Этап пайплайна стенда
+	NotifyKindStand          = "stand"           // Событие стенда вне этапов пайплайна
+	NotifyKindApproval       = "approval"        // Запрос администратору на согласование стенда
+	NotifyKindApprovalResult = "approval_result" // Решение по согласованию для автора стенда
+	NotifyKindJobApproval    = "job_approval"    // Запрос решения по manual-джобе
+	NotifyKindPlan           = "plan"            // Сводка terraform plan перед apply
+	NotifyKindUpgrade        = "upgrade"         // Результат обновления или отката продукта
+	NotifyKindFleet          = "fleet"           // Итог массового обновления стендов
+	NotifyKindQuota          = "quota"           // Изменение квоты или окончание срока жизни стенда
+	NotifyKindAccess         = "access"          // Выдача доступа к стенду или передача стенда
+	NotifyKindBooking        = "booking"         // Операция со стендом во время бронирования другого пользователя
+	NotifyKindRole           = "role"            // Изменение ролей пользователя
+	NotifyKindAccessRequest  = "access_request"  // Заявка на доступ к боту для администраторов
+)
+
+// Виды пайплайнов стенда
+const (
+	PipelineKindProvision = "provision" // Полная подготовка стенда
+	PipelineKindUpgrade   = "upgrade"   // Обновление одного продукта на стенде
+)
+
+// Решения по manual-джобе, требующей согласования
+const (
+	JobDecisionApprove = "approve" // Запустить джобу
+	JobDecisionSkip    = "skip"    // Пропустить джобу и продолжить шаг
+	JobDecisionAbort   = "abort"   // Остановить создание стенда
+)
+
+// Product продукт стенда с необязательной закрепленной версией (тегом образа)
+type Product struct {
+	Code    string `json:"code"`
+	Version string `json:"version,omitempty"`
+}
+
+// ProductList список продуктов стенда. При разборе JSON принимает и коды продуктов, и объекты Product
+type ProductList []Product
+
+// UnmarshalJSON разбирает ["code", {"code": "code", "version": "1.4.2"}]
+func (l *ProductList) UnmarshalJSON(data []byte) error {
+	var items []json.RawMessage
+	if err := json.Unmarshal(data, &items); err != nil {
+		return err
+	}
+
+	products := make(ProductList, 0, len(items))
+	for _, item := range items {
+		var code string
+		if err := json.Unmarshal(item, &code); err == nil {
+			products = append(products, Product{Code: code})
+			continue
+		}
+		var product Product
+		if err := json.Unmarshal(item, &product); err != nil {
+			return err
+		}
+		products = append(products, product)
+	}
+	*l = products
+	return nil
+}
+
+// Codes возвращает коды продуктов списка
+func (l ProductList) Codes() []string {
+	codes := make([]string, 0, len(l))
+	for _, product := range l {
+		codes = append(codes, product.Code)
+	}
+	return codes
+}
+
 // TableName Explicitly specify the table name
 func (User) TableName() string {
 	return "users" // Ensure this matches the actual table name in the database
@@ -14,9 +87,45 @@ func (User) TableName() string {
 type User struct {
 	ID     int64   `gorm:"primaryKey;uniqueIndex;not null" json:"chat_id"`
 	Name   string  `gorm:"not null" json:"name"`
-	Role   string  `gorm:"not null" json:"role"`
+	Roles  []Role  `gorm:"many2many:user_roles" json:"roles"`
 	Stands []Stand `gorm:"foreignKey:UserID"` // One user can have many stands
 }
+
+// HasRole проверяет, есть ли у пользователя роль role. Роли должны быть загружены вместе с пользователем
+func (u User) HasRole(role string) bool {
+	for _, r := range u.Roles {
+		if r.Name == role {
+			return true
+		}
+	}
+	return false
+}
+
+// RoleNames возвращает имена ролей пользователя
+func (u User) RoleNames() []string {
+	names := make([]string, 0, len(u.Roles))
+	for _, role := range u.Roles {
+		names = append(names, role.Name)
+	}
+	return names
+}
+
+// Role роль пользователя, по ролям выдаются права (internal/rbac) и квоты
+type Role struct {
+	ID   uint   `gorm:"primaryKey"`
+	Name string `gorm:"not null;uniqueIndex"`
+}
+
+// MarshalJSON представляет роль в JSON ее именем
+func (r Role) MarshalJSON() ([]byte, error) {
+	return json.Marshal(r.Name)
+}
+
+// UnmarshalJSON читает роль из ее имени
+func (r *Role) UnmarshalJSON(data []byte) error {
+	return json.Unmarshal(data, &r.Name)
+}
+
 type Subos struct {
 	ID   uint   `json:"id" gorm:"primaryKey"`
 	Name string `json:"name"`
@@ -26,26 +135,44 @@ type Subos struct {
 type Stand struct {
 	ID                uint           `gorm:"primaryKey"`
 	Name              string         `gorm:"not null;uniqueIndex"`
-	UserID            uint           `gorm:"index;not null"`     // Внешний ключ к пользователю
+	UserID            uint           `gorm:"index;not null"`     // Автор стенда, внешний ключ к пользователю
 	User              User           `gorm:"foreignKey:UserID"`  // Стенд принадлежит пользователю
-	Products          datatypes.JSON `gorm:"type:json"`          // Продукты хранятся как JSON
+	TeamID            *uint          `gorm:"index"`              // Команда, которой принадлежит стенд, nil — личный стенд автора
+	Products          datatypes.JSON `gorm:"type:json"`          // Продукты хранятся как JSON (ProductList)
 	Pipelines         []Pipeline     `gorm:"foreignKey:StandID"` // Один стенд может иметь много шагов
 	CurrentPipelineID uint           `gorm:"index"`              // ID текущего пайплайна
 	Status            string         `gorm:"not null"`
-	Ref               string         `gorm:"not null"` // бренча в GitLab
+	Ref               string         `gorm:"not null"`               // бренча в GitLab
+	ProvisionAttempts int            `gorm:"not null;default:0"`     // Количество неудачных попыток подготовки
+	RecreateBranch    bool           `gorm:"not null;default:false"` // Подготовка должна удалить ветку стенда и заново создать ее из Ref
+	DecidedBy         uint           // Администратор, согласовавший или отклонивший стенд
+	DecisionReason    string         // Причина решения по согласованию
+	Deployments       datatypes.JSON `gorm:"type:json"` // Развернутые версии продуктов: код продукта -> образ
+	Type              string         `gorm:"index"`     // Тип стенда, например demo или qa
+	Labels            datatypes.JSON `gorm:"type:json"` // Метки стенда: ключ -> значение
+	Stages            datatypes.JSON `gorm:"type:json"` // Stage следующего пайплайна подготовки, пусто — все
+	Region            string         `gorm:"index"`     // Регион, в котором нужно разместить стенд, пусто — любой
+	Pool              string         `gorm:"index"`     // Пул ресурсов, в котором размещен стенд
+	QueueReason       string         // Почему стенд ждет в очереди на подготовку
+	Warm              bool           `gorm:"not null;default:false;index"` // Готовый стенд из пула, еще не выданный пользователю
+	ExpiresAt         *time.Time     `gorm:"index"`                        // Окончание срока жизни стенда, nil — без ограничения
+	ExpiryNotified    bool           // Владелец уведомлен об окончании срока жизни
 	CreatedAt         time.Time      `gorm:"index"`
 	UpdatedAt         time.Time
 	DeletedAt         gorm.DeletedAt `gorm:"index"`
 }
 type Pipeline struct {
-	ID               uint      `gorm:"primaryKey"`
-	Name             string    `gorm:"not null"`
-	StandID          uint      `gorm:"index;not null"`             // Внешний ключ к шагу
-	Stand            Stand     `gorm:"foreignKey:StandID"`         // Пайплайн принадлежит шагу
-	Status           string    `gorm:"not null;default:'pending'"` // Статус выполнения пайплайна
-	Steps            []Step    `gorm:"foreignKey:PipelineID"`      // Один пайплайн может иметь много джобов
-	GitlabPipelineID int       `gorm:"index"`                      // ID пайплайна в GitLab
-	CreatedAt        time.Time `gorm:"index"`
+	ID               uint           `gorm:"primaryKey"`
+	Name             string         `gorm:"not null"`
+	StandID          uint           `gorm:"index;not null"`             // Внешний ключ к шагу
+	Stand            Stand          `gorm:"foreignKey:StandID"`         // Пайплайн принадлежит шагу
+	Status           string         `gorm:"not null;default:'pending'"` // Статус выполнения пайплайна
+	Steps            []Step         `gorm:"foreignKey:PipelineID"`      // Один пайплайн может иметь много джобов
+	GitlabPipelineID int            `gorm:"index"`                      // ID пайплайна в GitLab
+	Ref              string         // Ветка, из которой подготовлен стенд для этого пайплайна
+	Kind             string         `gorm:"not null;default:'provision'"` // provision / upgrade
+	Variables        datatypes.JSON `gorm:"type:json"`                    // Переменные, переданные в GitLab при запуске
+	CreatedAt        time.Time      `gorm:"index"`
 	UpdatedAt        time.Time
 	StartedAt        *time.Time
 	FinishedAt       *time.Time
@@ -57,11 +184,13 @@ type Step struct {
 	ID          uint   `gorm:"primaryKey"`
 	Name        string `gorm:"not null"`
 	Description string
-	Order       int      `gorm:"not null;default:0;index"` // Порядок выполнения шага
-	PipelineID  uint     `gorm:"index;not null"`           // Внешний ключ к стенду
-	Pipeline    Pipeline `gorm:"foreignKey:PipelineID"`    // Шаг принадлежит пайплайну
-	Jobs        []Job    `gorm:"foreignKey:StepID"`        // Один шаг может запускать много Джоб
-	Status      string   `gorm:"not null"`                 // Статус выполнения шага
+	Order       int            `gorm:"not null;default:0;index"` // Порядок выполнения шага
+	PipelineID  uint           `gorm:"index;not null"`           // Внешний ключ к стенду
+	Pipeline    Pipeline       `gorm:"foreignKey:PipelineID"`    // Шаг принадлежит пайплайну
+	Jobs        []Job          `gorm:"foreignKey:StepID"`        // Один шаг может запускать много Джоб
+	Status      string         `gorm:"not null"`                 // Статус выполнения шага
+	PlanSummary datatypes.JSON `gorm:"type:json"`                // Сводка terraform plan по типам ресурсов
+	PlanRaw     string         `gorm:"type:text"`                // Полный вывод terraform plan
 	CreatedAt   time.Time
 	UpdatedAt   time.Time
 	DeletedAt   gorm.DeletedAt
@@ -72,17 +201,22 @@ type Job struct {
 	ID          uint   `gorm:"primaryKey" json:"id"`
 	Name        string `gorm:"not null" json:"name"`
 	Description string
-	StepID      uint      `gorm:"index;not null"`    // Внешний ключ к пайплайну
-	Step        Step      `gorm:"foreignKey:StepID"` // Джоб принадлежит пайплайну
-	GitlabJobID int       `gorm:"index"`             // ID джоба в GitLab
-	Stage       string    `json:"stage"`
-	Status      string    `gorm:"not null" json:"status"` // Статус выполнения джоба
-	Order       int       `gorm:"not null;default:0"`     // Порядок выполнения джоба
-	CreatedAt   time.Time `gorm:"index"`
-	UpdatedAt   time.Time
-	StartedAt   *time.Time
-	FinishedAt  *time.Time
-	DeletedAt   gorm.DeletedAt
+	StepID      uint   `gorm:"index;not null"`    // Внешний ключ к пайплайну
+	Step        Step   `gorm:"foreignKey:StepID"` // Джоб принадлежит пайплайну
+	GitlabJobID int    `gorm:"index"`             // ID джоба в GitLab
+	Stage       string `json:"stage"`
+	Status      string `gorm:"not null" json:"status"` // Статус выполнения джоба
+	Order       int    `gorm:"not null;default:0"`     // Порядок выполнения джоба
+	// Согласование manual-джобы человеком
+	RequiresApproval bool       `json:"requires_approval"`
+	Decision         string     `json:"decision"` // approve / skip / abort
+	DecidedBy        uint       `json:"decided_by"`
+	DecidedAt        *time.Time `json:"decided_at"`
+	CreatedAt        time.Time  `gorm:"index"`
+	UpdatedAt        time.Time
+	StartedAt        *time.Time `json:"started_at"`
+	FinishedAt       *time.Time `json:"finished_at"`
+	DeletedAt        gorm.DeletedAt
 }
 
 type StepState struct {
@@ -92,6 +226,12 @@ type StepState struct {
 	UserID    uint           `json:"user_id" gorm:"not null;index"`
 	Status    string         `json:"status" gorm:"type:varchar(50)"`
 	Send      bool           `json:"send" gorm:"default:false"`
+	Kind      string         `json:"kind" gorm:"type:varchar(50);default:'step'"`
+	JobID     uint           `json:"job_id"`     // Джоба, по которой нужно решение
+	StepID    uint           `json:"step_id"`    // Шаг, к которому приложен план terraform
+	Product   string         `json:"product"`    // Продукт, обновление которого завершилось
+	RequestID uint           `json:"request_id"` // Заявка на доступ, по которой нужно решение
+	Message   string         `json:"message"`
 	CreatedAt time.Time      `json:"created_at"`
 	Order     int            `json:"order" gorm:"not null"`
 	UpdatedAt time.Time      `json:"updated_at"`
@@ -100,3 +240,255 @@ type StepState struct {
 	// Связь с пользователем
 	User User `json:"-" gorm:"foreignKey:UserID"`
 }
+
+// ProvisionAction фиксирует действие, выполненное при подготовке стенда,
+// чтобы повторная попытка продолжила с места остановки, а при окончательной
+// ошибке созданные ресурсы можно было удалить
+type ProvisionAction struct {
+	ID        uint   `gorm:"primaryKey"`
+	StandID   uint   `gorm:"index;not null"` // Внешний ключ к стенду
+	Name      string `gorm:"not null"`       // Название действия
+	Order     int    `gorm:"not null;default:0"`
+	Status    string `gorm:"not null"` // in_progress / done / compensated
+	Created   bool   // Ресурс создан этим действием, а не существовал ранее
+	Ref       string // Идентификатор созданного ресурса (например, ID пайплайна)
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// OrphanResource описывает ресурс GitLab, названный по схеме стенда, для которого нет стенда в БД
+type OrphanResource struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Kind        string     `json:"kind" gorm:"not null;uniqueIndex:idx_orphan_kind_name"` // branch / environment / variables
+	Name        string     `json:"name" gorm:"not null;uniqueIndex:idx_orphan_kind_name"`
+	FirstSeenAt time.Time  `json:"first_seen_at" gorm:"not null"`
+	LastSeenAt  time.Time  `json:"last_seen_at" gorm:"not null"`
+	RemovedAt   *time.Time `json:"removed_at"` // Время удаления ресурса из GitLab
+}
+
+// Schedule описывает регулярное создание или пересоздание стенда по cron-выражению
+type Schedule struct {
+	ID         uint           `json:"id" gorm:"primaryKey"`
+	StandName  string         `json:"stand_name" gorm:"not null;index"`
+	UserID     uint           `json:"user_id" gorm:"not null;index"` // Владелец расписания и создаваемого стенда
+	Cron       string         `json:"cron" gorm:"not null"`
+	TimeZone   string         `json:"time_zone" gorm:"not null;default:'UTC'"`
+	Products   datatypes.JSON `json:"products" gorm:"type:json"`
+	Ref        string         `json:"ref" gorm:"not null"`
+	Paused     bool           `json:"paused" gorm:"not null;default:false"`
+	NextRunAt  *time.Time     `json:"next_run_at" gorm:"index"`
+	LastRunAt  *time.Time     `json:"last_run_at"`
+	LastResult string         `json:"last_result"` // Результат последнего запуска
+	CreatedAt  time.Time      `json:"created_at"`
+	UpdatedAt  time.Time      `json:"updated_at"`
+}
+
+// DeploymentHistory запись о развертывании образа продукта на стенде успешным пайплайном
+type DeploymentHistory struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	StandID    uint      `json:"stand_id" gorm:"not null;index:idx_deployment_stand_product"`
+	Product    string    `json:"product" gorm:"not null;index:idx_deployment_stand_product"`
+	Image      string    `json:"image" gorm:"not null"` // Образ в виде code:tag
+	PipelineID uint      `json:"pipeline_id" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// FleetFilter условия выбора стендов для массовой операции
+type FleetFilter struct {
+	Product string            `json:"product"`          // Стенды, на которых развернут продукт
+	OwnerID uint              `json:"ownerID"`          // Владелец стендов, 0 — любой
+	Type    string            `json:"type"`             // Тип стенда, пусто — любой
+	Labels  map[string]string `json:"labels,omitempty"` // Все метки должны совпадать
+}
+
+// FleetOperation массовое обновление продукта на выбранных стендах пачками
+type FleetOperation struct {
+	ID          uint           `json:"id" gorm:"primaryKey"`
+	UserID      uint           `json:"user_id" gorm:"not null;index"` // Администратор, запустивший операцию
+	Product     string         `json:"product" gorm:"not null"`
+	Tag         string         `json:"tag"`
+	Filter      datatypes.JSON `json:"filter" gorm:"type:json"` // FleetFilter
+	BatchSize   int            `json:"batch_size" gorm:"not null"`
+	MaxFailures int            `json:"max_failures" gorm:"not null;default:0"` // Порог ошибок для остановки, 0 — без порога
+	Status      string         `json:"status" gorm:"not null;index"`
+	Message     string         `json:"message"`
+	Targets     []FleetTarget  `json:"targets,omitempty" gorm:"foreignKey:OperationID"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	FinishedAt  *time.Time     `json:"finished_at"`
+}
+
+// FleetTarget стенд массовой операции и его пайплайн обновления
+type FleetTarget struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	OperationID uint       `json:"operation_id" gorm:"not null;index"`
+	StandID     uint       `json:"stand_id" gorm:"not null"`
+	StandName   string     `json:"stand_name" gorm:"not null"`
+	Batch       int        `json:"batch" gorm:"not null"` // Номер пачки, начиная с 1
+	Status      string     `json:"status" gorm:"not null;index"`
+	PipelineID  uint       `json:"pipeline_id"`
+	Message     string     `json:"message"`
+	StartedAt   *time.Time `json:"started_at"`
+	FinishedAt  *time.Time `json:"finished_at"`
+}
+
+// ResourcePool пул мощностей гипервизоров в регионе. Нулевой лимит — без ограничения
+type ResourcePool struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	Name      string    `json:"name" gorm:"not null;uniqueIndex"`
+	Region    string    `json:"region" gorm:"not null;index"`
+	MaxVMs    int       `json:"max_vms" gorm:"not null;default:0"`
+	MaxVCPU   int       `json:"max_vcpu" gorm:"not null;default:0"`
+	MaxRAMGB  int       `json:"max_ram_gb" gorm:"not null;default:0"`
+	Disabled  bool      `json:"disabled" gorm:"not null;default:false"` // В отключенный пул новые стенды не размещаются
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+// QuotaGrant изменение квоты пользователя администратором поверх квоты его роли.
+// Значения прибавляются к лимитам роли, при ExpiresAt изменение временное
+type QuotaGrant struct {
+	ID        uint       `json:"id" gorm:"primaryKey"`
+	UserID    uint       `json:"user_id" gorm:"not null;index"`
+	Stands    int        `json:"stands"`
+	VMs       int        `json:"vms"`
+	TTLHours  int        `json:"ttl_hours"`
+	GrantedBy uint       `json:"granted_by" gorm:"not null"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at" gorm:"index"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+const (
+	TeamRoleOwner  = "owner"  // Управляет составом и описанием команды
+	TeamRoleMember = "member" // Управляет стендами команды
+)
+
+// Team команда пользователей. Стенды команды доступны всем ее участникам,
+// а квота команды считается по всем ее стендам. Нулевой лимит — без ограничения
+type Team struct {
+	ID          uint         `json:"id" gorm:"primaryKey"`
+	Name        string       `json:"name" gorm:"not null;uniqueIndex"`
+	Description string       `json:"description"`
+	MaxStands   int          `json:"max_stands" gorm:"not null;default:0"`
+	MaxVMs      int          `json:"max_vms" gorm:"not null;default:0"`
+	MaxTTLHours int          `json:"max_ttl_hours" gorm:"not null;default:0"`
+	Members     []TeamMember `json:"members" gorm:"foreignKey:TeamID"`
+	CreatedAt   time.Time    `json:"created_at"`
+	UpdatedAt   time.Time    `json:"updated_at"`
+}
+
+// Member возвращает участника команды по ID пользователя
+func (t Team) Member(userID uint) (TeamMember, bool) {
+	for _, member := range t.Members {
+		if member.UserID == userID {
+			return member, true
+		}
+	}
+	return TeamMember{}, false
+}
+
+// TeamMember участник команды
+type TeamMember struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	TeamID    uint      `json:"team_id" gorm:"not null;uniqueIndex:idx_team_member"`
+	UserID    uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_team_member;index"`
+	Role      string    `json:"role" gorm:"not null;default:'member'"` // owner / member
+	CreatedAt time.Time `json:"created_at"`
+}
+
+const (
+	PermissionView    = "view"    // Получает уведомления о стенде
+	PermissionOperate = "operate" // Пересоздает, перезапускает, обновляет и продлевает стенд
+	PermissionAdmin   = "admin"   // Кроме того, выдает доступ к стенду и передает его другому владельцу
+)
+
+var permissionRanks = map[string]int{PermissionView: 1, PermissionOperate: 2, PermissionAdmin: 3}
+
+// ValidPermission проверяет, что permission — известное право на стенд
+func ValidPermission(permission string) bool {
+	_, exist := permissionRanks[permission]
+	return exist
+}
+
+// PermissionAllows проверяет, что право granted включает право required
+func PermissionAllows(granted string, required string) bool {
+	return permissionRanks[granted] >= permissionRanks[required]
+}
+
+// StandCollaborator пользователь, которому автор стенда выдал доступ к стенду
+type StandCollaborator struct {
+	ID         uint      `json:"id" gorm:"primaryKey"`
+	StandID    uint      `json:"stand_id" gorm:"not null;uniqueIndex:idx_stand_collaborator"`
+	UserID     uint      `json:"user_id" gorm:"not null;uniqueIndex:idx_stand_collaborator;index"`
+	Permission string    `json:"permission" gorm:"not null"` // view / operate / admin
+	GrantedBy  uint      `json:"granted_by" gorm:"not null"`
+	CreatedAt  time.Time `json:"created_at"`
+}
+
+// StandBooking бронирование стенда на интервал времени. Эксклюзивное бронирование (блокировка) запрещает
+// операции со стендом другим пользователям, обычное только предупреждает держателя о таких операциях
+type StandBooking struct {
+	ID        uint      `json:"id" gorm:"primaryKey"`
+	StandID   uint      `json:"stand_id" gorm:"not null;index"`
+	UserID    uint      `json:"user_id" gorm:"not null;index"`
+	Reason    string    `json:"reason"`
+	Exclusive bool      `json:"exclusive" gorm:"not null;default:false"`
+	StartsAt  time.Time `json:"starts_at" gorm:"not null;index"`
+	EndsAt    time.Time `json:"ends_at" gorm:"not null;index"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Blocks проверяет, запрещает ли бронирование операции со стендом пользователю userID
+func (b StandBooking) Blocks(userID uint) bool {
+	return b.Exclusive && b.UserID != userID
+}
+
+// APIKey ключ доступа сервиса (бота, CI, CLI) к API. Хранится только хеш ключа
+type APIKey struct {
+	ID          uint       `json:"id" gorm:"primaryKey"`
+	Name        string     `json:"name" gorm:"not null;uniqueIndex"`
+	Prefix      string     `json:"prefix" gorm:"not null"` // Начало ключа для опознания
+	Hash        string     `json:"-" gorm:"not null;uniqueIndex"`
+	CreatedBy   uint       `json:"created_by" gorm:"not null"`
+	Impersonate bool       `json:"impersonate" gorm:"not null;default:false"` // Ключ бота: действует от имени пользователя из запроса и выпускает токены
+	OwnerID     int64      `json:"owner_id"`                                  // Пользователь, от имени которого действует ключ без Impersonate, 0 — создатель ключа
+	ExpiresAt   *time.Time `json:"expires_at"`                                // Пусто — бессрочный ключ
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+	CreatedAt   time.Time  `json:"created_at"`
+}
+
+// Owner возвращает пользователя, от имени которого действует ключ без Impersonate
+func (k APIKey) Owner() int64 {
+	if k.OwnerID != 0 {
+		return k.OwnerID
+	}
+	return int64(k.CreatedBy)
+}
+
+// Active проверяет, что ключ не отозван и не истек
+func (k APIKey) Active(now time.Time) bool {
+	return k.RevokedAt == nil && (k.ExpiresAt == nil || now.Before(*k.ExpiresAt))
+}
+
+const (
+	AccessRequestPending  = "pending"
+	AccessRequestApproved = "approved"
+	AccessRequestDenied   = "denied"
+)
+
+// AccessRequest заявка неизвестного пользователя Telegram на доступ к боту. Пользователя еще нет в БД,
+// поэтому UserID не ссылается на users
+type AccessRequest struct {
+	ID             uint       `json:"id" gorm:"primaryKey"`
+	UserID         int64      `json:"user_id" gorm:"not null;index;uniqueIndex:idx_access_request_pending,where:status = 'pending'"` // Telegram ID заявителя, на рассмотрении не больше одной заявки
+	Name           string     `json:"name"`
+	Reason         string     `json:"reason"`                                         // Обоснование заявителя
+	Status         string     `json:"status" gorm:"not null;default:'pending';index"` // pending / approved / denied
+	Role           string     `json:"role"`                                           // Роль, выданная при одобрении
+	DecidedBy      uint       `json:"decided_by"`
+	DecisionReason string     `json:"decision_reason"`
+	DecidedAt      *time.Time `json:"decided_at"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
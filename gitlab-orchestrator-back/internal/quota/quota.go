@@ -0,0 +1,172 @@
+package quota
+
+import (
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal/capacity"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/models"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const (
+	DefaultRole = "default" // Квота пользователей без настроенных ролей
+	TeamRole    = "team"    // Квота, которую получает новая команда
+)
+
+// ErrQuotaExceeded возвращается, если новый стенд не помещается в квоту пользователя
+var ErrQuotaExceeded = errors.New("quota exceeded")
+
+// Usage ресурсы, занятые стендами пользователя
+type Usage struct {
+	Stands []string `json:"stands"`
+	VMs    int      `json:"vms"`
+}
+
+// Status квота пользователя с учетом изменений и ее использование
+type Status struct {
+	UserID uint                `json:"user_id,omitempty"`
+	Team   string              `json:"team,omitempty"`
+	Name   string              `json:"name"`
+	Role   string              `json:"role"`
+	Limits config.Quota        `json:"limits"`
+	Usage  Usage               `json:"usage"`
+	Grants []models.QuotaGrant `json:"grants"`
+}
+
+// RoleLimits возвращает квоту ролей пользователя: из нескольких ролей берется наибольший лимит.
+// Если ни одна роль не настроена, используется квота default, а без нее квота не ограничена
+func RoleLimits(user models.User) config.Quota {
+	var limits config.Quota
+	found := false
+	for _, role := range user.RoleNames() {
+		quota, exist := config.Config.Quotas[role]
+		if !exist {
+			continue
+		}
+		if !found {
+			limits, found = quota, true
+			continue
+		}
+		limits.Stands = widest(limits.Stands, quota.Stands)
+		limits.VMs = widest(limits.VMs, quota.VMs)
+		limits.TTLHours = widest(limits.TTLHours, quota.TTLHours)
+	}
+	if !found {
+		return config.Config.Quotas[DefaultRole]
+	}
+	return limits
+}
+
+// widest возвращает больший из лимитов, нулевой лимит означает отсутствие ограничения
+func widest(a int, b int) int {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return max(a, b)
+}
+
+// Limits возвращает квоту роли пользователя с прибавленными действующими изменениями
+func Limits(user models.User, grants []models.QuotaGrant) config.Quota {
+	limits := RoleLimits(user)
+	for _, grant := range grants {
+		limits.Stands = extend(limits.Stands, grant.Stands)
+		limits.VMs = extend(limits.VMs, grant.VMs)
+		limits.TTLHours = extend(limits.TTLHours, grant.TTLHours)
+	}
+	return limits
+}
+
+// extend прибавляет изменение к ограниченному лимиту. Лимит не опускается ниже единицы,
+// чтобы не стать неограниченным
+func extend(limit int, delta int) int {
+	if limit == 0 {
+		return 0
+	}
+	return max(limit+delta, 1)
+}
+
+// GetStatus возвращает квоту пользователя и ее использование
+func GetStatus(user models.User, tx *gorm.DB) (Status, error) {
+	grants, err := database.GetActiveQuotaGrants(uint(user.ID), time.Now(), tx)
+	if err != nil {
+		return Status{}, err
+	}
+	stands, err := database.GetQuotaStands(uint(user.ID), tx)
+	if err != nil {
+		return Status{}, err
+	}
+
+	return Status{
+		UserID: uint(user.ID),
+		Name:   user.Name,
+		Role:   strings.Join(user.RoleNames(), ","),
+		Limits: Limits(user, grants),
+		Usage:  usage(stands),
+		Grants: grants,
+	}, nil
+}
+
+// GetTeamStatus возвращает квоту команды и ее использование всеми стендами команды
+func GetTeamStatus(team models.Team, tx *gorm.DB) (Status, error) {
+	stands, err := database.GetTeamStands(team.ID, tx)
+	if err != nil {
+		return Status{}, err
+	}
+	return Status{
+		Team:   team.Name,
+		Limits: config.Quota{Stands: team.MaxStands, VMs: team.MaxVMs, TTLHours: team.MaxTTLHours},
+		Usage:  usage(stands),
+		Grants: []models.QuotaGrant{},
+	}, nil
+}
+
+// GetStandStatus возвращает квоту, в которой учитывается стенд: квоту его команды или его автора
+func GetStandStatus(stand models.Stand, tx *gorm.DB) (Status, error) {
+	if stand.TeamID != nil {
+		team, err := database.GetTeamByID(*stand.TeamID, tx)
+		if err != nil {
+			return Status{}, err
+		}
+		return GetTeamStatus(*team, tx)
+	}
+	user, err := database.GetUserByID(stand.UserID)
+	if err != nil {
+		return Status{}, err
+	}
+	return GetStatus(*user, tx)
+}
+
+func usage(stands []models.Stand) Usage {
+	result := Usage{Stands: []string{}}
+	for _, stand := range stands {
+		result.Stands = append(result.Stands, stand.Name)
+		result.VMs += capacity.Cost(stand.Type).VMs
+	}
+	return result
+}
+
+// Check проверяет, помещается ли в квоту новый стенд типа standType со сроком жизни ttlHours,
+// и возвращает срок жизни стенда: если он не задан, берется максимальный по квоте
+func (s Status) Check(standType string, ttlHours int) (int, error) {
+	if s.Limits.Stands > 0 && len(s.Usage.Stands)+1 > s.Limits.Stands {
+		return 0, fmt.Errorf("%w: active stands %d of %d", ErrQuotaExceeded, len(s.Usage.Stands), s.Limits.Stands)
+	}
+	cost := capacity.Cost(standType)
+	if s.Limits.VMs > 0 && s.Usage.VMs+cost.VMs > s.Limits.VMs {
+		return 0, fmt.Errorf("%w: stand needs %d VMs, %d of %d VMs are used", ErrQuotaExceeded, cost.VMs, s.Usage.VMs, s.Limits.VMs)
+	}
+	if s.Limits.TTLHours > 0 {
+		if ttlHours == 0 {
+			return s.Limits.TTLHours, nil
+		}
+		if ttlHours > s.Limits.TTLHours {
+			return 0, fmt.Errorf("%w: stand TTL %dh is longer than %dh", ErrQuotaExceeded, ttlHours, s.Limits.TTLHours)
+		}
+	}
+	return ttlHours, nil
+}
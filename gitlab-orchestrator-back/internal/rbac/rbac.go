@@ -0,0 +1,131 @@
+package rbac
+
+import (
+	"fmt"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/models"
+	"sort"
+)
+
+// Права пользователей. Права на собственные стенды, стенды команды и стенды соавторов выдаются
+// владельцем стенда (models.PermissionView/Operate/Admin), права ниже действуют на все ресурсы
+const (
+	StandCreate     = "stand:create"      // Создание и импорт стендов, расписания и команды
+	StandOperate    = "stand:operate"     // Управление доступными пользователю стендами, командами и расписаниями
+	StandApprove    = "stand:approve"     // Согласование стендов
+	StandOperateAny = "stand:operate:any" // Управление любым стендом и решения по manual-джобам
+	StandAdminAny   = "stand:admin:any"   // Выдача доступа, передача и снятие блокировки любого стенда
+	SchedulesManage = "schedules:manage"  // Изменение чужих расписаний
+	FleetManage     = "fleet:manage"      // Массовые обновления
+	PoolsManage     = "pools:manage"      // Пулы ресурсов и осиротевшие ресурсы
+	QuotasManage    = "quotas:manage"     // Квоты пользователей и команд
+	TeamsManage     = "teams:manage"      // Управление любой командой
+	UsersManage     = "users:manage"      // Пользователи и их роли
+	KeysManage      = "keys:manage"       // API-ключи сервисов
+
+	All = "*" // Все права
+)
+
+// Permissions все известные права
+var Permissions = []string{
+	StandCreate, StandOperate, StandApprove, StandOperateAny, StandAdminAny, SchedulesManage,
+	FleetManage, PoolsManage, QuotasManage, TeamsManage, UsersManage, KeysManage,
+}
+
+// DefaultRolePermissions права встроенных ролей, ROLE_PERMISSIONS может их переопределить
+var DefaultRolePermissions = map[string][]string{
+	"admin": {All},
+	"user":  {StandCreate, StandOperate},
+}
+
+// impliedPermissions права, которые включают другие: управление любым стендом включает управление доступными
+var impliedPermissions = map[string][]string{
+	StandOperateAny: {StandOperate},
+	StandAdminAny:   {StandOperate},
+}
+
+// Valid проверяет, что permission — известное право или *
+func Valid(permission string) bool {
+	if permission == All {
+		return true
+	}
+	for _, known := range Permissions {
+		if known == permission {
+			return true
+		}
+	}
+	return false
+}
+
+// Roles возвращает все известные роли: встроенные и из ROLE_PERMISSIONS
+func Roles() []string {
+	var roles []string
+	for role := range DefaultRolePermissions {
+		roles = append(roles, role)
+	}
+	for role := range config.Config.RolePermissions {
+		if _, builtin := DefaultRolePermissions[role]; !builtin {
+			roles = append(roles, role)
+		}
+	}
+	sort.Strings(roles)
+	return roles
+}
+
+// ValidRole проверяет, что role — встроенная роль или роль из ROLE_PERMISSIONS
+func ValidRole(role string) bool {
+	if _, exist := DefaultRolePermissions[role]; exist {
+		return true
+	}
+	_, exist := config.Config.RolePermissions[role]
+	return exist
+}
+
+// RolePermissions возвращает права роли из ROLE_PERMISSIONS или встроенные права роли
+func RolePermissions(role string) []string {
+	if permissions, exist := config.Config.RolePermissions[role]; exist {
+		return permissions
+	}
+	return DefaultRolePermissions[role]
+}
+
+// Can проверяет, дает ли одна из ролей пользователя право permission
+func Can(user models.User, permission string) bool {
+	for _, role := range user.RoleNames() {
+		for _, granted := range RolePermissions(role) {
+			if granted == All || granted == permission {
+				return true
+			}
+			for _, implied := range impliedPermissions[granted] {
+				if implied == permission {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// UserPermissions возвращает права пользователя по всем его ролям
+func UserPermissions(user models.User) []string {
+	var result []string
+	for _, permission := range Permissions {
+		if Can(user, permission) {
+			result = append(result, permission)
+		}
+	}
+	sort.Strings(result)
+	return result
+}
+
+// Validate проверяет, что в ROLE_PERMISSIONS указаны только известные права
+func Validate() error {
+	for role, permissions := range config.Config.RolePermissions {
+		for _, permission := range permissions {
+			if !Valid(permission) {
+				return fmt.Errorf("unknown permission %q of role %s in ROLE_PERMISSIONS", permission, role)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package rbac
+
+import (
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/models"
+	"testing"
+)
+
+// userWithRoles возвращает пользователя с ролями roles
+func userWithRoles(roles ...string) models.User {
+	user := models.User{ID: 1, Name: "user"}
+	for _, role := range roles {
+		user.Roles = append(user.Roles, models.Role{Name: role})
+	}
+	return user
+}
+
+func TestCan(t *testing.T) {
+	custom := map[string][]string{
+		"operator": {StandOperateAny},
+		"keeper":   {StandAdminAny, KeysManage},
+		"viewer":   {},
+	}
+
+	tests := []struct {
+		name            string
+		rolePermissions map[string][]string // ROLE_PERMISSIONS
+		user            models.User
+		permission      string
+		want            bool
+	}{
+		{name: "admin получает любое право", user: userWithRoles("admin"), permission: UsersManage, want: true},
+		{name: "user создает стенды", user: userWithRoles("user"), permission: StandCreate, want: true},
+		{name: "user управляет доступными стендами", user: userWithRoles("user"), permission: StandOperate, want: true},
+		{name: "user не управляет чужими стендами", user: userWithRoles("user"), permission: StandOperateAny, want: false},
+		{name: "user не согласует стенды", user: userWithRoles("user"), permission: StandApprove, want: false},
+		{name: "роль из конфигурации", rolePermissions: custom, user: userWithRoles("keeper"), permission: KeysManage, want: true},
+		{name: "stand:operate:any включает stand:operate", rolePermissions: custom, user: userWithRoles("operator"), permission: StandOperate, want: true},
+		{name: "stand:admin:any включает stand:operate", rolePermissions: custom, user: userWithRoles("keeper"), permission: StandOperate, want: true},
+		{name: "stand:admin:any не включает stand:operate:any", rolePermissions: custom, user: userWithRoles("keeper"), permission: StandOperateAny, want: false},
+		{name: "права складываются по ролям", rolePermissions: custom, user: userWithRoles("viewer", "operator"), permission: StandOperateAny, want: true},
+		{name: "роль без прав", rolePermissions: custom, user: userWithRoles("viewer"), permission: StandCreate, want: false},
+		{
+			name:            "ROLE_PERMISSIONS переопределяет встроенную роль",
+			rolePermissions: map[string][]string{"user": {StandCreate}},
+			user:            userWithRoles("user"),
+			permission:      StandOperate,
+			want:            false,
+		},
+		{name: "неизвестная роль", user: userWithRoles("ghost"), permission: StandCreate, want: false},
+		{name: "пользователь без ролей", user: userWithRoles(), permission: StandCreate, want: false},
+	}
+
+	previous := config.Config.RolePermissions
+	defer func() { config.Config.RolePermissions = previous }()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config.Config.RolePermissions = tt.rolePermissions
+			if got := Can(tt.user, tt.permission); got != tt.want {
+				t.Errorf("Can(%v, %s) = %v, ожидалось %v", tt.user.RoleNames(), tt.permission, got, tt.want)
+			}
+		})
+	}
+}
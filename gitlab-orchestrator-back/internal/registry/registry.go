@@ -0,0 +1,155 @@
+package registry
+
+import (
+	"encoding/json"
+	"fmt"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// Типы реестра образов
+const (
+	TypeNone   = "none"
+	TypeFile   = "file"
+	TypeDocker = "docker"
+)
+
+// TagLister возвращает доступные теги образа продукта
+type TagLister interface {
+	ListTags(product string) ([]string, error)
+}
+
+// New создает реестр по конфигурации. Если реестр не настроен, возвращает nil и версии не проверяются
+func New() (TagLister, error) {
+	switch config.Config.RegistryType {
+	case TypeFile:
+		return NewFileTagLister(config.Config.RegistryTagsFile), nil
+	case TypeDocker:
+		return NewDockerTagLister(config.Config.RegistryURL, config.Config.RegistryImageTemplate, config.Config.RegistryToken), nil
+	case TypeNone, "":
+		return nil, nil
+	}
+	return nil, fmt.Errorf("unknown registry type %q", config.Config.RegistryType)
+}
+
+// StaticTagLister реестр с заранее известными тегами: код продукта -> теги
+type StaticTagLister map[string][]string
+
+func (l StaticTagLister) ListTags(product string) ([]string, error) {
+	tags, ok := l[product]
+	if !ok {
+		return nil, fmt.Errorf("product %s not found in registry", product)
+	}
+	return tags, nil
+}
+
+// FileTagLister читает теги из JSON-файла вида {"code": ["1.4.1", "1.4.2"]} при каждом запросе
+type FileTagLister struct {
+	Path string
+}
+
+func NewFileTagLister(path string) *FileTagLister {
+	return &FileTagLister{Path: path}
+}
+
+func (l *FileTagLister) ListTags(product string) ([]string, error) {
+	data, err := os.ReadFile(l.Path)
+	if err != nil {
+		return nil, fmt.Errorf("ошибка при чтении файла тегов %s: %v", l.Path, err)
+	}
+	var tags StaticTagLister
+	if err := json.Unmarshal(data, &tags); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе файла тегов %s: %v", l.Path, err)
+	}
+	return tags.ListTags(product)
+}
+
+// DockerTagLister получает теги из реестра по Docker Registry HTTP API v2
+type DockerTagLister struct {
+	BaseURL       string
+	ImageTemplate string // Имя образа для кода продукта, %s заменяется кодом
+	Token         string
+	client        *http.Client
+}
+
+func NewDockerTagLister(baseURL string, imageTemplate string, token string) *DockerTagLister {
+	return &DockerTagLister{
+		BaseURL:       strings.TrimRight(baseURL, "/"),
+		ImageTemplate: imageTemplate,
+		Token:         token,
+		client:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (l *DockerTagLister) ListTags(product string) ([]string, error) {
+	image := strings.ReplaceAll(l.ImageTemplate, "%s", product)
+	url := fmt.Sprintf("%s/v2/%s/tags/list", l.BaseURL, image)
+	logger.DebugfWithCaller("Получение тегов образа %s", image)
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if l.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+l.Token)
+	}
+
+	resp, err := l.client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer func(Body io.ReadCloser) {
+		if err := Body.Close(); err != nil {
+			logger.ErrorWithCaller("Не удалось закрыть тело ответа")
+		}
+	}(resp.Body)
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, fmt.Errorf("product %s not found in registry", product)
+	}
+	if resp.StatusCode >= 400 {
+		return nil, fmt.Errorf("unexpected registry response: %s", resp.Status)
+	}
+
+	var body struct {
+		Tags []string `json:"tags"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("ошибка при декодировании ответа реестра: %v", err)
+	}
+	return body.Tags, nil
+}
+
+// ValidateVersions проверяет, что закрепленные версии продуктов есть в реестре.
+// Без реестра версии не проверяются
+func ValidateVersions(lister TagLister, products models.ProductList) error {
+	if lister == nil {
+		return nil
+	}
+	for _, product := range products {
+		if product.Version == "" {
+			continue
+		}
+		tags, err := lister.ListTags(product.Code)
+		if err != nil {
+			return err
+		}
+		found := false
+		for _, tag := range tags {
+			if tag == product.Version {
+				found = true
+				break
+			}
+		}
+		if !found {
+			return fmt.Errorf("version %s of product %s not found in registry", product.Version, product.Code)
+		}
+	}
+	return nil
+}
@@ -2,35 +2,117 @@ package routes
 
 import (
 	"gitlab-orchestrator-back/internal/handlers"
+	"gitlab-orchestrator-back/internal/middleware"
+	"gitlab-orchestrator-back/internal/rbac"
 
 	"github.com/labstack/echo/v4"
 )
 
 // SetupRoutes configures all API routes for the application
 func SetupRoutes(e *echo.Echo, h *handlers.Handler) {
-	// API v1 group
-	api := e.Group("/api/v1")
+	// API v1 group, every route except health check requires an API key or a user token
+	api := e.Group("/api/v1", middleware.AuthMiddleware)
 
 	// Health check
 	api.GET("/health", h.HealthCheck)
 
+	// Auth routes
+	api.POST("/auth/token", h.IssueToken, middleware.ServiceOnly)
+	api.GET("/auth/users", h.GetAllUsers, middleware.ServiceOnly) // Bot allowlist
+
 	// User routes
-	api.GET("/users", h.GetAllUsers)
-	// api.GET("/users/:id", h.GetUserByID)
-	// api.POST("/users", h.CreateUser)
-	// api.PUT("/users/:id", h.UpdateUser)
-	// api.DELETE("/users/:id", h.DeleteUser)
+	users := middleware.RequirePermission(rbac.UsersManage)
+	api.GET("/users", h.GetAllUsers, users)
+	api.GET("/users/:id/quota", h.GetUserQuota)
+	api.GET("/users/:id", h.GetUser, users)
+	api.POST("/users", h.CreateUser, users)
+	api.PUT("/users/:id", h.UpdateUser, users)
+	api.DELETE("/users/:id", h.DeleteUser, users)
+
+	// Access request routes, unknown Telegram users ask for access through the bot
+	api.POST("/access-requests", h.CreateAccessRequest, middleware.ServiceOnly)
+	api.GET("/access-requests", h.GetAccessRequests, users)
+	api.POST("/access-requests/:id/approve", h.ApproveAccessRequest, users)
+	api.POST("/access-requests/:id/deny", h.DenyAccessRequest, users)
 
 	// Subos (products) routes
 	api.GET("/subos", h.GetSubos) // Simple map of code->name
+	api.GET("/subos/:code/versions", h.GetProductVersions)
+
+	// Regions of resource pools
+	api.GET("/regions", h.GetRegions)
 
-	// Stand routes
+	// Stand routes. Route permissions only admit the user, access to a particular stand is checked by handlers
+	create := middleware.RequirePermission(rbac.StandCreate)
+	operate := middleware.RequirePermission(rbac.StandOperate)
 	//api.POST("/stands/start", h.StartCreateStand)
-	api.POST("/stands", h.CreateStand)
+	api.POST("/stands", h.CreateStand, create)
+	api.POST("/stands/import", h.ImportStand, create)
+	api.GET("/stands/approvals", h.GetStandApprovals, middleware.RequirePermission(rbac.StandApprove))
+	api.POST("/stands/:name/approve", h.ApproveStand, middleware.RequirePermission(rbac.StandApprove))
+	api.POST("/stands/:name/reject", h.RejectStand, middleware.RequirePermission(rbac.StandApprove))
+	api.POST("/stands/:name/rebuild", h.RebuildStand, operate)
+	api.POST("/stands/:name/retry", h.RetryStand, operate)
+	api.GET("/stands/:name/pipelines", h.GetStandPipelines)
 	api.GET("/stands", h.GetAllStands)
-	// api.GET("/stands/:name/deployments", h.GetStandDeployments)
+	api.GET("/stands/:name/deployments", h.GetStandDeployments)
+	api.POST("/stands/:name/products/:code/upgrade", h.UpgradeProduct, operate)
+	api.POST("/stands/:name/products/:code/rollback", h.RollbackProduct, operate)
+	api.GET("/stands/:name/products/:code/history", h.GetProductHistory)
+	api.PUT("/stands/:name/labels", h.UpdateStandLabels, operate)
+	api.POST("/stands/:name/extend", h.ExtendStand, operate)
+	api.GET("/stands/:name/collaborators", h.GetStandCollaborators)
+	api.POST("/stands/:name/collaborators", h.ShareStand, operate)
+	api.DELETE("/stands/:name/collaborators/:memberID", h.UnshareStand, operate)
+	api.POST("/stands/:name/transfer", h.TransferStand, operate)
+	api.GET("/stands/:name/bookings", h.GetStandBookings)
+	api.POST("/stands/:name/bookings", h.CreateStandBooking, operate)
+	api.DELETE("/stands/:name/bookings/:id", h.DeleteStandBooking, operate)
+	api.POST("/stands/:name/unlock", h.UnlockStand, operate)
+	// TODO: отмена и удаление стенда с проверкой checkStandAccess, чтобы ими могли пользоваться участники команды
+
+	// Team routes
+	api.GET("/teams", h.GetTeams)
+	api.POST("/teams", h.CreateTeam, create)
+	api.GET("/teams/:name", h.GetTeam)
+	api.PUT("/teams/:name", h.UpdateTeam, operate)
+	api.DELETE("/teams/:name", h.DeleteTeam, operate)
+	api.PUT("/teams/:name/members/:memberID", h.SaveTeamMember, operate)
+	api.DELETE("/teams/:name/members/:memberID", h.DeleteTeamMember, operate)
+
+	// Schedule routes
+	api.GET("/schedules", h.GetSchedules)
+	api.POST("/schedules", h.CreateSchedule, create)
+	api.PUT("/schedules/:id", h.UpdateSchedule, operate)
+	api.DELETE("/schedules/:id", h.DeleteSchedule, operate)
+
+	// Fleet operation routes
+	fleet := middleware.RequirePermission(rbac.FleetManage)
+	api.GET("/fleet", h.GetFleetOperations, fleet)
+	api.POST("/fleet", h.CreateFleetOperation, fleet)
+	api.GET("/fleet/:id", h.GetFleetOperation, fleet)
+	api.POST("/fleet/:id/:action", h.ControlFleetOperation, fleet)
+
+	// Job routes
+	api.POST("/jobs/:id/decision", h.DecideJob, operate)
+
+	// Step routes
+	api.GET("/steps/:id/plan", h.GetStepPlan)
+
+	// Admin routes
+	pools := middleware.RequirePermission(rbac.PoolsManage)
+	quotas := middleware.RequirePermission(rbac.QuotasManage)
+	keys := middleware.RequirePermission(rbac.KeysManage)
+	api.GET("/admin/orphans", h.GetOrphans, pools)
+	api.GET("/admin/pools", h.GetResourcePools, pools)
+	api.PUT("/admin/pools/:name", h.SaveResourcePool, pools)
+	api.GET("/admin/quotas", h.GetQuotas, quotas)
+	api.POST("/admin/quotas/grants", h.CreateQuotaGrant, quotas)
+	api.GET("/admin/keys", h.GetAPIKeys, keys)
+	api.POST("/admin/keys", h.CreateAPIKey, keys)
+	api.DELETE("/admin/keys/:id", h.RevokeAPIKey, keys)
 
 	// notify steps
-	api.GET("/notify", h.GetNotification)
-	api.POST("/notify", h.UpdateNotification)
+	api.GET("/notify", h.GetNotification, middleware.ServiceOnly)
+	api.POST("/notify", h.UpdateNotification, middleware.ServiceOnly)
 }
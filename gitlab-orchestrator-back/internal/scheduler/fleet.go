@@ -0,0 +1,224 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/upgrade"
+	"time"
+)
+
+// Статусы массовой операции
+const (
+	FleetStatusRunning = "running"
+	FleetStatusPaused  = "paused"
+	FleetStatusAborted = "aborted"
+	FleetStatusSuccess = "success"
+	FleetStatusFailed  = "failed"
+)
+
+// Статусы стенда массовой операции, кроме pending, running, success и error
+const (
+	TargetStatusSkipped  = "skipped"
+	TargetStatusCanceled = "canceled"
+)
+
+// FleetProgress сводка по стендам массовой операции
+type FleetProgress struct {
+	Total    int `json:"total"`
+	Pending  int `json:"pending"`
+	Running  int `json:"running"`
+	Success  int `json:"success"`
+	Failed   int `json:"failed"`
+	Skipped  int `json:"skipped"`
+	Canceled int `json:"canceled"`
+}
+
+// Progress считает стенды массовой операции по статусам
+func Progress(targets []models.FleetTarget) FleetProgress {
+	progress := FleetProgress{Total: len(targets)}
+	for _, target := range targets {
+		switch target.Status {
+		case StatusPending:
+			progress.Pending++
+		case StatusRunning:
+			progress.Running++
+		case StatusSuccess:
+			progress.Success++
+		case StatusError:
+			progress.Failed++
+		case TargetStatusSkipped:
+			progress.Skipped++
+		case TargetStatusCanceled:
+			progress.Canceled++
+		}
+	}
+	return progress
+}
+
+// CheckFleetOperations отслеживает пайплайны стендов массовых операций и запускает следующие пачки
+func (r *Runner) CheckFleetOperations() error {
+	// Стенды приостановленных и прерванных операций тоже доходят до конца
+	var running []models.FleetTarget
+	if err := r.db.Where("status = ?", StatusRunning).Find(&running).Error; err != nil {
+		return fmt.Errorf("ошибка при получении запущенных стендов массовых операций: %v", err)
+	}
+	for i := range running {
+		r.refreshFleetTarget(&running[i])
+	}
+
+	operations, err := database.GetFleetOperationsByStatus([]string{FleetStatusRunning}, r.db)
+	if err != nil {
+		return err
+	}
+	for _, operation := range operations {
+		if err := r.advanceFleetOperation(&operation); err != nil {
+			logger.ErrorfWithCaller("Ошибка при обработке массовой операции %d: %v", operation.ID, err)
+		}
+	}
+	return nil
+}
+
+// refreshFleetTarget обновляет статус запущенного стенда по его пайплайну обновления
+func (r *Runner) refreshFleetTarget(target *models.FleetTarget) {
+	pipeline, err := database.GetPipelineByID(target.PipelineID, r.db)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении пайплайна стенда %s: %v", target.StandName, err)
+		return
+	}
+
+	switch pipeline.Status {
+	case StatusSuccess:
+		r.finishFleetTarget(target, StatusSuccess, "")
+	case StatusError, StatusFailed, StatusCanceled:
+		r.finishFleetTarget(target, StatusError, "пайплайн обновления завершился с ошибкой")
+	}
+}
+
+// advanceFleetOperation проверяет порог ошибок, завершает операцию или запускает следующую пачку,
+// когда предыдущая завершена
+func (r *Runner) advanceFleetOperation(operation *models.FleetOperation) error {
+	progress := Progress(operation.Targets)
+
+	if operation.MaxFailures > 0 && progress.Failed >= operation.MaxFailures {
+		r.cancelPendingTargets(operation)
+		message := fmt.Sprintf("Остановлено: ошибок обновления %d при пороге %d", progress.Failed, operation.MaxFailures)
+		return r.finishFleetOperation(operation, FleetStatusFailed, message)
+	}
+	if progress.Running > 0 {
+		return nil
+	}
+	if progress.Pending == 0 {
+		status := FleetStatusSuccess
+		if progress.Failed > 0 {
+			status = FleetStatusFailed
+		}
+		message := fmt.Sprintf("Обновлено %d из %d стендов, ошибок %d, пропущено %d",
+			progress.Success, progress.Total, progress.Failed, progress.Skipped)
+		return r.finishFleetOperation(operation, status, message)
+	}
+
+	batch := 0
+	for _, target := range operation.Targets {
+		if target.Status == StatusPending && (batch == 0 || target.Batch < batch) {
+			batch = target.Batch
+		}
+	}
+	logger.InfofWithCaller("Массовая операция %d: запуск пачки %d", operation.ID, batch)
+	for i := range operation.Targets {
+		target := &operation.Targets[i]
+		if target.Status == StatusPending && target.Batch == batch {
+			r.startFleetTarget(operation, target)
+		}
+	}
+	return nil
+}
+
+// startFleetTarget запускает обновление продукта на стенде. Занятый, заблокированный или изменившийся стенд пропускается
+func (r *Runner) startFleetTarget(operation *models.FleetOperation, target *models.FleetTarget) {
+	stand, err := database.GetStandByName(target.StandName, r.db)
+	if errors.Is(err, database.ErrStandNotFound) {
+		r.finishFleetTarget(target, TargetStatusSkipped, "стенд удален")
+		return
+	}
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении стенда %s: %v", target.StandName, err)
+		return
+	}
+	if _, active := r.activeStands.Load(stand.Name); active || (stand.Status != StatusSuccess && stand.Status != StatusError) {
+		r.finishFleetTarget(target, TargetStatusSkipped, "стенд занят: "+stand.Status)
+		return
+	}
+	if booking, err := database.GetActiveBooking(stand.ID, time.Now(), r.db); err == nil && booking.Blocks(operation.UserID) {
+		r.finishFleetTarget(target, TargetStatusSkipped, "стенд заблокирован до "+booking.EndsAt.Format("02.01.2006 15:04"))
+		return
+	}
+	products, err := database.GetProductsFromStand(stand.Name, r.db)
+	if err != nil || !internal.ContainsString(products, operation.Product) {
+		r.finishFleetTarget(target, TargetStatusSkipped, "продукта нет на стенде")
+		return
+	}
+
+	pipeline, err := upgrade.Start(r.gitlab, stand, operation.Product, operation.Tag)
+	if err != nil {
+		r.finishFleetTarget(target, StatusError, err.Error())
+		return
+	}
+
+	now := time.Now()
+	target.Status = StatusRunning
+	target.PipelineID = pipeline.ID
+	target.StartedAt = &now
+	if err := r.db.Save(target).Error; err != nil {
+		logger.ErrorfWithCaller("Ошибка при сохранении стенда %s массовой операции %d: %v", target.StandName, operation.ID, err)
+	}
+}
+
+// finishFleetTarget записывает итог обновления стенда массовой операции
+func (r *Runner) finishFleetTarget(target *models.FleetTarget, status string, message string) {
+	now := time.Now()
+	target.Status = status
+	target.Message = message
+	target.FinishedAt = &now
+	if err := r.db.Save(target).Error; err != nil {
+		logger.ErrorfWithCaller("Ошибка при сохранении стенда %s массовой операции %d: %v", target.StandName, target.OperationID, err)
+	}
+}
+
+// cancelPendingTargets отменяет еще не запущенные стенды операции
+func (r *Runner) cancelPendingTargets(operation *models.FleetOperation) {
+	for i := range operation.Targets {
+		if operation.Targets[i].Status == StatusPending {
+			r.finishFleetTarget(&operation.Targets[i], TargetStatusCanceled, "")
+		}
+	}
+}
+
+// finishFleetOperation завершает массовую операцию и уведомляет ее инициатора
+func (r *Runner) finishFleetOperation(operation *models.FleetOperation, status string, message string) error {
+	logger.InfofWithCaller("Массовая операция %d завершена со статусом %s: %s", operation.ID, status, message)
+
+	now := time.Now()
+	tx := r.db.Begin()
+	if err := tx.Model(operation).Updates(map[string]interface{}{
+		"status":      status,
+		"message":     message,
+		"finished_at": now,
+	}).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+	notifyStatus := StatusSuccess
+	if status != FleetStatusSuccess {
+		notifyStatus = StatusError
+	}
+	text := fmt.Sprintf("Обновление %s на стендах: %s", internal.DeploymentImage(operation.Product, operation.Tag), message)
+	if err := database.CreateFleetNotify(*operation, notifyStatus, text, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
@@ -0,0 +1,112 @@
+package scheduler
+
+import (
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"regexp"
+	"time"
+)
+
+const (
+	OrphanKindBranch      = "branch"
+	OrphanKindEnvironment = "environment"
+	OrphanKindVariables   = "variables"
+)
+
+// ScanOrphans ищет ветки, окружения и переменные PRODUCTS, названные по схеме стенда,
+// для которых нет стенда в БД, и при включенной очистке удаляет их после периода ожидания
+func (r *Runner) ScanOrphans() error {
+	logger.InfoWithCaller("Поиск осиротевших ресурсов GitLab")
+	pattern, err := regexp.Compile(config.Config.StandNamePattern)
+	if err != nil {
+		return fmt.Errorf("некорректный шаблон имени стенда: %v", err)
+	}
+
+	standNames, err := database.GetManagedStandNames(r.db)
+	if err != nil {
+		return err
+	}
+	managed := make(map[string]bool, len(standNames))
+	for _, name := range standNames {
+		managed[name] = true
+	}
+
+	listings := []struct {
+		kind string
+		list func() ([]string, error)
+	}{
+		{kind: OrphanKindBranch, list: r.gitlab.ListBranches},
+		{kind: OrphanKindEnvironment, list: r.gitlab.ListEnvironments},
+		{kind: OrphanKindVariables, list: func() ([]string, error) { return r.gitlab.ListVariableScopes("PRODUCTS") }},
+	}
+
+	now := time.Now()
+	for _, listing := range listings {
+		names, err := listing.list()
+		if err != nil {
+			return fmt.Errorf("ошибка при получении ресурсов %s: %v", listing.kind, err)
+		}
+
+		var orphans []string
+		for _, name := range names {
+			if pattern.MatchString(name) && !managed[name] {
+				orphans = append(orphans, name)
+			}
+		}
+
+		if err := database.SyncOrphanResources(listing.kind, orphans, now, r.db); err != nil {
+			return err
+		}
+		logger.InfofWithCaller("Найдено осиротевших ресурсов %s: %d", listing.kind, len(orphans))
+	}
+
+	if config.Config.OrphanCleanupEnabled {
+		r.cleanupOrphans(now)
+	}
+	return nil
+}
+
+// cleanupOrphans удаляет из GitLab ресурсы, осиротевшие дольше периода ожидания
+func (r *Runner) cleanupOrphans(now time.Time) {
+	orphans, err := database.GetOrphanResources(false, r.db)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при получении осиротевших ресурсов: %v", err)
+		return
+	}
+
+	for _, orphan := range orphans {
+		if now.Sub(orphan.FirstSeenAt) < config.Config.OrphanGracePeriod {
+			continue
+		}
+		// Стенд мог быть создан после сканирования
+		if _, active := r.activeStands.Load(orphan.Name); active {
+			continue
+		}
+		if _, err := database.GetStandByName(orphan.Name, r.db); !errors.Is(err, database.ErrStandNotFound) {
+			continue
+		}
+
+		var removeErr error
+		switch orphan.Kind {
+		case OrphanKindBranch:
+			removeErr = r.gitlab.DeleteBranch(orphan.Name)
+		case OrphanKindEnvironment:
+			removeErr = r.gitlab.DeleteEnvironment(orphan.Name)
+		case OrphanKindVariables:
+			removeErr = r.gitlab.DeleteVariablesFromEnvironment(orphan.Name)
+		}
+		if removeErr != nil {
+			logger.ErrorfWithCaller("Ошибка при удалении ресурса %s %s: %v", orphan.Kind, orphan.Name, removeErr)
+			continue
+		}
+
+		if err := database.MarkOrphanResourceRemoved(&orphan, r.db); err != nil {
+			logger.ErrorfWithCaller("%v", err)
+			continue
+		}
+		logger.InfofWithCaller("Осиротевший ресурс %s %s удален", orphan.Kind, orphan.Name)
+	}
+}
@@ -0,0 +1,107 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"gitlab-orchestrator-back/internal/terraform"
+)
+
+// capturePlan сохраняет сводку завершенной джобы terraform plan и отправляет ее участникам стенда.
+// Сводка только информирует и не задерживает джобы шага: apply, который не является manual-джобой
+// из JOB_APPROVAL_RULES, может начаться до нее, а если план получить не удалось, шаг продолжается без сводки
+func (r *Runner) capturePlan(step models.Step) error {
+	template, ok := internal.TemplateByOrder(step.Order)
+	if !ok || template.PlanJob == "" {
+		return nil
+	}
+
+	current, err := database.GetStepByID(step.ID, r.db)
+	if err != nil {
+		return err
+	}
+	if len(current.PlanSummary) > 0 {
+		return nil // Сводка уже отправлена
+	}
+
+	var jobs []models.Job
+	if err := r.db.Where("step_id = ? AND status = ?", step.ID, StatusSuccess).
+		Order("\"order\" asc").Find(&jobs).Error; err != nil {
+		return err
+	}
+	var planJob *models.Job
+	for i := range jobs {
+		if template.IsPlanJob(jobs[i].Name) {
+			planJob = &jobs[i]
+			break
+		}
+	}
+	if planJob == nil {
+		return nil
+	}
+
+	// Ошибки получения плана не останавливают создание стенда, сводка только информирует
+	trace, err := r.gitlab.GetJobTrace(planJob.GitlabJobID)
+	if err != nil {
+		logger.ErrorfWithCaller("Не удалось получить лог джобы plan %d: %v", planJob.GitlabJobID, err)
+		return nil
+	}
+	summary, err := r.parsePlan(*planJob, trace)
+	if err != nil {
+		logger.ErrorfWithCaller("Не удалось разобрать план джобы %d: %v", planJob.GitlabJobID, err)
+		return nil
+	}
+	summaryJSON, err := json.Marshal(summary)
+	if err != nil {
+		return err
+	}
+
+	stand, err := database.GetStandByPipelineID(step.PipelineID, r.db)
+	if err != nil {
+		return err
+	}
+
+	tx := r.db.Begin()
+	if err := tx.Model(current).Updates(map[string]interface{}{
+		"plan_summary": summaryJSON,
+		"plan_raw":     trace,
+	}).Error; err != nil {
+		tx.Rollback()
+		return fmt.Errorf("ошибка при сохранении плана шага %d: %v", step.ID, err)
+	}
+	if err := database.CreateStandMembersNotify(*stand, models.StepState{
+		StandName: stand.Name,
+		StepName:  step.Name,
+		Status:    StatusSuccess,
+		Kind:      models.NotifyKindPlan,
+		StepID:    step.ID,
+		Order:     step.Order,
+		Message:   summary.Format(),
+	}, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("ошибка при коммите транзакции: %v", err)
+	}
+
+	logger.InfofWithCaller("План terraform стенда %s сохранен: +%d ~%d -%d", stand.Name,
+		summary.Total.Add, summary.Total.Change, summary.Total.Destroy)
+	return nil
+}
+
+// parsePlan разбирает JSON-артефакт плана, если он настроен, иначе лог джобы
+func (r *Runner) parsePlan(planJob models.Job, trace string) (*terraform.PlanSummary, error) {
+	if config.Config.TerraformPlanArtifact == "" {
+		return terraform.ParsePlanTrace(trace)
+	}
+	artifact, err := r.gitlab.GetJobArtifact(planJob.GitlabJobID, config.Config.TerraformPlanArtifact)
+	if err != nil {
+		return nil, err
+	}
+	return terraform.ParsePlanJSON(artifact)
+}
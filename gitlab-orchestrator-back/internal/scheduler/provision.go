@@ -0,0 +1,546 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"strconv"
+	"strings"
+)
+
+const (
+	ActionStatusInProgress  = "in_progress"
+	ActionStatusDone        = "done"
+	ActionStatusCompensated = "compensated"
+
+	ActionLint        = "lint"
+	ActionBranch      = "branch"
+	ActionEnvironment = "environment"
+	ActionVariables   = "variables"
+	ActionPipeline    = "pipeline"
+	ActionTrigger     = "trigger"
+	ActionJobs        = "jobs"
+
+	provisionStepName = "Подготовка стенда"
+)
+
+// errInvalidCI означает ошибку в конфигурации CI, которую не исправят повторные попытки подготовки
+var errInvalidCI = errors.New("некорректная конфигурация CI")
+
+// provisionResult описывает результат выполнения действия подготовки стенда
+type provisionResult struct {
+	created bool   // ресурс создан действием и должен быть удален при компенсации
+	ref     string // идентификатор созданного ресурса
+}
+
+// provisionAction описывает идемпотентное действие подготовки стенда и компенсирующее его действие.
+// started — запись о том же действии, начатом прошлой попыткой, которая могла успеть создать ресурс
+// в GitLab, или nil, если действие выполняется впервые
+type provisionAction struct {
+	name       string
+	do         func(stand models.Stand, done map[string]models.ProvisionAction, started *models.ProvisionAction) (provisionResult, error)
+	compensate func(stand models.Stand, action models.ProvisionAction) error
+}
+
+// provisionActions возвращает действия подготовки стенда в порядке выполнения
+func (r *Runner) provisionActions() []provisionAction {
+	return []provisionAction{
+		{name: ActionLint, do: r.lintCI},
+		{name: ActionBranch, do: r.ensureBranch, compensate: r.removeBranch},
+		{name: ActionEnvironment, do: r.ensureEnvironment, compensate: r.removeEnvironment},
+		{name: ActionVariables, do: r.ensureVariables, compensate: r.removeVariables},
+		{name: ActionPipeline, do: r.createPipeline, compensate: r.removePipeline},
+		{name: ActionTrigger, do: r.triggerPipeline, compensate: r.cancelPipeline},
+		{name: ActionJobs, do: r.createJobs},
+	}
+}
+
+// ProcessCreatingStand выполняет действия подготовки стенда, пропуская уже выполненные
+func (r *Runner) ProcessCreatingStand(stand models.Stand) error {
+	records, err := database.GetProvisionActions(stand.ID, r.db)
+	if err != nil {
+		return err
+	}
+
+	done := make(map[string]models.ProvisionAction)
+	started := make(map[string]models.ProvisionAction)
+	for _, record := range records {
+		switch record.Status {
+		case ActionStatusDone:
+			done[record.Name] = record
+		case ActionStatusInProgress:
+			started[record.Name] = record
+		}
+	}
+
+	for order, action := range r.provisionActions() {
+		if _, exist := done[action.name]; exist {
+			logger.InfofWithCaller("Действие %s для стенда %s уже выполнено, пропускаем", action.name, stand.Name)
+			continue
+		}
+
+		// Действие записывается до обращения к GitLab, чтобы повторная попытка нашла созданный им ресурс
+		var previous *models.ProvisionAction
+		if record, exist := started[action.name]; exist {
+			previous = &record
+			logger.InfofWithCaller("Действие %s для стенда %s было начато прошлой попыткой, продолжаем", action.name, stand.Name)
+		} else if err := database.SaveProvisionAction(models.ProvisionAction{
+			StandID: stand.ID,
+			Name:    action.name,
+			Order:   order + 1,
+			Status:  ActionStatusInProgress,
+		}, r.db); err != nil {
+			return err
+		}
+
+		result, err := action.do(stand, done, previous)
+		if err != nil {
+			return fmt.Errorf("ошибка при выполнении действия %s: %w", action.name, err)
+		}
+
+		record := models.ProvisionAction{
+			StandID: stand.ID,
+			Name:    action.name,
+			Order:   order + 1,
+			Status:  ActionStatusDone,
+			Created: result.created,
+			Ref:     result.ref,
+		}
+		if err := database.SaveProvisionAction(record, r.db); err != nil {
+			return err
+		}
+		done[action.name] = record
+		logger.InfofWithCaller("Действие %s для стенда %s выполнено", action.name, stand.Name)
+	}
+
+	return nil
+}
+
+// handleProvisionFailure оставляет стенд для повторной попытки или, если попытки исчерпаны,
+// удаляет созданные ресурсы и переводит стенд в статус failed
+func (r *Runner) handleProvisionFailure(stand models.Stand, provisionErr error) {
+	attempts := stand.ProvisionAttempts + 1
+	invalidCI := errors.Is(provisionErr, errInvalidCI)
+	if !invalidCI && attempts < config.Config.ProvisionMaxAttempts {
+		if err := r.db.Model(&stand).Update("provision_attempts", attempts).Error; err != nil {
+			logger.ErrorfWithCaller("Ошибка при обновлении количества попыток стенда %s: %v", stand.Name, err)
+		}
+		logger.WarnfWithCaller("Попытка %d/%d подготовки стенда %s не удалась, повторим позже",
+			attempts, config.Config.ProvisionMaxAttempts, stand.Name)
+		return
+	}
+
+	if invalidCI {
+		logger.ErrorfWithCaller("Подготовка стенда %s отменена из-за ошибок CI, удаляем созданные ресурсы", stand.Name)
+	} else {
+		logger.ErrorfWithCaller("Попытки подготовки стенда %s исчерпаны, удаляем созданные ресурсы", stand.Name)
+	}
+	if err := r.compensateProvisioning(stand); err != nil {
+		logger.ErrorfWithCaller("Ошибка при удалении ресурсов стенда %s: %v", stand.Name, err)
+	}
+
+	// Ресурсы стенда удалены, поэтому он больше не занимает место в пуле
+	if err := r.db.Model(&stand).Updates(map[string]interface{}{
+		"provision_attempts": attempts,
+		"status":             StatusFailed,
+		"pool":               "",
+	}).Error; err != nil {
+		logger.ErrorfWithCaller("Ошибка при обновлении статуса стенда %s: %v", stand.Name, err)
+	}
+
+	message := fmt.Sprintf("Подготовка стенда не удалась после %d попыток: %v", attempts, provisionErr)
+	if invalidCI {
+		message = fmt.Sprintf("Подготовка стенда отменена: %v", provisionErr)
+	}
+	if err := database.CreateStandNotify(stand, provisionStepName, StatusError, message, r.db); err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании уведомления для стенда %s: %v", stand.Name, err)
+	}
+}
+
+// compensateProvisioning выполняет компенсирующие действия в обратном порядке
+func (r *Runner) compensateProvisioning(stand models.Stand) error {
+	records, err := database.GetProvisionActions(stand.ID, r.db)
+	if err != nil {
+		return err
+	}
+
+	actions := make(map[string]provisionAction)
+	for _, action := range r.provisionActions() {
+		actions[action.name] = action
+	}
+
+	var errs []error
+	for i := len(records) - 1; i >= 0; i-- {
+		record := records[i]
+		// Начатое действие компенсируется, если оно успело отметить создание ресурса
+		if record.Status != ActionStatusDone && !(record.Status == ActionStatusInProgress && record.Created) {
+			continue
+		}
+
+		if action, exist := actions[record.Name]; exist && action.compensate != nil {
+			if err := action.compensate(stand, record); err != nil {
+				logger.ErrorfWithCaller("Ошибка при компенсации действия %s для стенда %s: %v", record.Name, stand.Name, err)
+				errs = append(errs, err)
+				continue
+			}
+		}
+
+		if err := database.UpdateProvisionActionStatus(ActionStatusCompensated, &record, r.db); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		logger.InfofWithCaller("Действие %s для стенда %s компенсировано", record.Name, stand.Name)
+	}
+
+	return errors.Join(errs...)
+}
+
+// lintCI проверяет конфигурацию CI до создания ресурсов стенда. Пайплайн запускается на ветке стенда,
+// поэтому проверяется она, если уже существует, иначе — ветка, из которой стенд будет создан
+func (r *Runner) lintCI(stand models.Stand, _ map[string]models.ProvisionAction, _ *models.ProvisionAction) (provisionResult, error) {
+	if !config.Config.CILintEnabled {
+		return provisionResult{}, nil
+	}
+
+	ref := stand.Ref
+	existBranch, err := r.gitlab.CheckBranchExist(stand.Name)
+	if err != nil {
+		return provisionResult{}, err
+	}
+	if existBranch && !stand.RecreateBranch {
+		ref = stand.Name
+	}
+
+	result, err := r.gitlab.LintCI(ref)
+	if err != nil {
+		return provisionResult{}, err
+	}
+	if !result.Valid {
+		return provisionResult{}, fmt.Errorf("%w в %s: %s", errInvalidCI, ref, strings.Join(result.Errors, "; "))
+	}
+	for _, warning := range result.Warnings {
+		logger.WarnfWithCaller("Предупреждение CI для стенда %s в %s: %s", stand.Name, ref, warning)
+	}
+
+	if config.Config.CILintCheckStages {
+		expected, err := internal.StandStages(stand)
+		if err != nil {
+			return provisionResult{}, err
+		}
+		if len(expected) == 0 {
+			for _, template := range internal.StepTemplates() {
+				expected = append(expected, template.Stage)
+			}
+		}
+
+		var missing []string
+		for _, stage := range expected {
+			if !internal.ContainsString(result.Stages(), stage) {
+				missing = append(missing, stage)
+			}
+		}
+		if len(missing) > 0 {
+			return provisionResult{}, fmt.Errorf("%w в %s: нет джоб в stage %s", errInvalidCI, ref, strings.Join(missing, ", "))
+		}
+	}
+
+	logger.InfofWithCaller("Конфигурация CI в %s для стенда %s корректна", ref, stand.Name)
+	return provisionResult{ref: ref}, nil
+}
+
+func (r *Runner) ensureBranch(stand models.Stand, _ map[string]models.ProvisionAction, started *models.ProvisionAction) (provisionResult, error) {
+	existBranch, err := r.gitlab.CheckBranchExist(stand.Name)
+	if err != nil {
+		return provisionResult{}, err
+	}
+	if existBranch && !stand.RecreateBranch {
+		logger.InfofWithCaller("Branch %s already exist", stand.Name)
+		return provisionResult{created: createdBefore(started)}, nil
+	}
+
+	if err = database.MarkProvisionActionCreated(stand.ID, ActionBranch, r.db); err != nil {
+		return provisionResult{}, err
+	}
+	// При пересоздании старая ветка удаляется здесь, а не при постановке в очередь: флаг снимается
+	// только после клонирования, поэтому повторная попытка продолжит с того же места
+	if existBranch {
+		if err = r.gitlab.DeleteBranch(stand.Name); err != nil {
+			return provisionResult{}, err
+		}
+		logger.InfofWithCaller("Branch %s deleted before recreation", stand.Name)
+	}
+	if err = r.gitlab.CloneBranch(stand.Name, stand.Ref); err != nil {
+		return provisionResult{}, err
+	}
+	if stand.RecreateBranch {
+		if err = r.db.Model(&stand).Update("recreate_branch", false).Error; err != nil {
+			return provisionResult{}, err
+		}
+	}
+	logger.InfofWithCaller("Branch successfully %s created from %s", stand.Name, stand.Ref)
+	return provisionResult{created: true}, nil
+}
+
+func (r *Runner) removeBranch(stand models.Stand, action models.ProvisionAction) error {
+	if !action.Created {
+		return nil
+	}
+	return r.gitlab.DeleteBranch(stand.Name)
+}
+
+func (r *Runner) ensureEnvironment(stand models.Stand, _ map[string]models.ProvisionAction, started *models.ProvisionAction) (provisionResult, error) {
+	existEnv, err := r.gitlab.CheckEnvironmentExist(stand.Name)
+	if err != nil {
+		return provisionResult{}, err
+	}
+	if existEnv {
+		logger.InfofWithCaller("Environment %s already exist", stand.Name)
+		return provisionResult{created: createdBefore(started)}, nil
+	}
+
+	if err = database.MarkProvisionActionCreated(stand.ID, ActionEnvironment, r.db); err != nil {
+		return provisionResult{}, err
+	}
+	if err = r.gitlab.CreateEnvironmentIntoRepository(stand.Name); err != nil {
+		return provisionResult{}, err
+	}
+	logger.InfofWithCaller("Environment successfully %s created", stand.Name)
+	return provisionResult{created: true}, nil
+}
+
+func (r *Runner) removeEnvironment(stand models.Stand, action models.ProvisionAction) error {
+	if !action.Created {
+		return nil
+	}
+	return r.gitlab.DeleteEnvironment(stand.Name)
+}
+
+func (r *Runner) ensureVariables(stand models.Stand, _ map[string]models.ProvisionAction, started *models.ProvisionAction) (provisionResult, error) {
+	products, err := database.GetProductsFromStand(stand.Name, r.db)
+	if err != nil {
+		logger.ErrorWithCaller("Failed to get products from stand:", err)
+		return provisionResult{}, err
+	}
+
+	existVariables, err := r.gitlab.CheckVariablesIntoEnvironment(stand.Name)
+	if err != nil {
+		logger.ErrorWithCaller("Failed to check variables into environment:", err)
+		return provisionResult{}, err
+	}
+
+	if existVariables {
+		if err = r.gitlab.UpdateVariablesIntoEnvironment(stand.Name, products); err != nil {
+			logger.ErrorWithCaller("Failed to update variables into environment:", err)
+			return provisionResult{}, err
+		}
+		logger.InfofWithCaller("Environment variables successfully updated for %s", stand.Name)
+		return provisionResult{created: createdBefore(started)}, nil
+	}
+
+	if err = database.MarkProvisionActionCreated(stand.ID, ActionVariables, r.db); err != nil {
+		return provisionResult{}, err
+	}
+	if err = r.gitlab.CreateVariablesIntoEnvironment(stand.Name, products); err != nil {
+		logger.ErrorWithCaller("Failed to create variables into environment:", err)
+		return provisionResult{}, err
+	}
+	logger.InfofWithCaller("Environment variables successfully created for %s", stand.Name)
+	return provisionResult{created: true}, nil
+}
+
+func (r *Runner) removeVariables(stand models.Stand, action models.ProvisionAction) error {
+	if !action.Created {
+		return nil
+	}
+	return r.gitlab.DeleteVariablesFromEnvironment(stand.Name)
+}
+
+func (r *Runner) createPipeline(stand models.Stand, _ map[string]models.ProvisionAction, started *models.ProvisionAction) (provisionResult, error) {
+	// Пайплайн, созданный прошлой попыткой, становится текущим пайплайном стенда, но еще не запущен в GitLab
+	if started != nil && stand.CurrentPipelineID != 0 {
+		current, err := database.GetPipelineByID(stand.CurrentPipelineID, r.db)
+		if err != nil {
+			return provisionResult{}, err
+		}
+		if current.GitlabPipelineID == 0 && current.Kind == models.PipelineKindProvision {
+			logger.InfofWithCaller("Pipeline %d already created for %s", current.ID, stand.Name)
+			return provisionResult{created: true, ref: strconv.FormatUint(uint64(current.ID), 10)}, nil
+		}
+	}
+
+	tx := r.db.Begin()
+	pipeline, err := database.CreateStandPipeline(stand.Name, tx)
+	if err != nil {
+		tx.Rollback()
+		return provisionResult{}, err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return provisionResult{}, err
+	}
+	logger.InfofWithCaller("Pipeline %d successfully created for %s", pipeline.ID, stand.Name)
+	return provisionResult{created: true, ref: strconv.FormatUint(uint64(pipeline.ID), 10)}, nil
+}
+
+func (r *Runner) removePipeline(_ models.Stand, action models.ProvisionAction) error {
+	pipelineID, err := strconv.ParseUint(action.Ref, 10, 64)
+	if err != nil {
+		return fmt.Errorf("некорректный ID пайплайна %q: %v", action.Ref, err)
+	}
+
+	tx := r.db.Begin()
+	if err := database.DeletePipeline(uint(pipelineID), tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	return tx.Commit().Error
+}
+
+func (r *Runner) triggerPipeline(stand models.Stand, done map[string]models.ProvisionAction, started *models.ProvisionAction) (provisionResult, error) {
+	pipelineID, err := pipelineIDFromActions(done)
+	if err != nil {
+		return provisionResult{}, err
+	}
+
+	pipeline, err := database.GetPipelineByID(pipelineID, r.db)
+	if err != nil {
+		return provisionResult{}, err
+	}
+	if pipeline.GitlabPipelineID != 0 {
+		logger.InfofWithCaller("Pipeline %d already triggered in GitLab (%d)", pipeline.ID, pipeline.GitlabPipelineID)
+		return provisionResult{created: true, ref: strconv.Itoa(pipeline.GitlabPipelineID)}, nil
+	}
+
+	variables := make(map[string]string)
+	if len(pipeline.Variables) > 0 {
+		if err := json.Unmarshal(pipeline.Variables, &variables); err != nil {
+			return provisionResult{}, fmt.Errorf("ошибка при разборе переменных пайплайна %d: %v", pipeline.ID, err)
+		}
+	}
+
+	// Прошлая попытка могла запустить пайплайн в GitLab и не успеть записать его. Такой пайплайн —
+	// последний на ветке стенда и еще не записан в БД, он принимается вместо запуска нового
+	gitlabPipelineID := 0
+	if started != nil {
+		latest, err := r.gitlab.GetLatestPipeline(stand.Name)
+		if err != nil {
+			return provisionResult{}, err
+		}
+		if latest.ID != 0 {
+			tracked, err := database.IsGitlabPipelineTracked(latest.ID, r.db)
+			if err != nil {
+				return provisionResult{}, err
+			}
+			if !tracked {
+				gitlabPipelineID = latest.ID
+				logger.InfofWithCaller("Pipeline %d was already triggered in GitLab for %s", gitlabPipelineID, stand.Name)
+			}
+		}
+	}
+	if gitlabPipelineID == 0 {
+		gitlabPipelineID, err = r.gitlab.RunPipeline(stand.Name, variables)
+		if err != nil {
+			logger.ErrorWithCaller("Failed to run pipeline:", err)
+			return provisionResult{}, err
+		}
+		logger.InfofWithCaller("Pipeline successfully triggered for %s", stand.Name)
+	}
+
+	tx := r.db.Begin()
+	if err = database.UpdateStandPipeline(pipeline.ID, gitlabPipelineID, tx); err == nil {
+		err = tx.Commit().Error
+	} else {
+		tx.Rollback()
+	}
+	if err != nil {
+		// Действие не будет записано, поэтому отменяем запущенный пайплайн сразу
+		if cancelErr := r.gitlab.CancelPipeline(gitlabPipelineID); cancelErr != nil {
+			logger.ErrorfWithCaller("Ошибка при отмене пайплайна %d: %v", gitlabPipelineID, cancelErr)
+		}
+		return provisionResult{}, err
+	}
+	logger.InfofWithCaller("Pipeline successfully updated for %s", stand.Name)
+
+	return provisionResult{created: true, ref: strconv.Itoa(gitlabPipelineID)}, nil
+}
+
+func (r *Runner) cancelPipeline(_ models.Stand, action models.ProvisionAction) error {
+	gitlabPipelineID, err := strconv.Atoi(action.Ref)
+	if err != nil {
+		return fmt.Errorf("некорректный ID пайплайна GitLab %q: %v", action.Ref, err)
+	}
+	return r.gitlab.CancelPipeline(gitlabPipelineID)
+}
+
+func (r *Runner) createJobs(stand models.Stand, done map[string]models.ProvisionAction, _ *models.ProvisionAction) (provisionResult, error) {
+	pipelineID, err := pipelineIDFromActions(done)
+	if err != nil {
+		return provisionResult{}, err
+	}
+	gitlabPipelineID, err := strconv.Atoi(done[ActionTrigger].Ref)
+	if err != nil {
+		return provisionResult{}, fmt.Errorf("некорректный ID пайплайна GitLab %q: %v", done[ActionTrigger].Ref, err)
+	}
+
+	jobs, err := r.gitlab.GetJobsFromPipeline(gitlabPipelineID)
+	if err != nil {
+		logger.ErrorWithCaller("Error getting jobs from pipeline:", err)
+		return provisionResult{}, err
+	}
+
+	steps, err := database.GetStepsByPipelineID(pipelineID, r.db)
+	if err != nil {
+		logger.ErrorfWithCaller("Error getting steps: %v", err)
+		return provisionResult{}, err
+	}
+
+	jobsProcess, err := internal.ProcessJobs(internal.JobsToMap(jobs), steps)
+	if err != nil {
+		logger.ErrorfWithCaller("Error processing jobs: %v", err)
+		return provisionResult{}, err
+	}
+	logger.InfofWithCaller("Jobs successfully was process for %s", stand.Name)
+
+	tx := r.db.Begin()
+	// Джобы могли быть частично созданы предыдущей попыткой
+	if err := tx.Where("step_id IN (?)", tx.Model(&models.Step{}).Select("id").Where("pipeline_id = ?", pipelineID)).
+		Delete(&models.Job{}).Error; err != nil {
+		tx.Rollback()
+		return provisionResult{}, err
+	}
+	if len(jobsProcess) > 0 {
+		if err := database.CreateJob(jobsProcess, tx); err != nil {
+			tx.Rollback()
+			return provisionResult{}, err
+		}
+	}
+	if err := tx.Commit().Error; err != nil {
+		return provisionResult{}, err
+	}
+	logger.InfofWithCaller("Jobs successfully created for %s", stand.Name)
+
+	return provisionResult{}, nil
+}
+
+// createdBefore сообщает, успела ли прошлая попытка действия отметить создание ресурса. Тогда найденный
+// ресурс создан стендом и должен быть удален при компенсации
+func createdBefore(started *models.ProvisionAction) bool {
+	return started != nil && started.Created
+}
+
+// pipelineIDFromActions возвращает ID пайплайна, созданного действием pipeline
+func pipelineIDFromActions(done map[string]models.ProvisionAction) (uint, error) {
+	action, exist := done[ActionPipeline]
+	if !exist {
+		return 0, errors.New("пайплайн стенда еще не создан")
+	}
+	pipelineID, err := strconv.ParseUint(action.Ref, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("некорректный ID пайплайна %q: %v", action.Ref, err)
+	}
+	return uint(pipelineID), nil
+}
@@ -0,0 +1,329 @@
+package scheduler
+
+import (
+	"fmt"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/gitlab"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+const StatusSkipped = "skipped"
+
+// reconciliation накапливает исправления, внесенные при сверке одного стенда
+type reconciliation struct {
+	stand       string
+	corrections int
+}
+
+func (rc *reconciliation) correct(format string, args ...interface{}) {
+	rc.corrections++
+	logger.InfofWithCaller("Сверка стенда %s: %s", rc.stand, fmt.Sprintf(format, args...))
+}
+
+// openPipelineStatuses статусы пайплайнов, которые еще не завершились
+var openPipelineStatuses = []string{StatusPending, StatusRunning, StatusAwaitingApproval}
+
+// pipelineSnapshot состояние незавершенного пайплайна и его джоб в GitLab, собранное до записи в БД
+type pipelineSnapshot struct {
+	pipeline models.Pipeline
+	gitlab   *gitlab.PipelineInfo // nil, если пайплайн еще не запущен в GitLab
+	steps    []stepSnapshot
+}
+
+type stepSnapshot struct {
+	step models.Step
+	jobs []jobSnapshot
+}
+
+type jobSnapshot struct {
+	job    models.Job
+	gitlab gitlab.JobInfo
+}
+
+// ReconcileStands сверяет незавершенные стенды с реальным состоянием пайплайнов и джоб в GitLab
+func (r *Runner) ReconcileStands() error {
+	logger.InfoWithCaller("Начало сверки стендов с GitLab")
+	var stands []models.Stand
+
+	if err := r.db.Where("status IN ?", []string{StatusPending, StatusRunning, StatusPaused}).Find(&stands).Error; err != nil {
+		return fmt.Errorf("ошибка при поиске незавершенных стендов: %v", err)
+	}
+
+	for _, stand := range stands {
+		// Стенд, который сейчас обрабатывается планировщиком, не трогаем
+		if _, active := r.activeStands.LoadOrStore(stand.Name, true); active {
+			logger.InfofWithCaller("Стенд %s активно обрабатывается, пропускаем сверку", stand.Name)
+			continue
+		}
+
+		if err := r.reconcileStand(stand); err != nil {
+			logger.ErrorfWithCaller("Ошибка при сверке стенда %s: %v", stand.Name, err)
+		}
+		r.activeStands.Delete(stand.Name)
+	}
+
+	return nil
+}
+
+// reconcileStand сначала опрашивает GitLab, а затем одной короткой транзакцией исправляет
+// джобы, шаги и пайплайны стенда. Статус стенда следует за текущим пайплайном: первым
+// незавершенным, а если таких нет — последним
+func (r *Runner) reconcileStand(stand models.Stand) error {
+	var latest models.Pipeline
+	if err := r.db.Where("stand_id = ?", stand.ID).Order("id desc").Limit(1).Find(&latest).Error; err != nil {
+		return err
+	}
+	if latest.ID == 0 {
+		logger.InfofWithCaller("У стенда %s нет пайплайнов, сверять нечего", stand.Name)
+		return nil
+	}
+
+	var pipelines []models.Pipeline
+	if err := r.db.Where("stand_id = ? AND status IN ?", stand.ID, openPipelineStatuses).
+		Order("id asc").Find(&pipelines).Error; err != nil {
+		return err
+	}
+
+	snapshots := make([]pipelineSnapshot, 0, len(pipelines))
+	for _, pipeline := range pipelines {
+		snapshot, err := r.fetchPipeline(pipeline)
+		if err != nil {
+			return err
+		}
+		snapshots = append(snapshots, snapshot)
+	}
+
+	rc := &reconciliation{stand: stand.Name}
+	tx := r.db.Begin()
+
+	currentStatus := ""
+	latestStatus := latest.Status
+	for _, snapshot := range snapshots {
+		pipelineStatus, err := r.reconcilePipeline(snapshot, rc, tx)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+		if pipelineStatus == StatusSuccess {
+			if err := database.UpdateStandDeployments(&stand, snapshot.pipeline, tx); err != nil {
+				tx.Rollback()
+				return err
+			}
+		}
+		if currentStatus == "" && pipelineStatus != StatusSuccess && pipelineStatus != StatusError {
+			currentStatus = pipelineStatus
+		}
+		if snapshot.pipeline.ID == latest.ID {
+			latestStatus = pipelineStatus
+		}
+	}
+	if currentStatus == "" {
+		currentStatus = latestStatus
+	}
+
+	standStatus := standStatusFor(currentStatus)
+	if stand.Status != standStatus {
+		rc.correct("статус стенда %s -> %s", stand.Status, standStatus)
+		if err := database.UpdateStandStatus(standStatus, &stand, tx); err != nil {
+			tx.Rollback()
+			return err
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("ошибка при коммите транзакции: %v", err)
+	}
+
+	logger.InfofWithCaller("Сверка стенда %s завершена, исправлений: %d", stand.Name, rc.corrections)
+	return nil
+}
+
+// standStatusFor возвращает статус стенда по статусу его текущего пайплайна. Незавершенный
+// пайплайн возвращается в очередь планировщика, пайплайн на согласовании оставляет стенд на паузе
+func standStatusFor(pipelineStatus string) string {
+	switch pipelineStatus {
+	case StatusSuccess, StatusError:
+		return pipelineStatus
+	case StatusAwaitingApproval:
+		return StatusPaused
+	default:
+		return StatusPending
+	}
+}
+
+// fetchPipeline получает из GitLab состояние пайплайна и джоб его незавершенных шагов
+func (r *Runner) fetchPipeline(pipeline models.Pipeline) (pipelineSnapshot, error) {
+	snapshot := pipelineSnapshot{pipeline: pipeline}
+	if pipeline.GitlabPipelineID == 0 {
+		return snapshot, nil
+	}
+
+	gitlabPipeline, err := r.gitlab.GetPipeline(pipeline.GitlabPipelineID)
+	if err != nil {
+		return snapshot, err
+	}
+	snapshot.gitlab = &gitlabPipeline
+
+	steps, err := database.GetStepsByPipelineID(pipeline.ID, r.db)
+	if err != nil {
+		return snapshot, err
+	}
+
+	for _, step := range steps {
+		entry := stepSnapshot{step: step}
+		if !stepFinal(step.Status) {
+			var jobs []models.Job
+			if err := r.db.Where("step_id = ?", step.ID).Order("\"order\" asc").Find(&jobs).Error; err != nil {
+				return snapshot, err
+			}
+			for _, job := range jobs {
+				// Пропущенная по решению человека джоба остается manual в GitLab
+				if job.Decision == models.JobDecisionSkip {
+					entry.jobs = append(entry.jobs, jobSnapshot{job: job, gitlab: gitlab.JobInfo{Status: StatusSkipped}})
+					continue
+				}
+				gitlabJob, err := r.gitlab.GetJob(job.GitlabJobID)
+				if err != nil {
+					return snapshot, err
+				}
+				entry.jobs = append(entry.jobs, jobSnapshot{job: job, gitlab: gitlabJob})
+			}
+		}
+		snapshot.steps = append(snapshot.steps, entry)
+	}
+
+	return snapshot, nil
+}
+
+// stepFinal сообщает, что шаг сверять не нужно: он завершен или ждет решения по manual-джобе,
+// которая в GitLab остается manual до решения
+func stepFinal(status string) bool {
+	return status == StatusSuccess || status == StatusError || status == StatusSkipped || status == StatusAwaitingApproval
+}
+
+// reconcilePipeline исправляет статусы джоб, шагов и пайплайна и возвращает итоговый статус пайплайна
+func (r *Runner) reconcilePipeline(snapshot pipelineSnapshot, rc *reconciliation, tx *gorm.DB) (string, error) {
+	pipeline := snapshot.pipeline
+	if snapshot.gitlab == nil {
+		return pipeline.Status, nil
+	}
+	gitlabPipeline := *snapshot.gitlab
+
+	var stepStatuses []string
+	awaitingApproval := false
+	for _, step := range snapshot.steps {
+		stepStatus, err := r.reconcileStep(step, rc, tx)
+		if err != nil {
+			return "", err
+		}
+		awaitingApproval = awaitingApproval || stepStatus == StatusAwaitingApproval
+		stepStatuses = append(stepStatuses, stepStatus)
+	}
+
+	pipelineStatus := internal.AggregateStatus(stepStatuses)
+	if gitlabPipeline.Status == StatusCanceled && pipelineStatus != StatusSuccess {
+		pipelineStatus = StatusError
+	} else if awaitingApproval && pipelineStatus != StatusError {
+		pipelineStatus = StatusAwaitingApproval
+	}
+
+	updates := map[string]interface{}{}
+	if pipeline.Status != pipelineStatus {
+		rc.correct("статус пайплайна %d %s -> %s", pipeline.ID, pipeline.Status, pipelineStatus)
+		updates["status"] = pipelineStatus
+	}
+	if timeChanged(pipeline.StartedAt, gitlabPipeline.StartedAt) {
+		rc.correct("время запуска пайплайна %d -> %s", pipeline.ID, gitlabPipeline.StartedAt)
+		updates["started_at"] = gitlabPipeline.StartedAt
+	}
+	if timeChanged(pipeline.FinishedAt, gitlabPipeline.FinishedAt) {
+		rc.correct("время завершения пайплайна %d -> %s", pipeline.ID, gitlabPipeline.FinishedAt)
+		updates["finished_at"] = gitlabPipeline.FinishedAt
+	}
+	if len(updates) > 0 {
+		if err := tx.Model(&pipeline).Updates(updates).Error; err != nil {
+			return "", fmt.Errorf("ошибка при обновлении пайплайна %d: %v", pipeline.ID, err)
+		}
+	}
+
+	return pipelineStatus, nil
+}
+
+// reconcileStep исправляет статусы джоб шага и возвращает итоговый статус шага
+func (r *Runner) reconcileStep(snapshot stepSnapshot, rc *reconciliation, tx *gorm.DB) (string, error) {
+	step := snapshot.step
+	if stepFinal(step.Status) {
+		return step.Status, nil
+	}
+
+	var jobStatuses []string
+	for _, job := range snapshot.jobs {
+		if job.job.Decision != models.JobDecisionSkip {
+			if err := r.reconcileJob(job.job, job.gitlab, rc, tx); err != nil {
+				return "", err
+			}
+		}
+		jobStatuses = append(jobStatuses, job.gitlab.Status)
+	}
+
+	stepStatus := internal.StepStatusFromJobs(jobStatuses)
+
+	if step.Status != stepStatus {
+		rc.correct("статус шага %q %s -> %s", step.Name, step.Status, stepStatus)
+		if err := database.UpdateStepStatus(stepStatus, &step, tx); err != nil {
+			return "", err
+		}
+		// Шаг завершился, пока бэкенд был недоступен, пользователь об этом еще не знает
+		if stepStatus == StatusSuccess || stepStatus == StatusError {
+			if err := database.CreateStepNotify(step, stepStatus, tx); err != nil {
+				return "", err
+			}
+		}
+	}
+
+	return stepStatus, nil
+}
+
+func (r *Runner) reconcileJob(job models.Job, gitlabJob gitlab.JobInfo, rc *reconciliation, tx *gorm.DB) error {
+	updates := map[string]interface{}{}
+	if job.Status != gitlabJob.Status {
+		rc.correct("статус джобы %s (%d) %s -> %s", job.Name, job.GitlabJobID, job.Status, gitlabJob.Status)
+		updates["status"] = gitlabJob.Status
+	}
+
+	if gitlabJob.Status == StatusManual {
+		// Джоба не запускалась в GitLab, планировщик должен запустить ее заново
+		if job.StartedAt != nil {
+			rc.correct("сброс времени запуска джобы %s (%d)", job.Name, job.GitlabJobID)
+			updates["started_at"] = nil
+		}
+	} else if timeChanged(job.StartedAt, gitlabJob.StartedAt) {
+		rc.correct("время запуска джобы %s (%d) -> %s", job.Name, job.GitlabJobID, gitlabJob.StartedAt)
+		updates["started_at"] = gitlabJob.StartedAt
+	}
+	if timeChanged(job.FinishedAt, gitlabJob.FinishedAt) {
+		rc.correct("время завершения джобы %s (%d) -> %s", job.Name, job.GitlabJobID, gitlabJob.FinishedAt)
+		updates["finished_at"] = gitlabJob.FinishedAt
+	}
+
+	if len(updates) == 0 {
+		return nil
+	}
+	if err := tx.Model(&job).Updates(updates).Error; err != nil {
+		return fmt.Errorf("ошибка при обновлении джобы %d: %v", job.ID, err)
+	}
+	return nil
+}
+
+// timeChanged сообщает, отличается ли известное в GitLab время от сохраненного в БД
+func timeChanged(stored *time.Time, actual *time.Time) bool {
+	if actual == nil {
+		return false
+	}
+	return stored == nil || !stored.Equal(*actual)
+}
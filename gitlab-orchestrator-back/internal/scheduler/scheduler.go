@@ -1,8 +1,12 @@
 package scheduler
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
 	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/capacity"
+	"gitlab-orchestrator-back/internal/config"
 	"gitlab-orchestrator-back/internal/database"
 	"gitlab-orchestrator-back/internal/gitlab"
 	"gitlab-orchestrator-back/internal/logger"
@@ -21,13 +25,24 @@ const (
 	StatusFailed   = "failed"
 	StatusSuccess  = "success"
 	StatusCanceled = "canceled"
+
+	StatusAwaitingApproval = "awaiting_approval"
+	StatusPaused           = "paused" // Стенд ждет решения по manual-джобе
 )
 
+// errAwaitingApproval означает, что шаг остановлен до решения человека по manual-джобе
+var errAwaitingApproval = errors.New("step is awaiting approval")
+
 type Runner struct {
 	gitlab                gitlab.Gitlab
 	db                    *gorm.DB
 	workingPending        chan struct{}
 	workingCreating       chan struct{}
+	workingReconcile      chan struct{}
+	workingOrphans        chan struct{}
+	workingSchedules      chan struct{}
+	workingFleet          chan struct{}
+	workingWarmPool       chan struct{}
 	jobStatuses           sync.Map
 	activeStands          sync.Map // Для отслеживания активных стендов
 	maxConcurrentPending  int
@@ -41,6 +56,11 @@ func NewRunner(git gitlab.Gitlab, db *gorm.DB) *Runner {
 		db:                    db,
 		workingPending:        make(chan struct{}, 1),
 		workingCreating:       make(chan struct{}, 1),
+		workingReconcile:      make(chan struct{}, 1),
+		workingOrphans:        make(chan struct{}, 1),
+		workingSchedules:      make(chan struct{}, 1),
+		workingFleet:          make(chan struct{}, 1),
+		workingWarmPool:       make(chan struct{}, 1),
 		maxConcurrentPending:  1,
 		maxConcurrentCreating: 1,
 	}
@@ -52,13 +72,18 @@ func StartRunnerScheduler(git gitlab.Gitlab) {
 
 	runner := NewRunner(git, database.DB)
 
-	logger.InfoWithCaller("Looking for stale stands...")
-	if err := runner.recoverStaleStands(); err != nil {
-		logger.ErrorfWithCaller("Error recovering stale stands: %v", err)
+	logger.InfoWithCaller("Reconciling stands with GitLab...")
+	if err := runner.ReconcileStands(); err != nil {
+		logger.ErrorfWithCaller("Error reconciling stands: %v", err)
 	}
 
 	pendingTicker := time.NewTicker(10 * time.Second)
 	createdTicker := time.NewTicker(15 * time.Second)
+	reconcileTicker := time.NewTicker(config.Config.ReconcileInterval)
+	orphansTicker := time.NewTicker(config.Config.OrphanScanInterval)
+	schedulesTicker := time.NewTicker(time.Minute)
+	fleetTicker := time.NewTicker(config.Config.FleetCheckInterval)
+	warmPoolTicker := time.NewTicker(time.Minute)
 
 	go func() {
 		for range pendingTicker.C {
@@ -91,92 +116,91 @@ func StartRunnerScheduler(git gitlab.Gitlab) {
 			}
 		}
 	}()
-}
-
-func (r *Runner) recoverStaleStands() error {
-	logger.InfoWithCaller("Начало восстановления зависших стендов")
-	var staleStands []models.Stand
-
-	if err := r.db.Where("status = ?", StatusRunning).Find(&staleStands).Error; err != nil {
-		return fmt.Errorf("ошибка при поиске зависших стендов: %v", err)
-	}
-
-	for _, stand := range staleStands {
-		// Проверяем, не находится ли стенд в активной обработке
-		if _, active := r.activeStands.Load(stand.Name); active {
-			logger.InfofWithCaller("Стенд %s активно обрабатывается, пропускаем", stand.Name)
-			continue
-		}
-
-		logger.InfofWithCaller("Найден зависший стенд %s (ID: %d), начинаем восстановление", stand.Name, stand.ID)
 
-		tx := r.db.Begin()
-
-		// Обновление статуса стенда
-		if err := tx.Exec("UPDATE stands SET status = ? WHERE id = ?",
-			StatusPending, stand.ID).Error; err != nil {
-			tx.Rollback()
-			logger.ErrorfWithCaller("Ошибка при обновлении статуса стенда %s: %v", stand.Name, err)
-			continue
+	// Сверка стендов с GitLab
+	go func() {
+		for range reconcileTicker.C {
+			select {
+			case runner.workingReconcile <- struct{}{}:
+				if err := runner.ReconcileStands(); err != nil {
+					logger.ErrorfWithCaller("Ошибка при сверке стендов: %v", err)
+				}
+				<-runner.workingReconcile
+			default:
+				logger.InfoWithCaller("Предыдущая сверка стендов ещё выполняется, пропускаем")
+			}
 		}
+	}()
 
-		// Обновление статуса пайплайнов
-		if err := tx.Exec(`
-            UPDATE pipelines 
-            SET status = ?, updated_at = NOW() 
-            WHERE stand_id = ? AND статус = ?`,
-			StatusPending, stand.ID, StatusRunning).Error; err != nil {
-			tx.Rollback()
-			logger.ErrorfWithCaller("Ошибка при обновлении статусов пайплайнов для стенда %s: %v", stand.Name, err)
-			continue
+	// Поиск осиротевших ресурсов GitLab
+	go func() {
+		for range orphansTicker.C {
+			select {
+			case runner.workingOrphans <- struct{}{}:
+				if err := runner.ScanOrphans(); err != nil {
+					logger.ErrorfWithCaller("Ошибка при поиске осиротевших ресурсов: %v", err)
+				}
+				<-runner.workingOrphans
+			default:
+				logger.InfoWithCaller("Предыдущий поиск осиротевших ресурсов ещё выполняется, пропускаем")
+			}
 		}
+	}()
 
-		if err := tx.Exec(`
-			UPDATE jobs
-			SET status = ?, updated_at = NOW()
-			WHERE step_id IN (
-				SELECT steps.id
-				FROM steps
-				JOIN pipelines ON steps.pipeline_id = pipelines.id
-				WHERE pipelines.stand_id = ? AND steps.status = ?
-			)
-			AND status != ?`, StatusManual, stand.ID, StatusRunning, StatusSuccess).Error; err != nil {
-			tx.Rollback()
-			logger.ErrorfWithCaller("Ошибка при обновлении статусов джоб для стенда %s: %v", stand.Name, err)
-			continue
+	// Запуск стендов по расписаниям
+	go func() {
+		for range schedulesTicker.C {
+			select {
+			case runner.workingSchedules <- struct{}{}:
+				if err := runner.CheckSchedules(); err != nil {
+					logger.ErrorfWithCaller("Ошибка при проверке расписаний: %v", err)
+				}
+				if err := runner.CheckExpiredStands(); err != nil {
+					logger.ErrorfWithCaller("Ошибка при проверке сроков жизни стендов: %v", err)
+				}
+				<-runner.workingSchedules
+			default:
+				logger.InfoWithCaller("Предыдущая проверка расписаний ещё выполняется, пропускаем")
+			}
 		}
+	}()
 
-		// Обновление статуса шагов
-		if err := tx.Exec(`
-            UPDATE steps 
-            SET status = ?, updated_at = NOW() 
-            WHERE id IN (
-                SELECT steps.id 
-                FROM steps 
-                JOIN pipelines ON steps.pipeline_id = pipelines.id 
-                WHERE pipelines.stand_id = ? AND steps.status = ?
-            )`, StatusPending, stand.ID, StatusRunning).Error; err != nil {
-			tx.Rollback()
-			logger.ErrorfWithCaller("Ошибка при обновлении статусов шагов для стенда %s: %v", stand.Name, err)
-			continue
+	// Массовые обновления стендов
+	go func() {
+		for range fleetTicker.C {
+			select {
+			case runner.workingFleet <- struct{}{}:
+				if err := runner.CheckFleetOperations(); err != nil {
+					logger.ErrorfWithCaller("Ошибка при проверке массовых операций: %v", err)
+				}
+				<-runner.workingFleet
+			default:
+				logger.InfoWithCaller("Предыдущая проверка массовых операций ещё выполняется, пропускаем")
+			}
 		}
+	}()
 
-		if err := tx.Commit().Error; err != nil {
-			tx.Rollback()
-			logger.ErrorfWithCaller("Ошибка при коммите транзакции для стенда %s: %v", stand.Name, err)
-			continue
+	// Пополнение пула готовых стендов
+	go func() {
+		for range warmPoolTicker.C {
+			select {
+			case runner.workingWarmPool <- struct{}{}:
+				if err := runner.CheckWarmPool(); err != nil {
+					logger.ErrorfWithCaller("Ошибка при пополнении пула готовых стендов: %v", err)
+				}
+				<-runner.workingWarmPool
+			default:
+				logger.InfoWithCaller("Предыдущее пополнение пула готовых стендов ещё выполняется, пропускаем")
+			}
 		}
-
-		logger.InfofWithCaller("Стенд %s успешно восстановлен", stand.Name)
-	}
-
-	return nil
+	}()
 }
 
 func (r *Runner) CheckCreatedStands() error {
 	var stands []models.Stand
 
-	if err := r.db.Where("status = ?", "created").Find(&stands).Error; err != nil {
+	// Стенды размещаются в пулах ресурсов в порядке очереди
+	if err := r.db.Where("status = ?", "created").Order("created_at asc").Find(&stands).Error; err != nil {
 		return fmt.Errorf("ошибка при получении created стендов: %v", err)
 	}
 
@@ -185,6 +209,11 @@ func (r *Runner) CheckCreatedStands() error {
 		return nil
 	}
 
+	usage, err := capacity.Usage(r.db)
+	if err != nil {
+		return err
+	}
+
 	wg := sync.WaitGroup{}
 	semaphore := make(chan struct{}, r.maxConcurrentCreating)
 
@@ -193,6 +222,9 @@ func (r *Runner) CheckCreatedStands() error {
 			logger.InfofWithCaller("Стенд %s уже обрабатывается, пропускаем", stand.Name)
 			continue
 		}
+		if !r.placeStand(&stand, usage) {
+			continue
+		}
 
 		wg.Add(1)
 		semaphore <- struct{}{}
@@ -205,15 +237,25 @@ func (r *Runner) CheckCreatedStands() error {
 			}()
 
 			r.activeStands.Store(stand.Name, true)
-			tx := r.db.Begin()
 
 			if err := r.ProcessCreatingStand(stand); err != nil {
 				logger.ErrorfWithCaller("Ошибка при обработке стенда %s: %v", stand.Name, err)
+				r.handleProvisionFailure(stand, err)
+				return
+			}
+
+			tx := r.db.Begin()
+			// Подготовка завершена, записи о действиях больше не нужны для повторных попыток
+			if err := database.ClearProvisionActions(stand.ID, tx); err != nil {
+				logger.ErrorfWithCaller("Ошибка при очистке действий подготовки стенда %s: %v", stand.Name, err)
 				tx.Rollback()
 				return
 			}
 
-			if err := tx.Model(&stand).Update("status", StatusPending).Error; err != nil {
+			if err := tx.Model(&stand).Updates(map[string]interface{}{
+				"status":             StatusPending,
+				"provision_attempts": 0,
+			}).Error; err != nil {
 				logger.ErrorfWithCaller("Ошибка при обновлении статуса стенда %s: %v", stand.Name, err)
 				tx.Rollback()
 				return
@@ -228,6 +270,34 @@ func (r *Runner) CheckCreatedStands() error {
 	return nil
 }
 
+// placeStand размещает стенд в пуле ресурсов перед подготовкой. Если подходящего пула нет,
+// стенд остается в очереди с причиной ожидания. Без настроенных пулов емкость не ограничивается
+func (r *Runner) placeStand(stand *models.Stand, usage []capacity.PoolUsage) bool {
+	if len(usage) == 0 || stand.Pool != "" {
+		return true
+	}
+
+	index, reason := capacity.Place(*stand, usage)
+	if index < 0 {
+		if stand.QueueReason != reason {
+			logger.InfofWithCaller("Стенд %s ожидает ресурсов: %s", stand.Name, reason)
+			if err := database.UpdateStandQueueReason(stand, reason, r.db); err != nil {
+				logger.ErrorfWithCaller("%v", err)
+			}
+		}
+		return false
+	}
+
+	pool := usage[index].Pool.Name
+	if err := database.AssignStandPool(stand, pool, r.db); err != nil {
+		logger.ErrorfWithCaller("%v", err)
+		return false
+	}
+	usage[index].Add(*stand)
+	logger.InfofWithCaller("Стенд %s размещен в пуле ресурсов %s", stand.Name, pool)
+	return true
+}
+
 func (r *Runner) CheckPendingStands() error {
 	var stands []models.Stand
 
@@ -271,114 +341,6 @@ func (r *Runner) CheckPendingStands() error {
 	return nil
 }
 
-func (r *Runner) ProcessCreatingStand(stand models.Stand) error {
-
-	tx := r.db.Begin()
-
-	existBranch, err := r.gitlab.CheckBranchExist(stand.Name)
-	if err != nil {
-		return err
-	}
-
-	if !existBranch {
-		err = r.gitlab.CloneBranch(stand.Name, stand.Ref)
-		if err != nil {
-			return err
-		}
-		logger.InfofWithCaller("Branch successfully %s created from %s", stand.Name, stand.Ref)
-	}
-	logger.InfofWithCaller("Branch exist: %v", existBranch)
-
-	existEnv, err := r.gitlab.CheckEnvironmentExist(stand.Name)
-	if err != nil {
-		return err
-	}
-	if !existEnv {
-		err = r.gitlab.CreateEnvironmentIntoRepository(stand.Name)
-		if err != nil {
-			return err
-		}
-		logger.InfofWithCaller("Environment successfully %s created", stand.Name)
-	}
-	logger.InfofWithCaller("Environment exist: %v", existEnv)
-	products, err := database.GetProductsFromStand(stand.Name, tx)
-	if err != nil {
-		logger.ErrorWithCaller("Failed to get products from stand:", err)
-		return err
-	}
-
-	existVariables, err := r.gitlab.CheckVariablesIntoEnvironment(stand.Name)
-	if err != nil {
-		logger.ErrorWithCaller("Failed to check variables into environment:", err)
-		return err
-	}
-	if !existVariables {
-		err = r.gitlab.CreateVariablesIntoEnvironment(stand.Name, products)
-		if err != nil {
-			logger.ErrorWithCaller("Failed to create variables into environment:", err)
-			return err
-		}
-		logger.InfofWithCaller("Environment variables successfully created for %s", stand.Name)
-	} else if existVariables {
-		err = r.gitlab.UpdateVariablesIntoEnvironment(stand.Name, products)
-		if err != nil {
-			logger.ErrorWithCaller("Failed to update variables into environment:", err)
-			return err
-		}
-		logger.InfofWithCaller("Environment variables successfully updated for %s", stand.Name)
-	}
-
-	err = database.CreateStandPipeline(stand.Name, tx)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-
-	pipelineID, err := r.gitlab.RunPipeline(stand.Name)
-	if err != nil {
-		logger.ErrorWithCaller("Failed to run pipeline:", err)
-		tx.Rollback()
-		return err
-	}
-	logger.InfofWithCaller("Pipeline successfully created for %s", stand.Name)
-
-	err = database.UpdateStandPipeline(stand.Name, pipelineID, tx)
-	if err != nil {
-		tx.Rollback()
-		return err
-	}
-	logger.InfofWithCaller("Pipeline successfully updated for %s", stand.Name)
-
-	jobs, err := r.gitlab.GetJobsFromPipeline(pipelineID)
-	if err != nil {
-		logger.ErrorWithCaller("Error getting jobs from pipeline:", err)
-		tx.Rollback()
-		return err
-	}
-	logger.InfofWithCaller("Jobs successfully created for %s", stand.Name)
-
-	JobMap := internal.JobsToMap(jobs)
-
-	steps, err := database.GetStepByStandName(stand.Name, tx)
-	if err != nil {
-		logger.FatalfWithCaller("Error getting step: %v", err)
-		tx.Rollback()
-		return err
-	}
-
-	jobsProcess, err := internal.ProcessJobs(JobMap, steps)
-	if err != nil {
-		logger.FatalfWithCaller("Error processing and saving jobs: %v", err)
-	}
-	logger.InfofWithCaller("Jobs successfully was process for %s", stand.Name)
-	err = database.CreateJob(jobsProcess, tx)
-	logger.InfofWithCaller("Jobs successfully created for %s", stand.Name)
-
-	tx.Commit()
-
-	return nil
-}
-
 func (r *Runner) processPendingStand(stand models.Stand) error {
 	var pipelines []models.Pipeline
 
@@ -395,13 +357,22 @@ func (r *Runner) processPendingStand(stand models.Stand) error {
 		return fmt.Errorf("ошибка при обновлении статуса стенда в БД: %v", err)
 	}
 	for _, pipeline := range pipelines {
-		if err := r.processPendingPipeline(pipeline); err != nil {
+		if err := r.processPendingPipeline(stand, pipeline); err != nil {
+			if errors.Is(err, errAwaitingApproval) {
+				logger.InfofWithCaller("Стенд %s ожидает решения по manual-джобе", stand.Name)
+				return database.UpdateStandStatus(StatusPaused, &stand, r.db)
+			}
 			if err := database.UpdateStandStatus(StatusError, &stand, r.db); err != nil {
 				return fmt.Errorf("ошибка при обновлении статуса стенда в БД: %v", err)
 			}
+			r.notifyUpgrade(stand, pipeline, StatusError)
 			logger.ErrorfWithCaller("Ошибка при обработке пайплайна %d: %v", pipeline.ID, err)
 			return fmt.Errorf("ошибка при обработке пайплайна %d: %v", pipeline.ID, err)
 		}
+		if err := database.UpdateStandDeployments(&stand, pipeline, r.db); err != nil {
+			logger.ErrorfWithCaller("Ошибка при обновлении версий продуктов стенда %s: %v", stand.Name, err)
+		}
+		r.notifyUpgrade(stand, pipeline, StatusSuccess)
 	}
 	if err := database.UpdateStandStatus(StatusSuccess, &stand, r.db); err != nil {
 		return fmt.Errorf("ошибка при обновлении статуса стенда в БД: %v", err)
@@ -409,7 +380,7 @@ func (r *Runner) processPendingStand(stand models.Stand) error {
 	return nil
 }
 
-func (r *Runner) processPendingPipeline(pipeline models.Pipeline) error {
+func (r *Runner) processPendingPipeline(stand models.Stand, pipeline models.Pipeline) error {
 	var steps []models.Step
 
 	if err := r.db.Where("pipeline_id = ? AND status = ?", pipeline.ID, StatusPending).
@@ -425,20 +396,34 @@ func (r *Runner) processPendingPipeline(pipeline models.Pipeline) error {
 	if err := database.UpdatePipelineStatus(StatusRunning, &pipeline, r.db); err != nil {
 		return fmt.Errorf("ошибка при обновлении статуса шага в БД: %v", err)
 	}
+	// Об обновлении продукта сообщается одним уведомлением по стенду, а не по шагам.
+	// О подготовке стендов пула никому не сообщается, пока стенд не выдан пользователю
+	notifySteps := pipeline.Kind != models.PipelineKindUpgrade && !stand.Warm
 	for _, step := range steps {
 		if err := r.processStep(step); err != nil {
+			if errors.Is(err, errAwaitingApproval) {
+				if err := database.UpdatePipelineStatus(StatusAwaitingApproval, &pipeline, r.db); err != nil {
+					return err
+				}
+				return errAwaitingApproval
+			}
 			if err = database.UpdatePipelineStatus(StatusError, &pipeline, r.db); err != nil {
 				return fmt.Errorf("ошибка при обновлении статуса шага в БД: %v", err)
 			}
-			if err := database.CreateStepNotify(step, StatusError, r.db); err != nil {
-				logger.ErrorfWithCaller("Ошибка при создании уведомления для шага %d: %v", step.ID, err)
-				return fmt.Errorf("ошибка при создании уведомления для шага %d: %v", step.ID, err)
+			if notifySteps {
+				if err := database.CreateStepNotify(step, StatusError, r.db); err != nil {
+					logger.ErrorfWithCaller("Ошибка при создании уведомления для шага %d: %v", step.ID, err)
+					return fmt.Errorf("ошибка при создании уведомления для шага %d: %v", step.ID, err)
+				}
 			}
 			logger.ErrorfWithCaller("Ошибка при обработке шага %d: %v", step.ID, err)
 			return fmt.Errorf("ошибка при обработке шага %d: %v", step.ID, err)
 		}
 
 		logger.InfofWithCaller("Шаг %d для пайплайна %d успешно обработан", step.ID, pipeline.ID)
+		if !notifySteps {
+			continue
+		}
 		if err := database.CreateStepNotify(step, StatusSuccess, r.db); err != nil {
 			logger.ErrorfWithCaller("Ошибка при создании уведомления для шага %d: %v", step.ID, err)
 			return fmt.Errorf("ошибка при создании уведомления для шага %d: %v", step.ID, err)
@@ -456,7 +441,8 @@ func (r *Runner) processPendingPipeline(pipeline models.Pipeline) error {
 func (r *Runner) processStep(step models.Step) error {
 	var jobs []models.Job
 
-	if err := r.db.Where("step_id = ? AND status != ?", step.ID, StatusSuccess).Find(&jobs).Error; err != nil {
+	if err := r.db.Where("step_id = ? AND status NOT IN ?", step.ID, []string{StatusSuccess, StatusSkipped}).
+		Order("\"order\" asc").Find(&jobs).Error; err != nil {
 		return err
 	}
 
@@ -473,22 +459,36 @@ func (r *Runner) processStep(step models.Step) error {
 	}
 
 	hasManualJobs := false
-	hasRunningJobs := false
 	if err := database.UpdateStepStatus(StatusRunning, &step, r.db); err != nil {
 		return fmt.Errorf("ошибка при обновлении статуса шага в БД: %v", err)
 	}
+
+	// Джобы, выполнявшиеся до перезапуска бэкенда, дожидаемся, а не запускаем повторно
 	for _, job := range jobs {
 		switch job.Status {
 		case StatusManual:
 			hasManualJobs = true
-		case StatusRunning:
-			hasRunningJobs = true
+		case StatusRunning, StatusPending:
+			if err := r.monitorJobStatus(job); err != nil {
+				if err = database.UpdateStepStatus(StatusError, &step, r.db); err != nil {
+					return fmt.Errorf("ошибка при обновлении статуса шага в БД: %v", err)
+				}
+				logger.ErrorfWithCaller("Ошибка при ожидании джобы %d: %v", job.ID, err)
+				return fmt.Errorf("ошибка при ожидании джобы %d: %v", job.ID, err)
+			}
 		}
 	}
 
-	if hasManualJobs && !hasRunningJobs {
+	if err := r.capturePlan(step); err != nil {
+		logger.ErrorfWithCaller("Ошибка при сохранении плана шага %d: %v", step.ID, err)
+	}
+
+	if hasManualJobs {
 		for _, job := range jobs {
 			if job.Status == StatusManual {
+				if job.RequiresApproval && job.Decision != models.JobDecisionApprove {
+					return r.requestJobApproval(step, job)
+				}
 				if err := r.processJob(job); err != nil {
 					if err = database.UpdateStepStatus(StatusError, &step, r.db); err != nil {
 						return fmt.Errorf("ошибка при обновлении статуса шага в БД: %v", err)
@@ -496,6 +496,9 @@ func (r *Runner) processStep(step models.Step) error {
 					logger.ErrorfWithCaller("Ошибка при обработке джобы %d: %v", job.ID, err)
 					return fmt.Errorf("ошибка при обработке джобы %d: %v", job.ID, err)
 				}
+				if err := r.capturePlan(step); err != nil {
+					logger.ErrorfWithCaller("Ошибка при сохранении плана шага %d: %v", step.ID, err)
+				}
 			}
 		}
 	}
@@ -506,6 +509,51 @@ func (r *Runner) processStep(step models.Step) error {
 	return nil
 }
 
+// notifyUpgrade сообщает владельцу стенда о результате обновления продукта
+func (r *Runner) notifyUpgrade(stand models.Stand, pipeline models.Pipeline, status string) {
+	if pipeline.Kind != models.PipelineKindUpgrade {
+		return
+	}
+	var variables map[string]string
+	if err := json.Unmarshal(pipeline.Variables, &variables); err != nil {
+		logger.ErrorfWithCaller("Ошибка при разборе переменных пайплайна %d: %v", pipeline.ID, err)
+	}
+	product := variables[internal.VarUpgradeProduct]
+	image := internal.DeploymentImage(product, variables[internal.VarUpgradeTag])
+
+	message := "Продукт обновлен до " + image
+	if status == StatusError {
+		message = "Не удалось обновить продукт до " + image
+	}
+	if err := database.CreateUpgradeNotify(stand, product, "Обновление продукта", status, message, r.db); err != nil {
+		logger.ErrorfWithCaller("Ошибка при создании уведомления для стенда %s: %v", stand.Name, err)
+	}
+}
+
+// requestJobApproval останавливает шаг до решения по джобе и уведомляет владельца стенда и администраторов
+func (r *Runner) requestJobApproval(step models.Step, job models.Job) error {
+	logger.InfofWithCaller("Джоба %d (%s) требует согласования, шаг %d приостановлен", job.ID, job.Name, step.ID)
+
+	stand, err := database.GetStandByPipelineID(step.PipelineID, r.db)
+	if err != nil {
+		return err
+	}
+
+	tx := r.db.Begin()
+	if err := database.UpdateStepStatus(StatusAwaitingApproval, &step, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := database.CreateJobApprovalNotify(*stand, step, job, tx); err != nil {
+		tx.Rollback()
+		return fmt.Errorf("ошибка при создании уведомления о согласовании джобы %d: %v", job.ID, err)
+	}
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("ошибка при коммите транзакции: %v", err)
+	}
+	return errAwaitingApproval
+}
+
 func (r *Runner) processJob(job models.Job) error {
 	logger.InfofWithCaller("Запуск джобы %d (GitLab JobID: %d)", job.ID, job.GitlabJobID)
 
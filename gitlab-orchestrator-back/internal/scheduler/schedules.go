@@ -0,0 +1,149 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/admission"
+	"gitlab-orchestrator-back/internal/cron"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"net/http"
+	"time"
+)
+
+// CheckSchedules запускает расписания, время которых наступило, и планирует их следующий запуск
+func (r *Runner) CheckSchedules() error {
+	now := time.Now()
+	schedules, err := database.GetDueSchedules(now, r.db)
+	if err != nil {
+		return err
+	}
+
+	for _, schedule := range schedules {
+		result := "ok"
+		if err := r.runSchedule(schedule); err != nil {
+			logger.ErrorfWithCaller("Ошибка при запуске расписания %d для стенда %s: %v", schedule.ID, schedule.StandName, err)
+			result = err.Error()
+		}
+
+		updates := map[string]interface{}{
+			"last_run_at": now,
+			"last_result": result,
+			"next_run_at": nil,
+		}
+		parsed, err := cron.Parse(schedule.Cron, schedule.TimeZone)
+		if err != nil {
+			logger.ErrorfWithCaller("Некорректное cron-выражение расписания %d: %v", schedule.ID, err)
+			updates["paused"] = true
+		} else if next := parsed.Next(now); !next.IsZero() {
+			updates["next_run_at"] = next
+		}
+		if err := r.db.Model(&schedule).Updates(updates).Error; err != nil {
+			logger.ErrorfWithCaller("Ошибка при обновлении расписания %d: %v", schedule.ID, err)
+		}
+	}
+	return nil
+}
+
+// runSchedule создает стенд расписания или ставит существующий стенд на пересоздание
+func (r *Runner) runSchedule(schedule models.Schedule) error {
+	var products models.ProductList
+	if err := json.Unmarshal(schedule.Products, &products); err != nil {
+		return fmt.Errorf("некорректный список продуктов: %v", err)
+	}
+
+	stand, err := database.GetStandByName(schedule.StandName, r.db)
+	if errors.Is(err, database.ErrStandNotFound) {
+		return r.createScheduledStand(schedule, products)
+	}
+	if err != nil {
+		return err
+	}
+
+	if _, active := r.activeStands.Load(stand.Name); active || !internal.CanRebuild(stand.Status) {
+		return fmt.Errorf("стенд %s в статусе %s, пересоздание пропущено", stand.Name, stand.Status)
+	}
+	if booking, err := database.GetActiveBooking(stand.ID, time.Now(), r.db); err == nil && booking.Blocks(schedule.UserID) {
+		return fmt.Errorf("стенд %s заблокирован до %s, пересоздание пропущено", stand.Name, booking.EndsAt.Format("02.01.2006 15:04"))
+	}
+
+	// Подготовка удалит ветку стенда и заново склонирует ее из ref расписания
+	tx := r.db.Begin()
+	if err := database.RebuildStand(stand, products, schedule.Ref, nil, true, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := database.CreateStandNotify(*stand, "Расписание", StatusSuccess,
+		fmt.Sprintf("Стенд поставлен на пересоздание из %s по расписанию %s", schedule.Ref, schedule.Cron), tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("ошибка при коммите транзакции: %v", err)
+	}
+
+	logger.InfofWithCaller("Стенд %s поставлен на пересоздание по расписанию %d", stand.Name, schedule.ID)
+	return nil
+}
+
+// createScheduledStand создает стенд расписания с теми же проверками региона, квоты, срока жизни
+// и согласования, что и стенд пользователя. Если стенд не проходит проверки, запуск пропускается,
+// а владелец расписания получает уведомление с причиной
+func (r *Runner) createScheduledStand(schedule models.Schedule, products models.ProductList) error {
+	request := internal.StandRequest{
+		NameStand: schedule.StandName,
+		Products:  products,
+		UserID:    int64(schedule.UserID),
+		Ref:       schedule.Ref,
+	}
+	needApproval, status, message := admission.Check(&request)
+	if status != http.StatusOK {
+		if err := database.CreateNotify(models.StepState{
+			StandName: schedule.StandName,
+			StepName:  "Расписание",
+			UserID:    schedule.UserID,
+			Status:    StatusError,
+			Kind:      models.NotifyKindStand,
+			Message:   fmt.Sprintf("Стенд не создан по расписанию %s: %s", schedule.Cron, message),
+		}, r.db); err != nil {
+			logger.ErrorfWithCaller("Ошибка при уведомлении о пропуске расписания %d: %v", schedule.ID, err)
+		}
+		return fmt.Errorf("стенд %s не создан: %s", schedule.StandName, message)
+	}
+
+	tx := r.db.Begin()
+	if _, err := admission.Create(request, needApproval, tx); err != nil {
+		tx.Rollback()
+		return err
+	}
+	if err := tx.Commit().Error; err != nil {
+		return fmt.Errorf("ошибка при коммите транзакции: %v", err)
+	}
+
+	if needApproval {
+		logger.InfofWithCaller("Стенд %s по расписанию %d отправлен на согласование", schedule.StandName, schedule.ID)
+		return nil
+	}
+	logger.InfofWithCaller("Стенд %s создан по расписанию %d", schedule.StandName, schedule.ID)
+	return nil
+}
+
+// CheckExpiredStands уведомляет владельцев стендов, срок жизни которых истек
+func (r *Runner) CheckExpiredStands() error {
+	stands, err := database.GetExpiredStands(time.Now(), r.db)
+	if err != nil {
+		return err
+	}
+
+	for _, stand := range stands {
+		if err := database.NotifyStandExpired(&stand, r.db); err != nil {
+			logger.ErrorfWithCaller("Ошибка при уведомлении об окончании срока жизни стенда %s: %v", stand.Name, err)
+			continue
+		}
+		logger.InfofWithCaller("Срок жизни стенда %s истек", stand.Name)
+	}
+	return nil
+}
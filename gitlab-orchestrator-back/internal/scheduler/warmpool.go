@@ -0,0 +1,57 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/config"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"time"
+)
+
+// CheckWarmPool досоздает стенды пула каждого типа до размера из WARM_POOL. Стенды пула готовятся
+// без stage helm, продукты устанавливаются при выдаче стенда пользователю
+func (r *Runner) CheckWarmPool() error {
+	stages, err := json.Marshal(warmStages())
+	if err != nil {
+		return err
+	}
+
+	for standType, size := range config.Config.WarmPool {
+		count, err := database.CountWarmStands(standType, r.db)
+		if err != nil {
+			return err
+		}
+
+		for i := int(count); i < size; i++ {
+			stand := models.Stand{
+				Name:     fmt.Sprintf("%s-%d", config.Config.WarmPoolPrefix, time.Now().UnixMilli()+int64(i)),
+				UserID:   config.Config.WarmPoolOwnerID,
+				Products: []byte("[]"),
+				Status:   "created",
+				Ref:      config.Config.WarmPoolRef,
+				Type:     standType,
+				Stages:   stages,
+				Warm:     true,
+			}
+			if err := database.CreateStand(stand, r.db); err != nil {
+				return fmt.Errorf("ошибка при создании готового стенда типа %s: %v", standType, err)
+			}
+			logger.InfofWithCaller("Стенд %s типа %s поставлен на подготовку в пул готовых стендов", stand.Name, standType)
+		}
+	}
+	return nil
+}
+
+// warmStages возвращает stage подготовки стенда пула: все, кроме stage установки продуктов
+func warmStages() []string {
+	var stages []string
+	for _, template := range internal.StepTemplates() {
+		if !internal.ContainsString(internal.UpgradeStages, template.Stage) {
+			stages = append(stages, template.Stage)
+		}
+	}
+	return stages
+}
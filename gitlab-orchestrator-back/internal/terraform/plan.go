@@ -0,0 +1,147 @@
+package terraform
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ResourceChanges количество изменений ресурсов одного типа
+type ResourceChanges struct {
+	Add     int `json:"add"`
+	Change  int `json:"change"`
+	Destroy int `json:"destroy"`
+}
+
+// PlanSummary сводка terraform plan по типам ресурсов
+type PlanSummary struct {
+	Resources map[string]*ResourceChanges `json:"resources"`
+	Total     ResourceChanges             `json:"total"`
+}
+
+func newPlanSummary() *PlanSummary {
+	return &PlanSummary{Resources: make(map[string]*ResourceChanges)}
+}
+
+// record учитывает действия terraform над ресурсом resourceType
+func (s *PlanSummary) record(resourceType string, actions []string) {
+	var add, change, destroy int
+	for _, action := range actions {
+		switch action {
+		case "create":
+			add++
+		case "update":
+			change++
+		case "delete":
+			destroy++
+		}
+	}
+	if add+change+destroy == 0 {
+		return // no-op и read не меняют инфраструктуру
+	}
+
+	changes, ok := s.Resources[resourceType]
+	if !ok {
+		changes = &ResourceChanges{}
+		s.Resources[resourceType] = changes
+	}
+	changes.Add += add
+	changes.Change += change
+	changes.Destroy += destroy
+	s.Total.Add += add
+	s.Total.Change += change
+	s.Total.Destroy += destroy
+}
+
+// ParsePlanJSON разбирает вывод terraform show -json
+func ParsePlanJSON(data []byte) (*PlanSummary, error) {
+	var plan struct {
+		ResourceChanges []struct {
+			Type   string `json:"type"`
+			Mode   string `json:"mode"`
+			Change struct {
+				Actions []string `json:"actions"`
+			} `json:"change"`
+		} `json:"resource_changes"`
+	}
+	if err := json.Unmarshal(data, &plan); err != nil {
+		return nil, fmt.Errorf("ошибка при разборе JSON плана: %v", err)
+	}
+
+	summary := newPlanSummary()
+	for _, resource := range plan.ResourceChanges {
+		if resource.Mode == "data" {
+			continue
+		}
+		summary.record(resource.Type, resource.Change.Actions)
+	}
+	return summary, nil
+}
+
+var (
+	ansiPattern     = regexp.MustCompile(`\x1b\[[0-9;]*m`)
+	resourcePattern = regexp.MustCompile(`^\s*# (\S+) (?:\(.+\) )?(will be created|will be updated in-place|will be destroyed|must be replaced|will be replaced)`)
+	indexPattern    = regexp.MustCompile(`\[[^\]]*\]`)
+)
+
+var traceActions = map[string][]string{
+	"will be created":          {"create"},
+	"will be updated in-place": {"update"},
+	"will be destroyed":        {"delete"},
+	"must be replaced":         {"delete", "create"},
+	"will be replaced":         {"delete", "create"},
+}
+
+// ParsePlanTrace разбирает текстовый вывод terraform plan из лога джобы
+func ParsePlanTrace(trace string) (*PlanSummary, error) {
+	summary := newPlanSummary()
+	found := false
+	for _, line := range strings.Split(ansiPattern.ReplaceAllString(trace, ""), "\n") {
+		if strings.Contains(line, "No changes.") {
+			found = true
+		}
+		match := resourcePattern.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		found = true
+		summary.record(resourceType(match[1]), traceActions[match[2]])
+	}
+	if !found {
+		return nil, fmt.Errorf("в логе джобы не найден вывод terraform plan")
+	}
+	return summary, nil
+}
+
+// resourceType выделяет тип ресурса из адреса вида module.vm.yandex_compute_instance.node[0]
+func resourceType(address string) string {
+	parts := strings.Split(indexPattern.ReplaceAllString(address, ""), ".")
+	if len(parts) < 2 {
+		return address
+	}
+	return parts[len(parts)-2]
+}
+
+// Format возвращает сводку в виде текста для уведомления
+func (s *PlanSummary) Format() string {
+	if len(s.Resources) == 0 {
+		return "Изменений нет"
+	}
+
+	types := make([]string, 0, len(s.Resources))
+	for resourceType := range s.Resources {
+		types = append(types, resourceType)
+	}
+	sort.Strings(types)
+
+	var builder strings.Builder
+	for _, resourceType := range types {
+		changes := s.Resources[resourceType]
+		builder.WriteString(fmt.Sprintf("%s: +%d ~%d -%d\n", resourceType, changes.Add, changes.Change, changes.Destroy))
+	}
+	builder.WriteString(fmt.Sprintf("Итого: добавить %d, изменить %d, удалить %d",
+		s.Total.Add, s.Total.Change, s.Total.Destroy))
+	return builder.String()
+}
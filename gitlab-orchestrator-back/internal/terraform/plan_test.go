@@ -0,0 +1,171 @@
+package terraform
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestParsePlanJSON(t *testing.T) {
+	tests := []struct {
+		name    string
+		data    string
+		want    map[string]ResourceChanges
+		total   ResourceChanges
+		wantErr bool
+	}{
+		{
+			name: "создание, изменение и замена",
+			data: `{"resource_changes": [
+				{"type": "yandex_compute_instance", "mode": "managed", "change": {"actions": ["create"]}},
+				{"type": "yandex_compute_instance", "mode": "managed", "change": {"actions": ["create"]}},
+				{"type": "yandex_dns_recordset", "mode": "managed", "change": {"actions": ["update"]}},
+				{"type": "yandex_vpc_subnet", "mode": "managed", "change": {"actions": ["delete", "create"]}}
+			]}`,
+			want: map[string]ResourceChanges{
+				"yandex_compute_instance": {Add: 2},
+				"yandex_dns_recordset":    {Change: 1},
+				"yandex_vpc_subnet":       {Add: 1, Destroy: 1},
+			},
+			total: ResourceChanges{Add: 3, Change: 1, Destroy: 1},
+		},
+		{
+			name: "data-источники и no-op не учитываются",
+			data: `{"resource_changes": [
+				{"type": "yandex_compute_image", "mode": "data", "change": {"actions": ["read"]}},
+				{"type": "yandex_compute_instance", "mode": "managed", "change": {"actions": ["no-op"]}}
+			]}`,
+			want: map[string]ResourceChanges{},
+		},
+		{
+			name:    "некорректный JSON",
+			data:    `{"resource_changes": [`,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := ParsePlanJSON([]byte(tt.data))
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ParsePlanJSON не вернул ошибку")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePlanJSON: %v", err)
+			}
+			assertSummary(t, summary, tt.want, tt.total)
+		})
+	}
+}
+
+func TestParsePlanTrace(t *testing.T) {
+	tests := []struct {
+		name    string
+		trace   string
+		want    map[string]ResourceChanges
+		total   ResourceChanges
+		wantErr bool
+	}{
+		{
+			name: "ресурсы модулей с индексами",
+			trace: "Terraform will perform the following actions:\n" +
+				"  # module.vm.yandex_compute_instance.node[0] will be created\n" +
+				"  # module.vm.yandex_compute_instance.node[\"db.main\"] will be created\n" +
+				"  # yandex_dns_recordset.stand will be updated in-place\n" +
+				"  # yandex_vpc_subnet.stand must be replaced\n" +
+				"  # yandex_lb_target_group.old will be destroyed\n" +
+				"Plan: 3 to add, 1 to change, 2 to destroy.\n",
+			want: map[string]ResourceChanges{
+				"yandex_compute_instance": {Add: 2},
+				"yandex_dns_recordset":    {Change: 1},
+				"yandex_vpc_subnet":       {Add: 1, Destroy: 1},
+				"yandex_lb_target_group":  {Destroy: 1},
+			},
+			total: ResourceChanges{Add: 3, Change: 1, Destroy: 2},
+		},
+		{
+			name:  "цветной вывод и пометка deposed",
+			trace: "\x1b[1m  # yandex_compute_instance.node\x1b[0m (deposed object 1a2b3c) will be destroyed\n",
+			want: map[string]ResourceChanges{
+				"yandex_compute_instance": {Destroy: 1},
+			},
+			total: ResourceChanges{Destroy: 1},
+		},
+		{
+			name:  "нет изменений",
+			trace: "No changes. Your infrastructure matches the configuration.\n",
+			want:  map[string]ResourceChanges{},
+		},
+		{
+			name:    "лог без вывода plan",
+			trace:   "$ terraform init\nInitializing the backend...\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			summary, err := ParsePlanTrace(tt.trace)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatal("ParsePlanTrace не вернул ошибку")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParsePlanTrace: %v", err)
+			}
+			assertSummary(t, summary, tt.want, tt.total)
+		})
+	}
+}
+
+func TestFormat(t *testing.T) {
+	tests := []struct {
+		name    string
+		summary *PlanSummary
+		want    string
+	}{
+		{
+			name:    "пустая сводка",
+			summary: newPlanSummary(),
+			want:    "Изменений нет",
+		},
+		{
+			name: "типы ресурсов по алфавиту",
+			summary: &PlanSummary{
+				Resources: map[string]*ResourceChanges{
+					"yandex_vpc_subnet":       {Add: 1, Destroy: 1},
+					"yandex_compute_instance": {Add: 2},
+				},
+				Total: ResourceChanges{Add: 3, Destroy: 1},
+			},
+			want: "yandex_compute_instance: +2 ~0 -0\nyandex_vpc_subnet: +1 ~0 -1\nИтого: добавить 3, изменить 0, удалить 1",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.summary.Format(); got != tt.want {
+				t.Errorf("Format() = %q, ожидалось %q", got, tt.want)
+			}
+		})
+	}
+}
+
+// assertSummary сравнивает сводку плана с ожидаемыми изменениями по типам ресурсов и итогом
+func assertSummary(t *testing.T, summary *PlanSummary, want map[string]ResourceChanges, total ResourceChanges) {
+	t.Helper()
+	got := make(map[string]ResourceChanges, len(summary.Resources))
+	for resourceType, changes := range summary.Resources {
+		got[resourceType] = *changes
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ресурсы = %+v, ожидалось %+v", got, want)
+	}
+	if summary.Total != total {
+		t.Errorf("итог = %+v, ожидалось %+v", summary.Total, total)
+	}
+}
@@ -0,0 +1,120 @@
+package upgrade
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gitlab-orchestrator-back/internal"
+	"gitlab-orchestrator-back/internal/database"
+	"gitlab-orchestrator-back/internal/gitlab"
+	"gitlab-orchestrator-back/internal/logger"
+	"gitlab-orchestrator-back/internal/models"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrRunPipeline возвращается, если GitLab не запустил пайплайн
+var ErrRunPipeline = errors.New("failed to run pipeline")
+
+// Start запускает в GitLab пайплайн обновления продукта code на стенде до тега tag,
+// сохраняет его с шагами и джобами и возвращает стенд в очередь планировщика
+func Start(git gitlab.Gitlab, stand *models.Stand, code string, tag string) (*models.Pipeline, error) {
+	tx := database.DB.Begin()
+	pipeline, err := run(git, stand, internal.UpgradeVariables(code, tag), models.PipelineKindUpgrade, tx)
+	if err != nil {
+		tx.Rollback()
+		return nil, err
+	}
+	if err := Commit(git, pipeline, tx); err != nil {
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// StartDeploy запускает пайплайн подготовки, который выполняет только stage helm с закрепленными
+// версиями products. Так готовый стенд из пула получает продукты без повторного создания ВМ.
+// Пайплайн сохраняется в транзакции tx вызывающего, которую нужно завершить через Commit
+func StartDeploy(git gitlab.Gitlab, stand *models.Stand, products models.ProductList, tx *gorm.DB) (*models.Pipeline, error) {
+	variables := internal.ProvisionVariables(products)
+	if variables == nil {
+		variables = make(map[string]string)
+	}
+	variables[internal.VarDeployStages] = strings.Join(internal.UpgradeStages, ",")
+	return run(git, stand, variables, models.PipelineKindProvision, tx)
+}
+
+// Commit фиксирует транзакцию с запущенным пайплайном. Если зафиксировать ее не удалось,
+// пайплайн отменяется в GitLab, чтобы он не выполнялся без записи в БД
+func Commit(git gitlab.Gitlab, pipeline *models.Pipeline, tx *gorm.DB) error {
+	if err := tx.Commit().Error; err != nil {
+		if cancelErr := git.CancelPipeline(pipeline.GitlabPipelineID); cancelErr != nil {
+			logger.ErrorfWithCaller("Ошибка при отмене пайплайна %d: %v", pipeline.GitlabPipelineID, cancelErr)
+		}
+		return fmt.Errorf("ошибка при коммите транзакции: %v", err)
+	}
+	return nil
+}
+
+func run(git gitlab.Gitlab, stand *models.Stand, variables map[string]string, kind string, tx *gorm.DB) (*models.Pipeline, error) {
+	variablesJSON, err := json.Marshal(variables)
+	if err != nil {
+		return nil, err
+	}
+
+	gitlabPipelineID, err := git.RunPipeline(stand.Name, variables)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при запуске пайплайна стенда %s: %v", stand.Name, err)
+		return nil, fmt.Errorf("%w: %v", ErrRunPipeline, err)
+	}
+	pipeline, err := track(git, stand, gitlabPipelineID, variablesJSON, kind, tx)
+	if err != nil {
+		logger.ErrorfWithCaller("Ошибка при сохранении пайплайна стенда %s: %v", stand.Name, err)
+		if cancelErr := git.CancelPipeline(gitlabPipelineID); cancelErr != nil {
+			logger.ErrorfWithCaller("Ошибка при отмене пайплайна %d: %v", gitlabPipelineID, cancelErr)
+		}
+		return nil, err
+	}
+	return pipeline, nil
+}
+
+// track сохраняет в tx пайплайн вида kind с шагами и джобами и переводит стенд в pending
+func track(git gitlab.Gitlab, stand *models.Stand, gitlabPipelineID int, variables []byte, kind string, tx *gorm.DB) (*models.Pipeline, error) {
+	jobs, err := git.GetJobsFromPipeline(gitlabPipelineID)
+	if err != nil {
+		return nil, err
+	}
+
+	pipeline, err := database.CreatePipeline(models.Pipeline{
+		Name:      stand.Name,
+		StandID:   stand.ID,
+		Status:    "pending",
+		Ref:       stand.Ref,
+		Kind:      kind,
+		Variables: variables,
+	}, tx)
+	if err != nil {
+		return nil, err
+	}
+	if err := database.UpdateStandPipeline(pipeline.ID, gitlabPipelineID, tx); err != nil {
+		return nil, err
+	}
+	pipeline.GitlabPipelineID = gitlabPipelineID
+	steps, err := database.GetStepsByPipelineID(pipeline.ID, tx)
+	if err != nil {
+		return nil, err
+	}
+	jobsProcess, err := internal.ProcessJobs(internal.JobsToMap(jobs), steps)
+	if err != nil {
+		return nil, err
+	}
+	if len(jobsProcess) > 0 {
+		if err := database.CreateJob(jobsProcess, tx); err != nil {
+			return nil, err
+		}
+	}
+	if err := database.UpdateStandStatus("pending", stand, tx); err != nil {
+		return nil, err
+	}
+	return &pipeline, nil
+}
@@ -4,9 +4,14 @@ import (
 	"gitlab-orchestrator-bot/client"
 	"gitlab-orchestrator-bot/config"
 	"log"
-	"strings"
+	"time"
 )
 
+// RefreshInterval как часто бот перечитывает пользователей и их роли из бэкенда
+const RefreshInterval = time.Minute
+
+// InitUsers загружает пользователей из бэкенда и заменяет списки допущенных пользователей и администраторов.
+// К боту допускается пользователь с любой ролью. При ошибке прежние списки сохраняются
 func InitUsers() {
 	users, err := client.GetUsers()
 	if err != nil {
@@ -14,20 +19,32 @@ func InitUsers() {
 		return
 	}
 
+	allowedUsers := make(map[int64]bool)
+	allowedAdmins := make(map[int64]bool)
 	for _, user := range users {
 		userID := int64(user["chat_id"].(float64))
-		roles := strings.Split(user["role"].(string), ",")
+		roles, _ := user["roles"].([]any)
 
 		for _, role := range roles {
+			allowedUsers[userID] = true
 			if role == "admin" {
-				config.AllowedAdmins[userID] = true
-			}
-			if role == "user" {
-				config.AllowedUsers[userID] = true
+				allowedAdmins[userID] = true
 			}
 		}
 	}
-	log.Printf("Admins loaded: %+v", config.AllowedAdmins)
-	log.Printf("Users loaded: %+v", config.AllowedUsers)
+	if !config.SetAllowed(allowedUsers, allowedAdmins) {
+		return
+	}
+	log.Printf("Admins loaded: %+v", allowedAdmins)
+	log.Printf("Users loaded: %+v", allowedUsers)
+}
 
+// StartRefresh периодически обновляет пользователей, чтобы изменения ролей действовали без перезапуска бота
+func StartRefresh() {
+	ticker := time.NewTicker(RefreshInterval)
+	go func() {
+		for range ticker.C {
+			InitUsers()
+		}
+	}()
 }
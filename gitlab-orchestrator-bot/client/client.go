@@ -3,16 +3,32 @@ package client
 import (
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"gitlab-orchestrator-bot/config"
 	"io"
 	"net/http"
+	"net/url"
 	"sync"
 	"time"
 )
 
 var httpClient = &http.Client{
-	Timeout: time.Second * 20,
+	Timeout:   time.Second * 20,
+	Transport: apiKeyTransport{base: http.DefaultTransport},
+}
+
+// apiKeyTransport добавляет API-ключ бота ко всем запросам к бэкенду
+type apiKeyTransport struct {
+	base http.RoundTripper
+}
+
+func (t apiKeyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if key := config.Config.BackendAPIKey; key != "" {
+		req = req.Clone(req.Context())
+		req.Header.Set("Authorization", "Bearer "+key)
+	}
+	return t.base.RoundTrip(req)
 }
 
 type Notifications struct {
@@ -22,6 +38,12 @@ type Notifications struct {
 	StepName  string `json:"step_name"`
 	Order     int    `json:"order"`
 	Status    string `json:"status"`
+	Kind      string `json:"kind"`
+	JobID     uint   `json:"job_id"`
+	StepID    uint   `json:"step_id"`
+	Product   string `json:"product"`
+	RequestID uint   `json:"request_id"`
+	Message   string `json:"message"`
 }
 
 // Cache for Subos data
@@ -175,31 +197,38 @@ func FetchAllSubos() (map[string]string, error) {
 }
 
 // CreateStand отправляет запрос на создание стенда
-func SendStandToBackend(jsonData []byte) (string, error) {
+// SendStandToBackend создает стенд и возвращает сообщение бэкенда и имя стенда. Имя может отличаться
+// от запрошенного, если бэкенд выдал готовый стенд из пула
+func SendStandToBackend(jsonData []byte) (string, string, error) {
 	resp, err := httpClient.Post(fmt.Sprintf("%s/stands", config.Config.BackendURL),
 		"application/json", bytes.NewBuffer(jsonData))
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		bodyBytes, _ := io.ReadAll(resp.Body)
-		return "", fmt.Errorf("failed to create stand: %s, body: %s", resp.Status, string(bodyBytes))
+		// Ошибки проверок (квоты, регион) показываем пользователю как есть
+		var response map[string]string
+		if err := json.Unmarshal(bodyBytes, &response); err == nil && response["error"] != "" {
+			return "", "", errors.New(response["error"])
+		}
+		return "", "", fmt.Errorf("failed to create stand: %s, body: %s", resp.Status, string(bodyBytes))
 	}
 
-	var response map[string]interface{}
+	var response map[string]string
 
 	bodyBytes, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	if err := json.Unmarshal(bodyBytes, &response); err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	return response["message"].(string), nil
+	return response["message"], response["nameStand"], nil
 }
 
 // FetchAllStands получает список стендов
@@ -240,9 +269,9 @@ func GetStandDeployments(standName string) (map[string]string, error) {
 	return deployments, nil
 }
 
-// GetUsers получает список пользователей с их ролями
+// GetUsers получает список пользователей с их ролями для списка допущенных пользователей бота
 func GetUsers() ([]map[string]any, error) {
-	resp, err := httpClient.Get(fmt.Sprintf("%s/users", config.Config.BackendURL))
+	resp, err := httpClient.Get(fmt.Sprintf("%s/auth/users", config.Config.BackendURL))
 	if err != nil {
 		return nil, err
 	}
@@ -259,3 +288,1010 @@ func GetUsers() ([]map[string]any, error) {
 
 	return users, nil
 }
+
+// ApproveStand согласует стенд от имени администратора
+func ApproveStand(standName string, adminID int64, reason string) (string, error) {
+	return decideStand(standName, "approve", adminID, reason)
+}
+
+// RejectStand отклоняет стенд от имени администратора
+func RejectStand(standName string, adminID int64, reason string) (string, error) {
+	return decideStand(standName, "reject", adminID, reason)
+}
+
+func decideStand(standName string, decision string, adminID int64, reason string) (string, error) {
+	jsonData, err := json.Marshal(map[string]any{"adminID": adminID, "reason": reason})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/stands/%s/%s", config.Config.BackendURL, standName, decision),
+		"application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to %s stand: %s, body: %s", decision, resp.Status, response["error"])
+	}
+	return response["message"], nil
+}
+
+// DecideJob передает решение пользователя по manual-джобе: approve, skip или abort
+func DecideJob(jobID string, userID int64, decision string) (string, error) {
+	jsonData, err := json.Marshal(map[string]any{"userID": userID, "decision": decision})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/jobs/%s/decision", config.Config.BackendURL, jobID),
+		"application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to %s job: %s, body: %s", decision, resp.Status, response["error"])
+	}
+	return response["message"], nil
+}
+
+// StepPlan план terraform шага
+type StepPlan struct {
+	Step string `json:"step"`
+	Plan string `json:"plan"`
+}
+
+// GetStepPlan получает полный вывод terraform plan шага
+func GetStepPlan(stepID uint) (*StepPlan, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/steps/%d/plan", config.Config.BackendURL, stepID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch step plan, status: %s", resp.Status)
+	}
+
+	var plan StepPlan
+	if err := json.NewDecoder(resp.Body).Decode(&plan); err != nil {
+		return nil, err
+	}
+	return &plan, nil
+}
+
+// Schedule расписание создания или пересоздания стенда
+type Schedule struct {
+	ID         uint             `json:"id"`
+	StandName  string           `json:"stand_name"`
+	UserID     int64            `json:"user_id"`
+	Cron       string           `json:"cron"`
+	TimeZone   string           `json:"time_zone"`
+	Products   []config.Product `json:"products"`
+	Ref        string           `json:"ref"`
+	Paused     bool             `json:"paused"`
+	NextRunAt  *string          `json:"next_run_at"`
+	LastResult string           `json:"last_result"`
+}
+
+// FetchSchedules получает расписания пользователя, при userID = 0 — все расписания
+func FetchSchedules(userID int64) ([]Schedule, error) {
+	url := fmt.Sprintf("%s/schedules", config.Config.BackendURL)
+	if userID != 0 {
+		url = fmt.Sprintf("%s?userID=%d", url, userID)
+	}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch schedules, status: %s", resp.Status)
+	}
+
+	var schedules []Schedule
+	if err := json.NewDecoder(resp.Body).Decode(&schedules); err != nil {
+		return nil, err
+	}
+	return schedules, nil
+}
+
+// SetSchedulePaused приостанавливает или возобновляет расписание
+func SetSchedulePaused(scheduleID string, userID int64, paused bool) error {
+	jsonData, err := json.Marshal(map[string]any{"userID": userID, "paused": paused})
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest(http.MethodPut, fmt.Sprintf("%s/schedules/%s", config.Config.BackendURL, scheduleID),
+		bytes.NewBuffer(jsonData))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	return doScheduleRequest(req)
+}
+
+// DeleteSchedule удаляет расписание
+func DeleteSchedule(scheduleID string, userID int64) error {
+	req, err := http.NewRequest(http.MethodDelete,
+		fmt.Sprintf("%s/schedules/%s?userID=%d", config.Config.BackendURL, scheduleID, userID), nil)
+	if err != nil {
+		return err
+	}
+	return doScheduleRequest(req)
+}
+
+func doScheduleRequest(req *http.Request) error {
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response map[string]string
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return fmt.Errorf("schedule request failed: %s, body: %s", resp.Status, response["error"])
+	}
+	return nil
+}
+
+// RebuildStand ставит стенд в очередь на пересоздание, при пустом ref — из прежней ветки,
+// при пустом stages — со всеми stage
+func RebuildStand(standName string, userID int64, ref string, stages []string) (string, error) {
+	return requeueStand(standName, "rebuild", map[string]any{"userID": userID, "ref": ref, "stages": stages})
+}
+
+// RetryStand запускает новый пайплайн стенда на существующей ветке, при пустом stages — со всеми stage
+func RetryStand(standName string, userID int64, stages []string) (string, error) {
+	return requeueStand(standName, "retry", map[string]any{"userID": userID, "stages": stages})
+}
+
+func requeueStand(standName string, action string, body map[string]any) (string, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/stands/%s/%s", config.Config.BackendURL, standName, action),
+		"application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to %s stand: %s, body: %s", action, resp.Status, response["error"])
+	}
+	return response["message"], nil
+}
+
+// PipelineHistory пайплайн стенда из истории пересозданий
+type PipelineHistory struct {
+	ID        uint   `json:"id"`
+	Current   bool   `json:"current"`
+	Status    string `json:"status"`
+	Ref       string `json:"ref"`
+	CreatedAt string `json:"created_at"`
+	Steps     []struct {
+		Name   string `json:"name"`
+		Status string `json:"status"`
+	} `json:"steps"`
+}
+
+// FetchStandPipelines получает историю пайплайнов стенда
+func FetchStandPipelines(standName string) ([]PipelineHistory, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/stands/%s/pipelines", config.Config.BackendURL, standName))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch stand pipelines, status: %s", resp.Status)
+	}
+
+	var pipelines []PipelineHistory
+	if err := json.NewDecoder(resp.Body).Decode(&pipelines); err != nil {
+		return nil, err
+	}
+	return pipelines, nil
+}
+
+// FetchProductVersions возвращает доступные версии продукта из реестра образов
+func FetchProductVersions(code string) ([]string, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/subos/%s/versions", config.Config.BackendURL, code))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch product versions, status: %s", resp.Status)
+	}
+
+	var versions []string
+	if err := json.NewDecoder(resp.Body).Decode(&versions); err != nil {
+		return nil, err
+	}
+	return versions, nil
+}
+
+// FetchRegions возвращает регионы, в которых можно разместить стенд
+func FetchRegions() ([]string, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/regions", config.Config.BackendURL))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch regions, status: %s", resp.Status)
+	}
+
+	var regions []string
+	if err := json.NewDecoder(resp.Body).Decode(&regions); err != nil {
+		return nil, err
+	}
+	return regions, nil
+}
+
+// UpgradeProduct запускает обновление продукта code на стенде, при пустом tag — до версии по умолчанию
+func UpgradeProduct(standName string, code string, userID int64, tag string) (string, error) {
+	jsonData, err := json.Marshal(map[string]any{"userID": userID, "tag": tag})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/stands/%s/products/%s/upgrade", config.Config.BackendURL, standName, code),
+		"application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to upgrade product: %s, body: %s", resp.Status, response["error"])
+	}
+	return response["message"], nil
+}
+
+// RollbackProduct откатывает продукт code на стенде к предыдущей версии
+func RollbackProduct(standName string, code string, userID int64) (string, error) {
+	jsonData, err := json.Marshal(map[string]any{"userID": userID})
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/stands/%s/products/%s/rollback", config.Config.BackendURL, standName, code),
+		"application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to roll back product: %s, body: %s", resp.Status, response["error"])
+	}
+	return response["message"], nil
+}
+
+// FleetRequest запрос на массовое обновление продукта
+type FleetRequest struct {
+	UserID      int64             `json:"userID"`
+	Product     string            `json:"product"`
+	Tag         string            `json:"tag"`
+	OwnerID     uint              `json:"ownerID,omitempty"`
+	Type        string            `json:"type,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty"`
+	BatchSize   int               `json:"batchSize,omitempty"`
+	MaxFailures int               `json:"maxFailures,omitempty"`
+	DryRun      bool              `json:"dryRun"`
+}
+
+// FleetTarget стенд массовой операции
+type FleetTarget struct {
+	StandName string `json:"stand_name"`
+	Batch     int    `json:"batch"`
+	Status    string `json:"status"`
+	Message   string `json:"message"`
+}
+
+// FleetOperation массовая операция со сводкой по стендам
+type FleetOperation struct {
+	ID          uint          `json:"id"`
+	Product     string        `json:"product"`
+	Tag         string        `json:"tag"`
+	BatchSize   int           `json:"batch_size"`
+	MaxFailures int           `json:"max_failures"`
+	Status      string        `json:"status"`
+	Message     string        `json:"message"`
+	Targets     []FleetTarget `json:"targets"`
+	Progress    struct {
+		Total    int `json:"total"`
+		Pending  int `json:"pending"`
+		Running  int `json:"running"`
+		Success  int `json:"success"`
+		Failed   int `json:"failed"`
+		Skipped  int `json:"skipped"`
+		Canceled int `json:"canceled"`
+	} `json:"progress"`
+}
+
+// CreateFleetOperation запускает массовое обновление, при DryRun только возвращает подходящие стенды
+func CreateFleetOperation(request FleetRequest) (*FleetOperation, error) {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/fleet", config.Config.BackendURL), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	return decodeFleetOperation(resp)
+}
+
+// FetchFleetOperations получает массовые операции, начиная с последней
+func FetchFleetOperations(userID int64) ([]FleetOperation, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/fleet?userID=%d", config.Config.BackendURL, userID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch fleet operations, status: %s", resp.Status)
+	}
+
+	var operations []FleetOperation
+	if err := json.NewDecoder(resp.Body).Decode(&operations); err != nil {
+		return nil, err
+	}
+	return operations, nil
+}
+
+// ControlFleetOperation приостанавливает (pause), возобновляет (resume) или прерывает (abort) массовую операцию
+func ControlFleetOperation(operationID string, action string, userID int64) (*FleetOperation, error) {
+	jsonData, err := json.Marshal(map[string]any{"userID": userID})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/fleet/%s/%s", config.Config.BackendURL, operationID, action),
+		"application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	return decodeFleetOperation(resp)
+}
+
+func decodeFleetOperation(resp *http.Response) (*FleetOperation, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return nil, fmt.Errorf("fleet operation failed, status: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("fleet operation failed: %s, body: %s", resp.Status, response["error"])
+	}
+
+	var operation FleetOperation
+	if err := json.NewDecoder(resp.Body).Decode(&operation); err != nil {
+		return nil, err
+	}
+	return &operation, nil
+}
+
+// Quota лимиты квоты, 0 — без ограничения
+type Quota struct {
+	Stands   int `json:"stands"`
+	VMs      int `json:"vms"`
+	TTLHours int `json:"ttl_hours"`
+}
+
+// QuotaGrant изменение квоты пользователя
+type QuotaGrant struct {
+	Stands    int        `json:"stands"`
+	VMs       int        `json:"vms"`
+	TTLHours  int        `json:"ttl_hours"`
+	Reason    string     `json:"reason"`
+	ExpiresAt *time.Time `json:"expires_at"`
+}
+
+// QuotaStatus квота пользователя и ее использование
+type QuotaStatus struct {
+	UserID uint   `json:"user_id"`
+	Name   string `json:"name"`
+	Role   string `json:"role"`
+	Limits Quota  `json:"limits"`
+	Usage  struct {
+		Stands []string `json:"stands"`
+		VMs    int      `json:"vms"`
+	} `json:"usage"`
+	Grants []QuotaGrant `json:"grants"`
+}
+
+// QuotaGrantRequest запрос на изменение квоты пользователя
+type QuotaGrantRequest struct {
+	AdminID       int64  `json:"adminID"`
+	UserID        int64  `json:"userID"`
+	Stands        int    `json:"stands"`
+	VMs           int    `json:"vms"`
+	TTLHours      int    `json:"ttlHours"`
+	DurationHours int    `json:"durationHours"`
+	Reason        string `json:"reason"`
+}
+
+// FetchQuota получает квоту пользователя
+func FetchQuota(userID int64) (*QuotaStatus, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/users/%d/quota", config.Config.BackendURL, userID))
+	if err != nil {
+		return nil, err
+	}
+	return decodeQuotaStatus(resp)
+}
+
+// FetchQuotas получает квоты всех пользователей
+func FetchQuotas(userID int64) ([]QuotaStatus, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/admin/quotas?userID=%d", config.Config.BackendURL, userID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch quotas, status: %s", resp.Status)
+	}
+
+	var quotas []QuotaStatus
+	if err := json.NewDecoder(resp.Body).Decode(&quotas); err != nil {
+		return nil, err
+	}
+	return quotas, nil
+}
+
+// GrantQuota изменяет квоту пользователя
+func GrantQuota(request QuotaGrantRequest) (*QuotaStatus, error) {
+	jsonData, err := json.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/admin/quotas/grants", config.Config.BackendURL),
+		"application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	return decodeQuotaStatus(resp)
+}
+
+func decodeQuotaStatus(resp *http.Response) (*QuotaStatus, error) {
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response map[string]string
+		if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+			return nil, fmt.Errorf("quota request failed, status: %s", resp.Status)
+		}
+		return nil, fmt.Errorf("quota request failed: %s, body: %s", resp.Status, response["error"])
+	}
+
+	var status QuotaStatus
+	if err := json.NewDecoder(resp.Body).Decode(&status); err != nil {
+		return nil, err
+	}
+	return &status, nil
+}
+
+// ExtendStand продлевает срок жизни стенда на hours часов
+func ExtendStand(standName string, userID int64, hours int) (string, error) {
+	return requeueStand(standName, "extend", map[string]any{"userID": userID, "hours": hours})
+}
+
+// TeamMember участник команды
+type TeamMember struct {
+	UserID uint   `json:"user_id"`
+	Role   string `json:"role"`
+}
+
+// Team команда с участниками и использованием ее квоты
+type Team struct {
+	ID          uint         `json:"id"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Members     []TeamMember `json:"members"`
+	Quota       QuotaStatus  `json:"quota"`
+}
+
+// FetchTeams получает команды, при userID — только команды пользователя
+func FetchTeams(userID int64) ([]Team, error) {
+	url := fmt.Sprintf("%s/teams", config.Config.BackendURL)
+	if userID != 0 {
+		url += fmt.Sprintf("?userID=%d", userID)
+	}
+	resp, err := httpClient.Get(url)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch teams, status: %s", resp.Status)
+	}
+
+	var teams []Team
+	if err := json.NewDecoder(resp.Body).Decode(&teams); err != nil {
+		return nil, err
+	}
+	return teams, nil
+}
+
+// CreateTeam создает команду, пользователь становится ее владельцем
+func CreateTeam(name string, userID int64, description string) error {
+	return doTeamRequest(http.MethodPost, "", map[string]any{"userID": userID, "name": name, "description": description})
+}
+
+// UpdateTeamQuota изменяет лимиты квоты команды, nil — без изменений
+func UpdateTeamQuota(name string, userID int64, stands *int, vms *int, ttlHours *int) error {
+	return doTeamRequest(http.MethodPut, "/"+name, map[string]any{
+		"userID": userID, "maxStands": stands, "maxVMs": vms, "maxTTLHours": ttlHours,
+	})
+}
+
+// DeleteTeam удаляет команду без стендов
+func DeleteTeam(name string, userID int64) error {
+	return doTeamRequest(http.MethodDelete, fmt.Sprintf("/%s?userID=%d", name, userID), nil)
+}
+
+// SaveTeamMember добавляет пользователя в команду или меняет его роль
+func SaveTeamMember(name string, memberID int64, userID int64, role string) error {
+	return doTeamRequest(http.MethodPut, fmt.Sprintf("/%s/members/%d", name, memberID), map[string]any{"userID": userID, "role": role})
+}
+
+// RemoveTeamMember исключает пользователя из команды
+func RemoveTeamMember(name string, memberID int64, userID int64) error {
+	return doTeamRequest(http.MethodDelete, fmt.Sprintf("/%s/members/%d?userID=%d", name, memberID, userID), nil)
+}
+
+func doTeamRequest(method string, path string, body map[string]any) error {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		reader = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/teams%s", config.Config.BackendURL, path), reader)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response map[string]string
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return fmt.Errorf("team request failed: %s, body: %s", resp.Status, response["error"])
+	}
+	return nil
+}
+
+// Collaborator соавтор стенда
+type Collaborator struct {
+	UserID     uint   `json:"user_id"`
+	Name       string `json:"name"`
+	Permission string `json:"permission"`
+}
+
+// FetchCollaborators получает соавторов стенда
+func FetchCollaborators(standName string) ([]Collaborator, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/stands/%s/collaborators", config.Config.BackendURL, standName))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch collaborators, status: %s", resp.Status)
+	}
+
+	var collaborators []Collaborator
+	if err := json.NewDecoder(resp.Body).Decode(&collaborators); err != nil {
+		return nil, err
+	}
+	return collaborators, nil
+}
+
+// ShareStand выдает пользователю user (ID или @имя) право permission на стенд
+func ShareStand(standName string, userID int64, user string, permission string) (string, error) {
+	return doAccessRequest(http.MethodPost, fmt.Sprintf("/%s/collaborators", standName),
+		map[string]any{"userID": userID, "user": user, "permission": permission})
+}
+
+// UnshareStand отзывает доступ пользователя user (ID или @имя) к стенду
+func UnshareStand(standName string, userID int64, user string) (string, error) {
+	return doAccessRequest(http.MethodDelete,
+		fmt.Sprintf("/%s/collaborators/%s?userID=%d", standName, url.PathEscape(user), userID), nil)
+}
+
+// TransferStand передает стенд пользователю user (ID или @имя)
+func TransferStand(standName string, userID int64, user string) (string, error) {
+	return doAccessRequest(http.MethodPost, fmt.Sprintf("/%s/transfer", standName), map[string]any{"userID": userID, "user": user})
+}
+
+func doAccessRequest(method string, path string, body map[string]any) (string, error) {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return "", err
+		}
+		reader = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/stands%s", config.Config.BackendURL, path), reader)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("stand access request failed: %s, body: %s", resp.Status, response["error"])
+	}
+	return response["message"], nil
+}
+
+// Booking бронирование стенда
+type Booking struct {
+	ID        uint      `json:"id"`
+	UserID    uint      `json:"user_id"`
+	Name      string    `json:"name"`
+	Reason    string    `json:"reason"`
+	Exclusive bool      `json:"exclusive"`
+	Active    bool      `json:"active"`
+	StartsAt  time.Time `json:"starts_at"`
+	EndsAt    time.Time `json:"ends_at"`
+}
+
+// FetchBookings получает действующее и будущие бронирования стенда
+func FetchBookings(standName string) ([]Booking, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/stands/%s/bookings", config.Config.BackendURL, standName))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch bookings, status: %s", resp.Status)
+	}
+
+	var bookings []Booking
+	if err := json.NewDecoder(resp.Body).Decode(&bookings); err != nil {
+		return nil, err
+	}
+	return bookings, nil
+}
+
+// BookStand бронирует стенд на интервал, при exclusive — блокирует его для других пользователей.
+// Нулевой startsAt означает бронирование с текущего момента
+func BookStand(standName string, userID int64, startsAt time.Time, endsAt time.Time, reason string, exclusive bool) (*Booking, error) {
+	body := map[string]any{"userID": userID, "endsAt": endsAt, "reason": reason, "exclusive": exclusive}
+	if !startsAt.IsZero() {
+		body["startsAt"] = startsAt
+	}
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/stands/%s/bookings", config.Config.BackendURL, standName),
+		"application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response map[string]string
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return nil, fmt.Errorf("booking failed: %s, body: %s", resp.Status, response["error"])
+	}
+
+	var booking Booking
+	if err := json.NewDecoder(resp.Body).Decode(&booking); err != nil {
+		return nil, err
+	}
+	return &booking, nil
+}
+
+// UnlockStand снимает действующее бронирование или блокировку стенда
+func UnlockStand(standName string, userID int64) (string, error) {
+	return doAccessRequest(http.MethodPost, fmt.Sprintf("/%s/unlock", standName), map[string]any{"userID": userID})
+}
+
+// CancelBooking отменяет бронирование стенда
+func CancelBooking(standName string, bookingID uint, userID int64) (string, error) {
+	return doAccessRequest(http.MethodDelete, fmt.Sprintf("/%s/bookings/%d?userID=%d", standName, bookingID, userID), nil)
+}
+
+// APIKey API-ключ сервиса. Key заполнен только у только что созданного ключа
+type APIKey struct {
+	ID          uint       `json:"id"`
+	Name        string     `json:"name"`
+	Prefix      string     `json:"prefix"`
+	Key         string     `json:"key"`
+	OwnerID     int64      `json:"owner_id"`
+	Impersonate bool       `json:"impersonate"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	LastUsedAt  *time.Time `json:"last_used_at"`
+	RevokedAt   *time.Time `json:"revoked_at"`
+}
+
+// FetchAPIKeys получает API-ключи, доступно администраторам
+func FetchAPIKeys(userID int64) ([]APIKey, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/admin/keys?userID=%d", config.Config.BackendURL, userID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch API keys, status: %s", resp.Status)
+	}
+
+	var keys []APIKey
+	if err := json.NewDecoder(resp.Body).Decode(&keys); err != nil {
+		return nil, err
+	}
+	return keys, nil
+}
+
+// CreateAPIKey создает API-ключ сервиса, durationHours 0 — бессрочный. Ключ действует от имени ownerID
+// (0 — создателя), а ключ бота (impersonate) — от имени пользователя из запроса
+func CreateAPIKey(userID int64, name string, durationHours int, ownerID int64, impersonate bool) (*APIKey, error) {
+	jsonData, err := json.Marshal(map[string]any{"userID": userID, "name": name, "durationHours": durationHours,
+		"ownerID": ownerID, "impersonate": impersonate})
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/admin/keys", config.Config.BackendURL), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response map[string]string
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return nil, fmt.Errorf("API key request failed: %s, body: %s", resp.Status, response["error"])
+	}
+
+	var key APIKey
+	if err := json.NewDecoder(resp.Body).Decode(&key); err != nil {
+		return nil, err
+	}
+	return &key, nil
+}
+
+// RevokeAPIKey отзывает API-ключ
+func RevokeAPIKey(userID int64, keyID uint) (string, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/admin/keys/%d?userID=%d", config.Config.BackendURL, keyID, userID), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("API key request failed: %s, body: %s", resp.Status, response["error"])
+	}
+	return response["message"], nil
+}
+
+// User пользователь бота с ролями и правами по ним
+type User struct {
+	ID          int64    `json:"chat_id"`
+	Name        string   `json:"name"`
+	Roles       []string `json:"roles"`
+	Permissions []string `json:"permissions"`
+}
+
+// FetchUsers получает всех пользователей с их ролями, доступно администраторам
+func FetchUsers(adminID int64) ([]User, error) {
+	resp, err := httpClient.Get(fmt.Sprintf("%s/users?adminID=%d", config.Config.BackendURL, adminID))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch users, status: %s", resp.Status)
+	}
+
+	var users []User
+	if err := json.NewDecoder(resp.Body).Decode(&users); err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+// FetchUser получает пользователя по ID или имени вместе с его правами
+func FetchUser(adminID int64, user string) (*User, error) {
+	return doUserRequest(http.MethodGet, fmt.Sprintf("/%s?adminID=%d", url.PathEscape(user), adminID), nil)
+}
+
+// AddUser добавляет пользователя Telegram с ролями
+func AddUser(adminID int64, userID int64, name string, roles []string) (*User, error) {
+	return doUserRequest(http.MethodPost, "", map[string]any{"adminID": adminID, "userID": userID, "name": name, "roles": roles})
+}
+
+// SetUserRoles заменяет роли пользователя, user — ID или имя
+func SetUserRoles(adminID int64, user string, roles []string) (*User, error) {
+	return doUserRequest(http.MethodPut, "/"+url.PathEscape(user), map[string]any{"adminID": adminID, "roles": roles})
+}
+
+// RemoveUser удаляет пользователя, user — ID или имя
+func RemoveUser(adminID int64, user string) (string, error) {
+	req, err := http.NewRequest(http.MethodDelete, fmt.Sprintf("%s/users/%s?adminID=%d", config.Config.BackendURL, url.PathEscape(user), adminID), nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	var response map[string]string
+	if err := json.NewDecoder(resp.Body).Decode(&response); err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("user request failed: %s, body: %s", resp.Status, response["error"])
+	}
+	return response["message"], nil
+}
+
+func doUserRequest(method string, path string, body map[string]any) (*User, error) {
+	var reader io.Reader
+	if body != nil {
+		jsonData, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewBuffer(jsonData)
+	}
+
+	req, err := http.NewRequest(method, fmt.Sprintf("%s/users%s", config.Config.BackendURL, path), reader)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response map[string]string
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return nil, fmt.Errorf("user request failed: %s, body: %s", resp.Status, response["error"])
+	}
+
+	var user User
+	if err := json.NewDecoder(resp.Body).Decode(&user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// AccessRequest заявка пользователя Telegram на доступ к боту
+type AccessRequest struct {
+	ID     uint   `json:"id"`
+	UserID int64  `json:"user_id"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+	Status string `json:"status"`
+	Role   string `json:"role"`
+}
+
+// RequestAccess создает заявку неизвестного пользователя на доступ к боту
+func RequestAccess(userID int64, name string, reason string) (*AccessRequest, error) {
+	return postAccessRequest("", map[string]any{"userID": userID, "name": name, "reason": reason})
+}
+
+// ApproveAccessRequest одобряет заявку на доступ с ролью role
+func ApproveAccessRequest(requestID uint, adminID int64, role string) (*AccessRequest, error) {
+	return postAccessRequest(fmt.Sprintf("/%d/approve", requestID), map[string]any{"adminID": adminID, "role": role})
+}
+
+// DenyAccessRequest отклоняет заявку на доступ
+func DenyAccessRequest(requestID uint, adminID int64) (*AccessRequest, error) {
+	return postAccessRequest(fmt.Sprintf("/%d/deny", requestID), map[string]any{"adminID": adminID})
+}
+
+func postAccessRequest(path string, body map[string]any) (*AccessRequest, error) {
+	jsonData, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient.Post(fmt.Sprintf("%s/access-requests%s", config.Config.BackendURL, path), "application/json", bytes.NewBuffer(jsonData))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		var response map[string]string
+		_ = json.NewDecoder(resp.Body).Decode(&response)
+		return nil, fmt.Errorf("access request failed: %s, body: %s", resp.Status, response["error"])
+	}
+
+	var request AccessRequest
+	if err := json.NewDecoder(resp.Body).Decode(&request); err != nil {
+		return nil, err
+	}
+	return &request, nil
+}
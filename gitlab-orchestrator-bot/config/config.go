@@ -1,8 +1,11 @@
 package config
 
 import (
+	"encoding/json"
 	"log"
+	"maps"
 	"os"
+	"strings"
 	"sync"
 
 	"github.com/joho/godotenv"
@@ -14,9 +17,29 @@ const (
 	BtnDone            = "btnDone"
 	BtnCancel          = "btnCancel"
 	BtnDoneStep2       = "btnDoneStep2"
+	BtnApproveStand    = "btnApproveStand"
+	BtnRejectStand     = "btnRejectStand"
+	BtnJobApprove      = "btnJobApprove"
+	BtnJobSkip         = "btnJobSkip"
+	BtnJobAbort        = "btnJobAbort"
+	BtnSchedulePause   = "btnSchedulePause"
+	BtnScheduleResume  = "btnScheduleResume"
+	BtnScheduleDelete  = "btnScheduleDelete"
+	BtnVersion         = "btnVersion"
+	BtnRollback        = "btnRollback"
+	BtnFleetStart      = "btnFleetStart"
+	BtnFleetPause      = "btnFleetPause"
+	BtnFleetResume     = "btnFleetResume"
+	BtnFleetAbort      = "btnFleetAbort"
+	BtnRegion          = "btnRegion"
+	BtnTeam            = "btnTeam"
+	BtnRequestAccess   = "btnRequestAccess"
+	BtnAccessApprove   = "btnAccessApprove"
+	BtnAccessDeny      = "btnAccessDeny"
 	NumberOfLinesSubos = 2
+	MaxVersionButtons  = 8
 
-	StartMessage = "Добро пожаловать в Aggregator Bot!\nВ настоящее время доступны команды:\n1. /createstand"
+	StartMessage = "Добро пожаловать в Aggregator Bot!\nВ настоящее время доступны команды:\n1. /createstand\n2. /schedules\n3. /rebuild <стенд> [ветка] [stages=ansible,helm]\n4. /retry <стенд> [stage ...]\n5. /history <стенд>\n6. /upgrade <стенд> <продукт> [тег]\n7. /extend <стенд> <часы>\n8. /share, /unshare <стенд> @пользователь — доступ к стенду\n9. /transfer <стенд> @пользователь\n10. /bookings, /book, /unbook <стенд> — бронирования стенда\n11. /lock <стенд> [причина] until ЧЧ:ММ, /unlock <стенд>\n12. /quota — квота и занятые ресурсы\n13. /team — команды и их участники\n14. /fleet — массовое обновление (администраторам)\n15. /quotas, /grant — квоты пользователей (администраторам)\n16. /keys — API-ключи сервисов (администраторам)\n17. /users, /adduser, /role, /removeuser — пользователи и роли (администраторам)"
 )
 
 var (
@@ -26,34 +49,118 @@ var (
 	UserStates    = make(map[int64]*UserContext)
 	UserStands    = make(map[int64][]StandData)
 	AdminStands   = make([]StandData, 0)
+
+	// usersMu защищает AllowedUsers, AllowedAdmins и knownUsers: список пользователей обновляется на ходу
+	usersMu    sync.RWMutex
+	knownUsers = make(map[string]int64)
 )
 
+// IsUser проверяет, допущен ли пользователь к боту
+func IsUser(id int64) bool {
+	usersMu.RLock()
+	defer usersMu.RUnlock()
+	return AllowedUsers[id]
+}
+
+// IsAdmin проверяет, является ли пользователь администратором
+func IsAdmin(id int64) bool {
+	usersMu.RLock()
+	defer usersMu.RUnlock()
+	return AllowedAdmins[id]
+}
+
+// SetAllowed заменяет списки пользователей и администраторов и сообщает, изменились ли они
+func SetAllowed(users map[int64]bool, admins map[int64]bool) bool {
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	changed := !maps.Equal(AllowedUsers, users) || !maps.Equal(AllowedAdmins, admins)
+	AllowedUsers, AllowedAdmins = users, admins
+	return changed
+}
+
+// RememberUser запоминает ID пользователя Telegram по его username, чтобы администратор мог указать @username
+func RememberUser(username string, id int64) {
+	if username == "" {
+		return
+	}
+	usersMu.Lock()
+	defer usersMu.Unlock()
+	knownUsers[strings.ToLower(username)] = id
+}
+
+// LookupUser возвращает ID пользователя, который уже писал боту, по его @username
+func LookupUser(username string) (int64, bool) {
+	usersMu.RLock()
+	defer usersMu.RUnlock()
+	id, found := knownUsers[strings.ToLower(strings.TrimPrefix(username, "@"))]
+	return id, found
+}
+
 type StandData struct {
-	NameStand string   `json:"nameStand"`
-	Products  []string `json:"products"`
-	UserID    int64    `json:"userID"`
-	Ref       string   `json:"ref"`
+	NameStand string    `json:"nameStand"`
+	Products  []Product `json:"products"`
+	UserID    int64     `json:"userID"`
+	Ref       string    `json:"ref"`
+	Region    string    `json:"region,omitempty"`
+	Type      string    `json:"type,omitempty"`
+	TeamID    uint      `json:"teamID,omitempty"`
+}
+
+// Product продукт стенда с необязательной версией
+type Product struct {
+	Code    string `json:"code"`
+	Version string `json:"version,omitempty"`
+}
+
+// UnmarshalJSON принимает и код продукта, и объект {code, version}
+func (p *Product) UnmarshalJSON(data []byte) error {
+	var code string
+	if err := json.Unmarshal(data, &code); err == nil {
+		*p = Product{Code: code}
+		return nil
+	}
+	type product Product
+	return json.Unmarshal(data, (*product)(p))
+}
+
+// String возвращает продукт в виде "code" или "code:version"
+func (p Product) String() string {
+	if p.Version == "" {
+		return p.Code
+	}
+	return p.Code + ":" + p.Version
 }
 
 type UserContext struct {
 	//vars
 	FilterSubos     map[string]bool
 	CreateStandName string
+	RejectStandName string
+	Versions        map[string]string // Выбранные версии продуктов: код -> тег
+	VersionQueue    []string          // Коды продуктов, для которых еще не выбрана версия
+	Region          string            // Выбранный регион стенда, пусто — любой
+	TeamID          uint              // Команда, которой будет принадлежать стенд, 0 — личный стенд
+	TeamName        string
 
 	//states
 	WaitingForMessageStand    bool
 	WaitingApproveCreateStand bool
+	WaitingRejectReason       bool
+	WaitingAccessReason       bool // Неизвестный пользователь пишет обоснование заявки на доступ
 }
 
 type Configuration struct {
 	// API Configuration
-	BackendURL string `env:"BACKEND_URL"`
-	BotToken   string `env:"TOKEN"`
+	BackendURL    string `env:"BACKEND_URL"`
+	BackendAPIKey string `env:"BACKEND_API_KEY"`
+	BotToken      string `env:"TOKEN"`
 
 	// Application Configuration
 	LogLevel string `env:"LOG_LEVEL" default:"info"`
 	Env      string `env:"ENV" default:"development"`
 	Domain   string `env:"DOMAIN" default:".example.com"`
+	// Тип создаваемых стендов, по нему бэкенд выдает готовые стенды из пула
+	StandType string `env:"STAND_TYPE"`
 
 	// Internal
 	mu sync.Mutex
@@ -92,6 +199,8 @@ func (c *Configuration) Inits() {
 		log.Fatalf("BACKEND_URL is not set. This is required to connect to the backend API.")
 	}
 
+	c.BackendAPIKey = os.Getenv("BACKEND_API_KEY")
+
 	// Load optional configuration with defaults
 	c.LogLevel = getEnvWithDefault("LOG_LEVEL", "info")
 	c.Env = getEnvWithDefault("ENV", "development")
@@ -104,6 +213,8 @@ func (c *Configuration) Inits() {
 		c.Domain = ".example.com" // Use the constant as default
 	}
 
+	c.StandType = os.Getenv("STAND_TYPE")
+
 	// Validate configuration
 	c.validate()
 }
@@ -120,6 +231,10 @@ func (c *Configuration) validate() {
 		log.Fatalf("TOKEN appears to be invalid: too short")
 	}
 
+	if c.BackendAPIKey == "" {
+		log.Printf("Warning: BACKEND_API_KEY is not set, requests to a backend with authentication will be rejected")
+	}
+
 	// Check that environment is valid
 	validEnvs := map[string]bool{"development": true, "staging": true, "production": true}
 	if !validEnvs[c.Env] {
@@ -134,8 +249,14 @@ func (c *Configuration) Print() {
 	log.Printf("- Log Level: %s", c.LogLevel)
 	log.Printf("- Backend URL: %s", c.BackendURL)
 	log.Printf("- Domain: %s", c.Domain)
+	log.Printf("- Stand Type: %q", c.StandType)
 
-	// Don't print the token for security reasons
+	// Don't print the token and the API key for security reasons
+	if c.BackendAPIKey != "" {
+		log.Printf("- Backend API Key: [CONFIGURED]")
+	} else {
+		log.Printf("- Backend API Key: [NOT CONFIGURED]")
+	}
 	log.Printf("- Bot Token: %s******", c.BotToken[:4])
 }
 
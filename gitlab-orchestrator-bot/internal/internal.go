@@ -3,9 +3,11 @@ package internal
 import (
 	"fmt"
 	"github.com/sirupsen/logrus"
+	"gitlab-orchestrator-bot/auth"
 	"gitlab-orchestrator-bot/client"
 	"gitlab-orchestrator-bot/config"
 	"gopkg.in/telebot.v3"
+	"html"
 	"regexp"
 	"strings"
 	"unicode"
@@ -15,6 +17,30 @@ import (
 )
 
 func SendNotifications(notification client.Notifications, bot *telebot.Bot) error {
+	switch notification.Kind {
+	case "approval":
+		return sendApprovalRequest(notification, bot)
+	case "job_approval":
+		return sendJobApprovalRequest(notification, bot)
+	case "access_request":
+		return sendAccessRequest(notification, bot)
+	case "plan":
+		return sendPlan(notification, bot)
+	case "upgrade":
+		return sendUpgradeResult(notification, bot)
+	case "fleet", "quota", "access", "booking":
+		_, err := bot.Send(&telebot.User{ID: notification.UserID}, notification.StepName+"\n"+notification.Message)
+		return err
+	case "role":
+		// Роли пользователя изменены, обновляем списки сразу, не дожидаясь периодического обновления
+		auth.InitUsers()
+		_, err := bot.Send(&telebot.User{ID: notification.UserID}, notification.StepName+"\n"+notification.Message)
+		return err
+	case "approval_result":
+		_, err := bot.Send(&telebot.User{ID: notification.UserID}, notification.Message)
+		return err
+	}
+
 	var numbers = map[int]string{
 		1: "первом",
 		2: "втором",
@@ -25,9 +51,16 @@ func SendNotifications(notification client.Notifications, bot *telebot.Bot) erro
 		"error":   "с ошибкой",
 	}
 	succeedMessage := fmt.Sprintf("Уведомление об %s этапе создания стенда %s\nЭтап прошел %s: %s", numbers[notification.Order], notification.StandName, status[notification.Status], notification.StepName)
+	if notification.Order == 0 {
+		// Уведомление не относится к этапам пайплайна
+		succeedMessage = fmt.Sprintf("Уведомление о стенде %s\n%s: %s", notification.StandName, notification.StepName, status[notification.Status])
+	}
 	if notification.Status == "error" {
 		succeedMessage += fmt.Sprintf("\nСоздание стенда %s завершилось с ошибкой на одном из этапов\nРабота по созданию стенда завершена\nДля возобновления работы нужно...", notification.StandName)
 	}
+	if notification.Message != "" {
+		succeedMessage += "\n" + notification.Message
+	}
 	_, err := bot.Send(&telebot.User{ID: notification.UserID}, succeedMessage)
 	if err != nil {
 		return err
@@ -35,6 +68,86 @@ func SendNotifications(notification client.Notifications, bot *telebot.Bot) erro
 	return nil
 }
 
+// sendApprovalRequest отправляет администратору карточку стенда с кнопками согласования
+func sendApprovalRequest(notification client.Notifications, bot *telebot.Bot) error {
+	markup := &telebot.ReplyMarkup{}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, []telebot.InlineButton{
+		{Unique: config.BtnApproveStand, Text: "✅ Согласовать", Data: notification.StandName},
+		{Unique: config.BtnRejectStand, Text: "❌ Отклонить", Data: notification.StandName},
+	})
+
+	message := fmt.Sprintf("Запрос на создание стенда %s%s\n%s", notification.StandName, config.Config.Domain, notification.Message)
+	_, err := bot.Send(&telebot.User{ID: notification.UserID}, message, markup)
+	return err
+}
+
+// sendAccessRequest отправляет администратору карточку заявки на доступ с выбором роли
+func sendAccessRequest(notification client.Notifications, bot *telebot.Bot) error {
+	requestID := fmt.Sprintf("%d", notification.RequestID)
+	markup := &telebot.ReplyMarkup{}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, []telebot.InlineButton{
+		{Unique: config.BtnAccessApprove, Text: "✅ Одобрить: user", Data: requestID + "|user"},
+		{Unique: config.BtnAccessApprove, Text: "✅ Одобрить: admin", Data: requestID + "|admin"},
+	}, []telebot.InlineButton{
+		{Unique: config.BtnAccessDeny, Text: "❌ Отклонить", Data: requestID},
+	})
+
+	_, err := bot.Send(&telebot.User{ID: notification.UserID}, notification.StepName+"\n"+notification.Message, markup)
+	return err
+}
+
+// sendJobApprovalRequest отправляет карточку manual-джобы с кнопками решения
+func sendJobApprovalRequest(notification client.Notifications, bot *telebot.Bot) error {
+	jobID := fmt.Sprintf("%d", notification.JobID)
+	markup := &telebot.ReplyMarkup{}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, []telebot.InlineButton{
+		{Unique: config.BtnJobApprove, Text: "✅ Запустить", Data: jobID},
+		{Unique: config.BtnJobSkip, Text: "⏭ Пропустить", Data: jobID},
+		{Unique: config.BtnJobAbort, Text: "⛔ Остановить", Data: jobID},
+	})
+
+	message := fmt.Sprintf("Стенд %s%s, этап %s\n%s", notification.StandName, config.Config.Domain, notification.StepName, notification.Message)
+	_, err := bot.Send(&telebot.User{ID: notification.UserID}, message, markup)
+	return err
+}
+
+// sendUpgradeResult отправляет результат обновления продукта, при ошибке — с кнопкой отката
+func sendUpgradeResult(notification client.Notifications, bot *telebot.Bot) error {
+	message := fmt.Sprintf("Стенд %s%s\n%s", notification.StandName, config.Config.Domain, notification.Message)
+	if notification.Status != "error" || notification.Product == "" {
+		_, err := bot.Send(&telebot.User{ID: notification.UserID}, message)
+		return err
+	}
+
+	markup := &telebot.ReplyMarkup{}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, []telebot.InlineButton{
+		{Unique: config.BtnRollback, Text: "↩️ Откатить", Data: notification.StandName + "|" + notification.Product},
+	})
+	_, err := bot.Send(&telebot.User{ID: notification.UserID}, message, markup)
+	return err
+}
+
+// sendPlan отправляет сводку terraform plan и полный план файлом
+func sendPlan(notification client.Notifications, bot *telebot.Bot) error {
+	recipient := &telebot.User{ID: notification.UserID}
+	message := fmt.Sprintf("План terraform для стенда %s, этап %s:\n<pre>%s</pre>",
+		notification.StandName, notification.StepName, html.EscapeString(notification.Message))
+	if _, err := bot.Send(recipient, message, telebot.ModeHTML); err != nil {
+		return err
+	}
+
+	plan, err := client.GetStepPlan(notification.StepID)
+	if err != nil {
+		return err
+	}
+	document := &telebot.Document{
+		File:     telebot.FromReader(strings.NewReader(plan.Plan)),
+		FileName: fmt.Sprintf("plan-%s.txt", notification.StandName),
+	}
+	_, err = bot.Send(recipient, document)
+	return err
+}
+
 func CheckAndSendNotifications(bot *telebot.Bot) {
 	notifications, err := client.FetchNotifications()
 	if err != nil {
@@ -195,5 +308,11 @@ func GetEmoji(user *config.UserContext, name string) string {
 func DropWaitingMessages(user *config.UserContext) {
 	user.WaitingForMessageStand = false
 	user.WaitingApproveCreateStand = false
+	user.WaitingRejectReason = false
 	user.FilterSubos = nil
+	user.Versions = nil
+	user.VersionQueue = nil
+	user.Region = ""
+	user.TeamID = 0
+	user.TeamName = ""
 }
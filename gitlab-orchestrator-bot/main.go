@@ -17,6 +17,7 @@ func main() {
 	c.Print()
 
 	auth.InitUsers()
+	auth.StartRefresh()
 
 	bot := tg.Init()
 	notify.StartNotificationScheduler(bot)
@@ -27,6 +28,30 @@ func main() {
 	//Команды
 
 	bot.Handle("/createstand", handlers.CreateNameStandHandler)
+	bot.Handle("/schedules", handlers.SchedulesHandler)
+	bot.Handle("/rebuild", handlers.RebuildStandHandler)
+	bot.Handle("/retry", handlers.RetryStandHandler)
+	bot.Handle("/history", handlers.StandHistoryHandler)
+	bot.Handle("/upgrade", handlers.UpgradeProductHandler)
+	bot.Handle("/extend", handlers.ExtendStandHandler)
+	bot.Handle("/share", handlers.ShareStandHandler)
+	bot.Handle("/unshare", handlers.UnshareStandHandler)
+	bot.Handle("/transfer", handlers.TransferStandHandler)
+	bot.Handle("/bookings", handlers.BookingsHandler)
+	bot.Handle("/book", handlers.BookStandHandler)
+	bot.Handle("/unbook", handlers.UnbookStandHandler)
+	bot.Handle("/lock", handlers.LockStandHandler)
+	bot.Handle("/unlock", handlers.UnlockStandHandler)
+	bot.Handle("/quota", handlers.QuotaHandler)
+	bot.Handle("/team", handlers.TeamHandler)
+	adminOnly.Handle("/fleet", handlers.FleetHandler)
+	adminOnly.Handle("/quotas", handlers.QuotasHandler)
+	adminOnly.Handle("/grant", handlers.GrantQuotaHandler)
+	adminOnly.Handle("/keys", handlers.KeysHandler)
+	adminOnly.Handle("/users", handlers.UsersHandler)
+	adminOnly.Handle("/adduser", handlers.AddUserHandler)
+	adminOnly.Handle("/role", handlers.RoleHandler)
+	adminOnly.Handle("/removeuser", handlers.RemoveUserHandler)
 
 	//Обработчики
 	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnDone}, handlers.SelectProductsStand)
@@ -34,6 +59,28 @@ func main() {
 	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnCancel}, handlers.CatchHandler)
 	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnAddStand}, handlers.CatchHandler)
 	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnDoneStep2}, handlers.CatchHandler)
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnVersion}, handlers.SelectVersionHandler)
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnRegion}, handlers.SelectRegionHandler)
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnTeam}, handlers.SelectTeamHandler)
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnApproveStand}, handlers.ApproveStandHandler)
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnRejectStand}, handlers.RejectStandHandler)
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnFleetStart}, handlers.FleetStartHandler)
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnFleetPause}, handlers.FleetControlHandler("pause"))
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnFleetResume}, handlers.FleetControlHandler("resume"))
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnFleetAbort}, handlers.FleetControlHandler("abort"))
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnAccessApprove}, handlers.AccessApproveHandler)
+	adminOnly.Handle(&tele.InlineButton{Unique: config.BtnAccessDeny}, handlers.AccessDenyHandler)
+	// Заявку на доступ отправляет неизвестный пользователь, UserCheckMiddleware пропускает эту кнопку
+	bot.Handle(&tele.InlineButton{Unique: config.BtnRequestAccess}, handlers.RequestAccessHandler)
+	// Решение по джобе может принять владелец стенда, права проверяет бэкенд
+	bot.Handle(&tele.InlineButton{Unique: config.BtnJobApprove}, handlers.JobDecisionHandler("approve"))
+	bot.Handle(&tele.InlineButton{Unique: config.BtnJobSkip}, handlers.JobDecisionHandler("skip"))
+	bot.Handle(&tele.InlineButton{Unique: config.BtnJobAbort}, handlers.JobDecisionHandler("abort"))
+	bot.Handle(&tele.InlineButton{Unique: config.BtnRollback}, handlers.RollbackProductHandler)
+	// Права на расписание проверяет бэкенд
+	bot.Handle(&tele.InlineButton{Unique: config.BtnSchedulePause}, handlers.ScheduleToggleHandler(true))
+	bot.Handle(&tele.InlineButton{Unique: config.BtnScheduleResume}, handlers.ScheduleToggleHandler(false))
+	bot.Handle(&tele.InlineButton{Unique: config.BtnScheduleDelete}, handlers.ScheduleDeleteHandler)
 
 	bot.Handle(tele.OnText, handlers.CatchHandler)
 
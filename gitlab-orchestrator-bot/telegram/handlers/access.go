@@ -0,0 +1,79 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab-orchestrator-bot/client"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+const shareUsage = "Использование:\n/share <стенд> — соавторы стенда\n" +
+	"/share <стенд> @пользователь [view|operate|admin]\n/unshare <стенд> @пользователь"
+
+// ShareStandHandler показывает соавторов стенда или выдает доступ: /share <стенд> [@пользователь] [право]
+func ShareStandHandler(c tele.Context) error {
+	args := c.Args()
+	switch len(args) {
+	case 1:
+		return showCollaborators(c, args[0])
+	case 2, 3:
+	default:
+		return c.Send(shareUsage)
+	}
+
+	permission := "operate"
+	if len(args) == 3 {
+		permission = args[2]
+	}
+	message, err := client.ShareStand(args[0], c.Sender().ID, args[1], permission)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при выдаче доступа к стенду: %v", err))
+	}
+	return c.Send(message)
+}
+
+// UnshareStandHandler отзывает доступ к стенду: /unshare <стенд> @пользователь
+func UnshareStandHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Send(shareUsage)
+	}
+
+	message, err := client.UnshareStand(args[0], c.Sender().ID, args[1])
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при отзыве доступа к стенду: %v", err))
+	}
+	return c.Send(message)
+}
+
+// TransferStandHandler передает стенд другому пользователю: /transfer <стенд> @пользователь
+func TransferStandHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Send("Использование: /transfer <стенд> @пользователь")
+	}
+
+	message, err := client.TransferStand(args[0], c.Sender().ID, args[1])
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при передаче стенда: %v", err))
+	}
+	return c.Send(message)
+}
+
+func showCollaborators(c tele.Context, standName string) error {
+	collaborators, err := client.FetchCollaborators(standName)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении соавторов стенда: %v", err))
+	}
+	if len(collaborators) == 0 {
+		return c.Send(fmt.Sprintf("У стенда %s нет соавторов\n\n%s", standName, shareUsage))
+	}
+
+	items := make([]string, 0, len(collaborators))
+	for _, collaborator := range collaborators {
+		items = append(items, fmt.Sprintf("%s (%d): %s", collaborator.Name, collaborator.UserID, collaborator.Permission))
+	}
+	return c.Send(fmt.Sprintf("Соавторы стенда %s:\n%s", standName, strings.Join(items, "\n")))
+}
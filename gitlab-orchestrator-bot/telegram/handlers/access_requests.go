@@ -0,0 +1,90 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab-orchestrator-bot/auth"
+	"gitlab-orchestrator-bot/client"
+	"gitlab-orchestrator-bot/config"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// UnauthorizedHandler отвечает неизвестному пользователю: предлагает запросить доступ,
+// а если он пишет обоснование заявки — отправляет заявку администраторам
+func UnauthorizedHandler(c tele.Context) error {
+	user := accessState(c.Sender().ID)
+	message := c.Message()
+	if !user.WaitingAccessReason || c.Callback() != nil || message == nil || message.Text == "" || strings.HasPrefix(message.Text, "/") {
+		user.WaitingAccessReason = false
+		markup := &tele.ReplyMarkup{}
+		markup.InlineKeyboard = append(markup.InlineKeyboard, []tele.InlineButton{
+			{Unique: config.BtnRequestAccess, Text: "🔑 Запросить доступ"},
+		})
+		return c.Send("Не авторизирован", markup)
+	}
+
+	user.WaitingAccessReason = false
+	name := c.Sender().Username
+	if name == "" {
+		name = strings.TrimSpace(c.Sender().FirstName + " " + c.Sender().LastName)
+	}
+	if _, err := client.RequestAccess(c.Sender().ID, name, message.Text); err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при отправке заявки на доступ: %v", err))
+	}
+	return c.Send("Заявка отправлена администраторам, о решении сообщим в этом чате")
+}
+
+// RequestAccessHandler запрашивает у неизвестного пользователя обоснование заявки на доступ
+func RequestAccessHandler(c tele.Context) error {
+	if config.IsUser(c.Sender().ID) {
+		return c.Respond(&tele.CallbackResponse{Text: "У вас уже есть доступ"})
+	}
+	accessState(c.Sender().ID).WaitingAccessReason = true
+	return c.Send("Кратко напишите, зачем вам нужен доступ к боту")
+}
+
+// AccessApproveHandler одобряет заявку на доступ с ролью из кнопки карточки
+func AccessApproveHandler(c tele.Context) error {
+	requestID, role, _ := strings.Cut(getCallbackData(c), "|")
+	id, err := strconv.ParseUint(requestID, 10, 64)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная заявка"})
+	}
+	request, err := client.ApproveAccessRequest(uint(id), c.Sender().ID, role)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при одобрении заявки: %v", err))
+	}
+	// Заявитель получит уведомление о роли от бэкенда, а доступ появляется сразу
+	auth.InitUsers()
+	return c.Edit(c.Message().Text + fmt.Sprintf("\n\nОдобрено, роль: %s", request.Role))
+}
+
+// AccessDenyHandler отклоняет заявку на доступ и сообщает заявителю о решении.
+// Заявителя нет среди пользователей бэкенда, поэтому уведомление отправляет бот
+func AccessDenyHandler(c tele.Context) error {
+	id, err := strconv.ParseUint(getCallbackData(c), 10, 64)
+	if err != nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректная заявка"})
+	}
+	request, err := client.DenyAccessRequest(uint(id), c.Sender().ID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при отклонении заявки: %v", err))
+	}
+	if _, err := c.Bot().Send(&tele.User{ID: request.UserID}, "Заявка на доступ к боту отклонена"); err != nil {
+		return c.Send(fmt.Sprintf("Заявка отклонена, но сообщить заявителю не удалось: %v", err))
+	}
+	return c.Edit(c.Message().Text + "\n\nОтклонено")
+}
+
+// accessState возвращает состояние неизвестного пользователя, Logger его еще не создал
+func accessState(userID int64) *config.UserContext {
+	user, exist := config.UserStates[userID]
+	if !exist {
+		user = &config.UserContext{}
+		config.UserStates[userID] = user
+	}
+	return user
+}
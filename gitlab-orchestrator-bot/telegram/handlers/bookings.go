@@ -0,0 +1,148 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab-orchestrator-bot/client"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+const bookingUsage = "Использование:\n/bookings <стенд> — кто держит стенд сейчас и далее\n" +
+	"/book <стенд> <начало> <конец> [причина] — время в формате ЧЧ:ММ или ДД.ММ-ЧЧ:ММ\n" +
+	"/unbook <стенд> <ID бронирования>\n/lock <стенд> [причина] until ЧЧ:ММ\n/unlock <стенд>"
+
+// LockStandHandler блокирует стенд для других пользователей: /lock <стенд> [причина] until ЧЧ:ММ
+func LockStandHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) < 3 || args[len(args)-2] != "until" {
+		return c.Send(bookingUsage)
+	}
+	endsAt, err := parseBookingTime(args[len(args)-1], time.Now())
+	if err != nil {
+		return c.Send(err.Error())
+	}
+
+	booking, err := client.BookStand(args[0], c.Sender().ID, time.Time{}, endsAt, strings.Join(args[1:len(args)-2], " "), true)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при блокировке стенда: %v", err))
+	}
+	return c.Send(fmt.Sprintf("Стенд %s заблокирован до %s", args[0], booking.EndsAt.Local().Format("02.01.2006 15:04")))
+}
+
+// UnlockStandHandler снимает действующее бронирование или блокировку стенда: /unlock <стенд>
+func UnlockStandHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return c.Send(bookingUsage)
+	}
+
+	message, err := client.UnlockStand(args[0], c.Sender().ID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при снятии блокировки стенда: %v", err))
+	}
+	return c.Send(message)
+}
+
+// BookStandHandler бронирует стенд на интервал: /book <стенд> <начало> <конец> [причина]
+func BookStandHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) < 3 {
+		return c.Send(bookingUsage)
+	}
+	now := time.Now()
+	startsAt, err := parseBookingTime(args[1], now)
+	if err != nil {
+		return c.Send(err.Error())
+	}
+	endsAt, err := parseBookingTime(args[2], startsAt)
+	if err != nil {
+		return c.Send(err.Error())
+	}
+
+	booking, err := client.BookStand(args[0], c.Sender().ID, startsAt, endsAt, strings.Join(args[3:], " "), false)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при бронировании стенда: %v", err))
+	}
+	return c.Send(fmt.Sprintf("Стенд %s забронирован: %s (ID %d)", args[0], formatBookingTime(*booking), booking.ID))
+}
+
+// UnbookStandHandler отменяет бронирование стенда: /unbook <стенд> <ID бронирования>
+func UnbookStandHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Send(bookingUsage)
+	}
+	bookingID, err := strconv.ParseUint(args[1], 10, 64)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Некорректный ID бронирования %s", args[1]))
+	}
+
+	message, err := client.CancelBooking(args[0], uint(bookingID), c.Sender().ID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при отмене бронирования: %v", err))
+	}
+	return c.Send(message)
+}
+
+// BookingsHandler показывает, кто держит стенд сейчас и кто забронировал его далее: /bookings <стенд>
+func BookingsHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return c.Send(bookingUsage)
+	}
+
+	bookings, err := client.FetchBookings(args[0])
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении бронирований стенда: %v", err))
+	}
+
+	current := "Сейчас: стенд свободен"
+	var next []string
+	for _, booking := range bookings {
+		if booking.Active {
+			current = "Сейчас: " + formatBooking(booking)
+			continue
+		}
+		next = append(next, formatBooking(booking))
+	}
+	text := fmt.Sprintf("Бронирования стенда %s\n%s", args[0], current)
+	if len(next) > 0 {
+		text += "\nДалее:\n" + strings.Join(next, "\n")
+	}
+	return c.Send(text)
+}
+
+func formatBooking(booking client.Booking) string {
+	text := fmt.Sprintf("%s, %s (ID %d)", booking.Name, formatBookingTime(booking), booking.ID)
+	if booking.Exclusive {
+		text += ", блокировка"
+	}
+	if booking.Reason != "" {
+		text += ": " + booking.Reason
+	}
+	return text
+}
+
+func formatBookingTime(booking client.Booking) string {
+	return booking.StartsAt.Local().Format("02.01 15:04") + " — " + booking.EndsAt.Local().Format("02.01 15:04")
+}
+
+// parseBookingTime разбирает время ЧЧ:ММ или ДД.ММ-ЧЧ:ММ. Время без даты, уже прошедшее относительно after,
+// переносится на следующий день
+func parseBookingTime(value string, after time.Time) (time.Time, error) {
+	if parsed, err := time.ParseInLocation("15:04", value, time.Local); err == nil {
+		result := time.Date(after.Year(), after.Month(), after.Day(), parsed.Hour(), parsed.Minute(), 0, 0, time.Local)
+		if !result.After(after) {
+			result = result.AddDate(0, 0, 1)
+		}
+		return result, nil
+	}
+	if parsed, err := time.ParseInLocation("02.01-15:04", value, time.Local); err == nil {
+		return time.Date(after.Year(), parsed.Month(), parsed.Day(), parsed.Hour(), parsed.Minute(), 0, 0, time.Local), nil
+	}
+	return time.Time{}, fmt.Errorf("Некорректное время %s, используйте ЧЧ:ММ или ДД.ММ-ЧЧ:ММ", value)
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+
+	"gitlab-orchestrator-bot/client"
+	"gitlab-orchestrator-bot/config"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+const fleetUsage = "Использование:\n/fleet — последние массовые обновления\n" +
+	"/fleet <продукт> <тег> [batch=N] [maxfail=N] [type=тип] [owner=ID] [label=ключ:значение]"
+
+// Массовые обновления, ожидающие подтверждения администратором
+var (
+	pendingFleet      = make(map[int64]client.FleetRequest)
+	pendingFleetMutex sync.Mutex
+)
+
+// FleetHandler показывает массовые обновления или готовит новое: /fleet <продукт> <тег> [опции]
+func FleetHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return showFleetOperations(c)
+	}
+	if len(args) < 2 {
+		return c.Send(fleetUsage)
+	}
+
+	request := client.FleetRequest{UserID: c.Sender().ID, Product: args[0], Tag: args[1], DryRun: true}
+	for _, arg := range args[2:] {
+		key, value, found := strings.Cut(arg, "=")
+		if !found {
+			return c.Send(fleetUsage)
+		}
+		switch key {
+		case "batch", "maxfail", "owner":
+			number, err := strconv.Atoi(value)
+			if err != nil || number < 0 {
+				return c.Send(fmt.Sprintf("Некорректное значение %s", arg))
+			}
+			switch key {
+			case "batch":
+				request.BatchSize = number
+			case "maxfail":
+				request.MaxFailures = number
+			case "owner":
+				request.OwnerID = uint(number)
+			}
+		case "type":
+			request.Type = value
+		case "label":
+			labelKey, labelValue, found := strings.Cut(value, ":")
+			if !found {
+				return c.Send(fmt.Sprintf("Метка задается как label=ключ:значение, получено %s", arg))
+			}
+			if request.Labels == nil {
+				request.Labels = make(map[string]string)
+			}
+			request.Labels[labelKey] = labelValue
+		default:
+			return c.Send(fleetUsage)
+		}
+	}
+
+	preview, err := client.CreateFleetOperation(request)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при подборе стендов: %v", err))
+	}
+
+	pendingFleetMutex.Lock()
+	pendingFleet[c.Sender().ID] = request
+	pendingFleetMutex.Unlock()
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("Обновить %s:%s на %d стендах пачками по %d?\n",
+		request.Product, request.Tag, preview.Progress.Total, preview.BatchSize))
+	if request.MaxFailures > 0 {
+		builder.WriteString(fmt.Sprintf("Остановка после %d ошибок\n", request.MaxFailures))
+	}
+	for _, target := range preview.Targets {
+		builder.WriteString(fmt.Sprintf("%d. %s\n", target.Batch, target.StandName))
+	}
+
+	markup := &tele.ReplyMarkup{}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, []tele.InlineButton{
+		{Unique: config.BtnFleetStart, Text: "🚀 Запустить", Data: "start"},
+		{Unique: config.BtnCancel, Text: "❌ Отмена", Data: "cancel"},
+	})
+	return c.Send(builder.String(), markup)
+}
+
+// FleetStartHandler запускает подтвержденное массовое обновление
+func FleetStartHandler(c tele.Context) error {
+	pendingFleetMutex.Lock()
+	request, ok := pendingFleet[c.Sender().ID]
+	delete(pendingFleet, c.Sender().ID)
+	pendingFleetMutex.Unlock()
+	if !ok {
+		return c.Respond(&tele.CallbackResponse{Text: "Массовое обновление уже запущено или отменено"})
+	}
+
+	request.DryRun = false
+	operation, err := client.CreateFleetOperation(request)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при запуске массового обновления: %v", err))
+	}
+	return c.Edit(formatFleetOperation(*operation), fleetButtons(*operation))
+}
+
+// FleetControlHandler приостанавливает, возобновляет или прерывает массовое обновление по кнопке
+func FleetControlHandler(action string) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		operation, err := client.ControlFleetOperation(getCallbackData(c), action, c.Sender().ID)
+		if err != nil {
+			return c.Send(fmt.Sprintf("Ошибка при управлении массовым обновлением: %v", err))
+		}
+		return c.Edit(formatFleetOperation(*operation), fleetButtons(*operation))
+	}
+}
+
+func showFleetOperations(c tele.Context) error {
+	operations, err := client.FetchFleetOperations(c.Sender().ID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении массовых обновлений: %v", err))
+	}
+	if len(operations) == 0 {
+		return c.Send("Массовых обновлений нет\n\n" + fleetUsage)
+	}
+
+	// Показываем только последние операции
+	if len(operations) > 5 {
+		operations = operations[:5]
+	}
+	for _, operation := range operations {
+		if err := c.Send(formatFleetOperation(operation), fleetButtons(operation)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatFleetOperation(operation client.FleetOperation) string {
+	progress := operation.Progress
+	text := fmt.Sprintf("Массовое обновление %d: %s:%s (%s)\nСтендов: %d, обновлено %d, в работе %d, ожидают %d, ошибок %d, пропущено %d, отменено %d",
+		operation.ID, operation.Product, operation.Tag, operation.Status,
+		progress.Total, progress.Success, progress.Running, progress.Pending,
+		progress.Failed, progress.Skipped, progress.Canceled)
+	if operation.Message != "" {
+		text += "\n" + operation.Message
+	}
+	for _, target := range operation.Targets {
+		if target.Status == "error" || target.Status == "skipped" {
+			text += fmt.Sprintf("\n• %s: %s %s", target.StandName, target.Status, target.Message)
+		}
+	}
+	return text
+}
+
+func fleetButtons(operation client.FleetOperation) *tele.ReplyMarkup {
+	id := fmt.Sprintf("%d", operation.ID)
+	markup := &tele.ReplyMarkup{}
+	switch operation.Status {
+	case "running":
+		markup.InlineKeyboard = append(markup.InlineKeyboard, []tele.InlineButton{
+			{Unique: config.BtnFleetPause, Text: "⏸ Приостановить", Data: id},
+			{Unique: config.BtnFleetAbort, Text: "⛔ Прервать", Data: id},
+		})
+	case "paused":
+		markup.InlineKeyboard = append(markup.InlineKeyboard, []tele.InlineButton{
+			{Unique: config.BtnFleetResume, Text: "▶️ Возобновить", Data: id},
+			{Unique: config.BtnFleetAbort, Text: "⛔ Прервать", Data: id},
+		})
+	}
+	return markup
+}
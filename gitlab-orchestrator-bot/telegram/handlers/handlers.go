@@ -65,26 +65,33 @@ func NameToCodeSubo(FilterSubos map[string]bool, subos map[string]string) []stri
 	return selectedProducts
 }
 
-func CreateStand(c *config.UserContext, userID int64) (string, error) {
+// CreateStand отправляет стенд на создание и возвращает сообщение бэкенда и имя созданного или выданного стенда
+func CreateStand(c *config.UserContext, userID int64) (string, string, error) {
 	// Get all available subos
 	subos, err := fetchSubos()
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
-	// Create a slice for selected products
-	selectedProducts := NameToCodeSubo(c.FilterSubos, subos)
+	// Create a slice for selected products with chosen versions
+	var selectedProducts []config.Product
+	for _, code := range NameToCodeSubo(c.FilterSubos, subos) {
+		selectedProducts = append(selectedProducts, config.Product{Code: code, Version: c.Versions[code]})
+	}
 
 	standData := config.StandData{
 		NameStand: c.CreateStandName,
 		Products:  selectedProducts,
 		UserID:    userID,
 		Ref:       "master",
+		Region:    c.Region,
+		Type:      config.Config.StandType,
+		TeamID:    c.TeamID,
 	}
 
 	jsonData, err := json.Marshal(&standData)
 	if err != nil {
-		return "", err
+		return "", "", err
 	}
 
 	return client.SendStandToBackend(jsonData)
@@ -109,11 +116,16 @@ func CatchHandler(c tele.Context) error {
 
 		// Handle final stand creation approval
 		if user.WaitingApproveCreateStand && strings.HasPrefix(data, "yes ") {
-			response, err := CreateStand(user, userID)
+			response, nameStand, err := CreateStand(user, userID)
 			if err != nil {
 				return c.Send(fmt.Sprintf("Ошибка при создании стенда: %v", err))
 			}
-			c.Edit(fmt.Sprintf("Вы выбрали создать стенд %s%s с продуктами\n%v", user.CreateStandName, config.Config.Domain, user.FilterSubos))
+			text := fmt.Sprintf("Вы выбрали создать стенд %s%s с продуктами\n%s", user.CreateStandName, config.Config.Domain, selectedProductsText(user)+regionText(user)+teamText(user))
+			// Вместо запрошенного стенда бэкенд может выдать готовый стенд из пула с другим именем
+			if nameStand != "" && nameStand != user.CreateStandName {
+				text += fmt.Sprintf("\nВместо него выдан готовый стенд %s%s", nameStand, config.Config.Domain)
+			}
+			c.Edit(text)
 			c.Send(response)
 			user.WaitingApproveCreateStand = false
 			return nil
@@ -132,6 +144,16 @@ func CatchHandler(c tele.Context) error {
 	// Handle text messages
 	message := c.Message().Text
 
+	// Handle stand rejection reason input
+	if user.WaitingRejectReason {
+		user.WaitingRejectReason = false
+		response, err := client.RejectStand(user.RejectStandName, userID, message)
+		if err != nil {
+			return c.Send(fmt.Sprintf("Ошибка при отклонении стенда: %v", err))
+		}
+		return c.Send(response)
+	}
+
 	// Handle stand name input
 	if user.WaitingForMessageStand {
 		standName := internal.CutSpecAndSpaceCimbols(message)
@@ -163,7 +185,7 @@ func SelectProductsStand(c tele.Context) error {
 			return nil
 		}
 
-		return handleDoneSelection(c, user)
+		return startVersionSelection(c, user)
 	}
 
 	if data != "" && !strings.HasPrefix(data, "yes ") {
@@ -184,7 +206,7 @@ func getCallbackData(c tele.Context) string {
 // Обработка завершения выбора
 func handleDoneSelection(c tele.Context, user *config.UserContext) error {
 	markup := CreateButtonsVerify("test", config.BtnDoneStep2)
-	err := c.Edit(fmt.Sprintf("Вы хотите создать стенд %s%s с такими продуктами?\n%v", user.CreateStandName, config.Config.Domain, user.FilterSubos), markup)
+	err := c.Edit(fmt.Sprintf("Вы хотите создать стенд %s%s с такими продуктами?\n%s", user.CreateStandName, config.Config.Domain, selectedProductsText(user)+regionText(user)+teamText(user)), markup)
 	if err != nil {
 		return err
 	}
@@ -218,3 +240,33 @@ func sendUpdatedKeyboard(c tele.Context, user *config.UserContext) error {
 	}
 	return c.Send(fmt.Sprintf("Вы выбрали создать стенд с названием: %s\nТеперь вы можете выбрать продукты, которые должны быть на этом стенде", user.CreateStandName), markup)
 }
+
+// ApproveStandHandler согласует стенд по кнопке из карточки запроса
+func ApproveStandHandler(c tele.Context) error {
+	standName := getCallbackData(c)
+	response, err := client.ApproveStand(standName, c.Sender().ID, "")
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при согласовании стенда: %v", err))
+	}
+	return c.Edit(c.Message().Text + "\n\n" + response)
+}
+
+// RejectStandHandler запрашивает у администратора причину отклонения стенда
+func RejectStandHandler(c tele.Context) error {
+	user := config.UserStates[c.Sender().ID]
+	internal.DropWaitingMessages(user)
+	user.RejectStandName = getCallbackData(c)
+	user.WaitingRejectReason = true
+	return c.Send(fmt.Sprintf("Напишите причину отклонения стенда %s", user.RejectStandName))
+}
+
+// JobDecisionHandler передает решение по manual-джобе, выбранное кнопкой в карточке
+func JobDecisionHandler(decision string) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		response, err := client.DecideJob(getCallbackData(c), c.Sender().ID, decision)
+		if err != nil {
+			return c.Send(fmt.Sprintf("Ошибка при принятии решения по джобе: %v", err))
+		}
+		return c.Edit(c.Message().Text + "\n\n" + response)
+	}
+}
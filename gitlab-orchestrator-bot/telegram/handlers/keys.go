@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab-orchestrator-bot/client"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+const keysUsage = "Использование:\n/keys — API-ключи сервисов\n/keys create <имя> [часы] [owner=ID | bot]\n/keys revoke <ID>\n" +
+	"Ключ действует от имени владельца (по умолчанию — вас), ключ bot — от имени любого пользователя"
+
+// KeysHandler показывает и изменяет API-ключи сервисов: /keys [create <имя> [часы] [owner=ID | bot] | revoke <ID>]
+func KeysHandler(c tele.Context) error {
+	args := c.Args()
+	userID := c.Sender().ID
+	if len(args) == 0 {
+		return showAPIKeys(c)
+	}
+	if len(args) < 2 {
+		return c.Send(keysUsage)
+	}
+
+	switch args[0] {
+	case "create":
+		hours, ownerID, impersonate := 0, int64(0), false
+		for _, arg := range args[2:] {
+			switch {
+			case arg == "bot":
+				impersonate = true
+			case strings.HasPrefix(arg, "owner="):
+				parsed, err := strconv.ParseInt(strings.TrimPrefix(arg, "owner="), 10, 64)
+				if err != nil || parsed <= 0 {
+					return c.Send(fmt.Sprintf("Некорректный владелец %s", arg))
+				}
+				ownerID = parsed
+			default:
+				parsed, err := strconv.Atoi(arg)
+				if err != nil || parsed < 0 {
+					return c.Send(fmt.Sprintf("Некорректное число часов %s", arg))
+				}
+				hours = parsed
+			}
+		}
+		key, err := client.CreateAPIKey(userID, args[1], hours, ownerID, impersonate)
+		if err != nil {
+			return c.Send(fmt.Sprintf("Ошибка при создании API-ключа: %v", err))
+		}
+		return c.Send(fmt.Sprintf("API-ключ %s создан, сохраните его — повторно он не показывается:\n%s", key.Name, key.Key))
+	case "revoke":
+		keyID, err := strconv.ParseUint(args[1], 10, 64)
+		if err != nil {
+			return c.Send(fmt.Sprintf("Некорректный ID ключа %s", args[1]))
+		}
+		message, err := client.RevokeAPIKey(userID, uint(keyID))
+		if err != nil {
+			return c.Send(fmt.Sprintf("Ошибка при отзыве API-ключа: %v", err))
+		}
+		return c.Send(message)
+	default:
+		return c.Send(keysUsage)
+	}
+}
+
+func showAPIKeys(c tele.Context) error {
+	keys, err := client.FetchAPIKeys(c.Sender().ID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении API-ключей: %v", err))
+	}
+	if len(keys) == 0 {
+		return c.Send("API-ключей нет\n\n" + keysUsage)
+	}
+
+	items := make([]string, 0, len(keys))
+	for _, key := range keys {
+		item := fmt.Sprintf("%d. %s (%s...)", key.ID, key.Name, key.Prefix)
+		if key.Impersonate {
+			item += ", ключ бота"
+		} else if key.OwnerID != 0 {
+			item += fmt.Sprintf(", владелец %d", key.OwnerID)
+		}
+		switch {
+		case key.RevokedAt != nil:
+			item += ", отозван"
+		case key.ExpiresAt != nil:
+			item += ", до " + key.ExpiresAt.Local().Format("02.01.2006 15:04")
+		}
+		if key.LastUsedAt != nil {
+			item += ", использован " + key.LastUsedAt.Local().Format("02.01.2006 15:04")
+		}
+		items = append(items, item)
+	}
+	return c.Send(strings.Join(items, "\n") + "\n\n" + keysUsage)
+}
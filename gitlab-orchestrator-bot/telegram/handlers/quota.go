@@ -0,0 +1,113 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab-orchestrator-bot/client"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+const grantUsage = "Использование:\n/grant <ID пользователя> [stands=N] [vms=N] [ttl=N] [for=часы] [причина]\n" +
+	"Значения прибавляются к квоте роли, отрицательные уменьшают ее. Без for изменение постоянное"
+
+// QuotaHandler показывает квоту пользователя и ее использование
+func QuotaHandler(c tele.Context) error {
+	status, err := client.FetchQuota(c.Sender().ID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении квоты: %v", err))
+	}
+	return c.Send(formatQuota(*status))
+}
+
+// QuotasHandler показывает администратору квоты всех пользователей
+func QuotasHandler(c tele.Context) error {
+	quotas, err := client.FetchQuotas(c.Sender().ID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении квот: %v", err))
+	}
+	if len(quotas) == 0 {
+		return c.Send("Пользователей нет")
+	}
+
+	items := make([]string, 0, len(quotas))
+	for _, status := range quotas {
+		items = append(items, formatQuota(status))
+	}
+	return c.Send(strings.Join(items, "\n\n") + "\n\n" + grantUsage)
+}
+
+// GrantQuotaHandler изменяет квоту пользователя: /grant <ID> [stands=N] [vms=N] [ttl=N] [for=часы] [причина]
+func GrantQuotaHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) < 2 {
+		return c.Send(grantUsage)
+	}
+	userID, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return c.Send(grantUsage)
+	}
+
+	request := client.QuotaGrantRequest{AdminID: c.Sender().ID, UserID: userID}
+	var reason []string
+	for _, arg := range args[1:] {
+		key, value, found := strings.Cut(arg, "=")
+		if !found || len(reason) > 0 {
+			reason = append(reason, arg)
+			continue
+		}
+		number, err := strconv.Atoi(value)
+		if err != nil {
+			return c.Send(fmt.Sprintf("Некорректное значение %s", arg))
+		}
+		switch key {
+		case "stands":
+			request.Stands = number
+		case "vms":
+			request.VMs = number
+		case "ttl":
+			request.TTLHours = number
+		case "for":
+			request.DurationHours = number
+		default:
+			return c.Send(grantUsage)
+		}
+	}
+	request.Reason = strings.Join(reason, " ")
+
+	status, err := client.GrantQuota(request)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при изменении квоты: %v", err))
+	}
+	return c.Send("Квота изменена\n" + formatQuota(*status))
+}
+
+func formatQuota(status client.QuotaStatus) string {
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("%s (%d), роли: %s\n", status.Name, status.UserID, status.Role))
+	builder.WriteString(fmt.Sprintf("Стенды: %d из %s\n", len(status.Usage.Stands), quotaLimit(status.Limits.Stands)))
+	builder.WriteString(fmt.Sprintf("ВМ: %d из %s\n", status.Usage.VMs, quotaLimit(status.Limits.VMs)))
+	builder.WriteString(fmt.Sprintf("Срок жизни стенда: %s ч", quotaLimit(status.Limits.TTLHours)))
+	if len(status.Usage.Stands) > 0 {
+		builder.WriteString("\nАктивные стенды: " + strings.Join(status.Usage.Stands, ", "))
+	}
+	for _, grant := range status.Grants {
+		builder.WriteString(fmt.Sprintf("\n• стенды %+d, ВМ %+d, срок %+d ч", grant.Stands, grant.VMs, grant.TTLHours))
+		if grant.ExpiresAt != nil {
+			builder.WriteString(" до " + grant.ExpiresAt.Format("02.01.2006 15:04"))
+		}
+		if grant.Reason != "" {
+			builder.WriteString(": " + grant.Reason)
+		}
+	}
+	return builder.String()
+}
+
+func quotaLimit(limit int) string {
+	if limit == 0 {
+		return "∞"
+	}
+	return strconv.Itoa(limit)
+}
@@ -0,0 +1,65 @@
+package handlers
+
+import (
+	"fmt"
+
+	"gitlab-orchestrator-bot/client"
+	"gitlab-orchestrator-bot/config"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// anyRegion данные кнопки, оставляющей выбор региона бэкенду
+const anyRegion = "*"
+
+// askRegion предлагает выбрать регион размещения стенда.
+// Если выбирать не из чего, сразу показывается подтверждение
+func askRegion(c tele.Context, user *config.UserContext) error {
+	regions, err := client.FetchRegions()
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении регионов: %v", err))
+	}
+	if len(regions) < 2 {
+		return askTeam(c, user)
+	}
+
+	markup := &tele.ReplyMarkup{}
+	var row []tele.InlineButton
+	for _, region := range regions {
+		row = append(row, tele.InlineButton{Unique: config.BtnRegion, Text: region, Data: region})
+		if len(row) == config.NumberOfLinesSubos {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+			row = []tele.InlineButton{}
+		}
+	}
+	if len(row) > 0 {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+	}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, []tele.InlineButton{
+		{Unique: config.BtnRegion, Text: "Любой", Data: anyRegion},
+		{Unique: config.BtnCancel, Text: "❌ Отмена", Data: "cancel"},
+	})
+	return c.Edit("Выберите регион для стенда", markup)
+}
+
+// SelectRegionHandler запоминает выбранный регион и предлагает выбрать команду
+func SelectRegionHandler(c tele.Context) error {
+	user := config.UserStates[c.Sender().ID]
+	if user.FilterSubos == nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Выбор региона устарел"})
+	}
+
+	user.Region = c.Callback().Data
+	if user.Region == anyRegion {
+		user.Region = ""
+	}
+	return askTeam(c, user)
+}
+
+// regionText возвращает строку с выбранным регионом для подтверждения
+func regionText(user *config.UserContext) string {
+	if user.Region == "" {
+		return ""
+	}
+	return "\nРегион: " + user.Region
+}
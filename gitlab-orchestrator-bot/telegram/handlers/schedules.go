@@ -0,0 +1,96 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab-orchestrator-bot/client"
+	"gitlab-orchestrator-bot/config"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// SchedulesHandler показывает расписания пользователя, администратору — все расписания
+func SchedulesHandler(c tele.Context) error {
+	userID := c.Sender().ID
+	filter := userID
+	if config.IsAdmin(userID) {
+		filter = 0
+	}
+
+	schedules, err := client.FetchSchedules(filter)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении расписаний: %v", err))
+	}
+	if len(schedules) == 0 {
+		return c.Send("Расписаний нет")
+	}
+
+	for _, schedule := range schedules {
+		if err := c.Send(formatSchedule(schedule), scheduleButtons(schedule)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func formatProducts(products []config.Product) string {
+	items := make([]string, 0, len(products))
+	for _, product := range products {
+		items = append(items, product.String())
+	}
+	return strings.Join(items, ", ")
+}
+
+func formatSchedule(schedule client.Schedule) string {
+	state := "активно"
+	if schedule.Paused {
+		state = "приостановлено"
+	}
+	text := fmt.Sprintf("Стенд %s%s\nРасписание: %s (%s), %s\nВетка: %s\nПродукты: %s",
+		schedule.StandName, config.Config.Domain, schedule.Cron, schedule.TimeZone, state,
+		schedule.Ref, formatProducts(schedule.Products))
+	if schedule.NextRunAt != nil {
+		text += "\nСледующий запуск: " + *schedule.NextRunAt
+	}
+	if schedule.LastResult != "" {
+		text += "\nПоследний запуск: " + schedule.LastResult
+	}
+	return text
+}
+
+func scheduleButtons(schedule client.Schedule) *tele.ReplyMarkup {
+	id := fmt.Sprintf("%d", schedule.ID)
+	toggle := tele.InlineButton{Unique: config.BtnSchedulePause, Text: "⏸ Приостановить", Data: id}
+	if schedule.Paused {
+		toggle = tele.InlineButton{Unique: config.BtnScheduleResume, Text: "▶️ Возобновить", Data: id}
+	}
+
+	markup := &tele.ReplyMarkup{}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, []tele.InlineButton{
+		toggle,
+		{Unique: config.BtnScheduleDelete, Text: "🗑 Удалить", Data: id},
+	})
+	return markup
+}
+
+// ScheduleToggleHandler приостанавливает или возобновляет расписание по кнопке
+func ScheduleToggleHandler(paused bool) tele.HandlerFunc {
+	return func(c tele.Context) error {
+		if err := client.SetSchedulePaused(getCallbackData(c), c.Sender().ID, paused); err != nil {
+			return c.Send(fmt.Sprintf("Ошибка при изменении расписания: %v", err))
+		}
+		if paused {
+			return c.Edit(c.Message().Text + "\n\nРасписание приостановлено")
+		}
+		return c.Edit(c.Message().Text + "\n\nРасписание возобновлено")
+	}
+}
+
+// ScheduleDeleteHandler удаляет расписание по кнопке
+func ScheduleDeleteHandler(c tele.Context) error {
+	if err := client.DeleteSchedule(getCallbackData(c), c.Sender().ID); err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при удалении расписания: %v", err))
+	}
+	return c.Edit(c.Message().Text + "\n\nРасписание удалено")
+}
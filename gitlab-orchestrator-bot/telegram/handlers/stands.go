@@ -0,0 +1,110 @@
+package handlers
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab-orchestrator-bot/client"
+	"gitlab-orchestrator-bot/config"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// RebuildStandHandler пересоздает стенд: /rebuild <стенд> [ветка] [stages=ansible,helm]
+func RebuildStandHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send("Укажите стенд: /rebuild <стенд> [ветка] [stages=ansible,helm]")
+	}
+
+	ref := ""
+	var stages []string
+	for _, arg := range args[1:] {
+		if value, found := strings.CutPrefix(arg, "stages="); found {
+			stages = strings.Split(value, ",")
+			continue
+		}
+		ref = arg
+	}
+	response, err := client.RebuildStand(args[0], c.Sender().ID, ref, stages)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при пересоздании стенда: %v", err))
+	}
+	return c.Send(response)
+}
+
+// RetryStandHandler повторяет пайплайн стенда на его ветке: /retry <стенд> [stage ...]
+func RetryStandHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send("Укажите стенд: /retry <стенд> [stage ...], например /retry sf-31 ansible")
+	}
+
+	response, err := client.RetryStand(args[0], c.Sender().ID, args[1:])
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при повторном запуске стенда: %v", err))
+	}
+	return c.Send(response)
+}
+
+// StandHistoryHandler показывает историю пайплайнов стенда: /history <стенд>
+func StandHistoryHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send("Укажите стенд: /history <стенд>")
+	}
+
+	pipelines, err := client.FetchStandPipelines(args[0])
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении истории стенда: %v", err))
+	}
+	if len(pipelines) == 0 {
+		return c.Send(fmt.Sprintf("У стенда %s еще нет пайплайнов", args[0]))
+	}
+
+	var builder strings.Builder
+	builder.WriteString(fmt.Sprintf("История стенда %s%s\n", args[0], config.Config.Domain))
+	for _, pipeline := range pipelines {
+		current := ""
+		if pipeline.Current {
+			current = " (текущий)"
+		}
+		builder.WriteString(fmt.Sprintf("\n#%d%s %s, ветка %s, %s\n", pipeline.ID, current, pipeline.Status, pipeline.Ref, pipeline.CreatedAt))
+		for _, step := range pipeline.Steps {
+			builder.WriteString(fmt.Sprintf("  • %s: %s\n", step.Name, step.Status))
+		}
+	}
+	return c.Send(builder.String())
+}
+
+// UpgradeProductHandler обновляет один продукт на стенде: /upgrade <стенд> <продукт> [тег]
+func UpgradeProductHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) < 2 {
+		return c.Send("Укажите стенд и продукт: /upgrade <стенд> <продукт> [тег]")
+	}
+
+	tag := ""
+	if len(args) > 2 {
+		tag = args[2]
+	}
+	response, err := client.UpgradeProduct(args[0], args[1], c.Sender().ID, tag)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при обновлении продукта: %v", err))
+	}
+	return c.Send(response)
+}
+
+// RollbackProductHandler откатывает продукт после неудачного обновления по кнопке из уведомления
+func RollbackProductHandler(c tele.Context) error {
+	standName, code, found := strings.Cut(getCallbackData(c), "|")
+	if !found {
+		return c.Respond(&tele.CallbackResponse{Text: "Некорректные данные кнопки"})
+	}
+
+	response, err := client.RollbackProduct(standName, code, c.Sender().ID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при откате продукта: %v", err))
+	}
+	return c.Edit(c.Message().Text + "\n\n" + response)
+}
@@ -0,0 +1,208 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab-orchestrator-bot/client"
+	"gitlab-orchestrator-bot/config"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// personalStand данные кнопки, создающей личный стенд без команды
+const personalStand = "-"
+
+const teamUsage = "Использование:\n/team — команды и их участники\n" +
+	"/team create <команда> [описание]\n/team add <команда> <ID пользователя> [owner]\n" +
+	"/team remove <команда> <ID пользователя>\n/team delete <команда>\n" +
+	"/team quota <команда> [stands=N] [vms=N] [ttl=N] — квота команды (администраторам)"
+
+// TeamHandler показывает команды пользователя и управляет ими: /team [create|add|remove|delete|quota] ...
+func TeamHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return showTeams(c)
+	}
+	if len(args) < 2 {
+		return c.Send(teamUsage)
+	}
+
+	userID := c.Sender().ID
+	name := args[1]
+	var err error
+	switch args[0] {
+	case "create":
+		err = client.CreateTeam(name, userID, strings.Join(args[2:], " "))
+	case "add", "remove":
+		if len(args) < 3 {
+			return c.Send(teamUsage)
+		}
+		memberID, parseErr := strconv.ParseInt(args[2], 10, 64)
+		if parseErr != nil {
+			return c.Send(fmt.Sprintf("Некорректный ID пользователя %s", args[2]))
+		}
+		if args[0] == "remove" {
+			err = client.RemoveTeamMember(name, memberID, userID)
+			break
+		}
+		role := "member"
+		if len(args) > 3 {
+			role = args[3]
+		}
+		err = client.SaveTeamMember(name, memberID, userID, role)
+	case "delete":
+		err = client.DeleteTeam(name, userID)
+	case "quota":
+		var limits [3]*int
+		for _, arg := range args[2:] {
+			key, value, found := strings.Cut(arg, "=")
+			number, parseErr := strconv.Atoi(value)
+			if !found || parseErr != nil || number < 0 {
+				return c.Send(fmt.Sprintf("Некорректное значение %s", arg))
+			}
+			switch key {
+			case "stands":
+				limits[0] = &number
+			case "vms":
+				limits[1] = &number
+			case "ttl":
+				limits[2] = &number
+			default:
+				return c.Send(teamUsage)
+			}
+		}
+		err = client.UpdateTeamQuota(name, userID, limits[0], limits[1], limits[2])
+	default:
+		return c.Send(teamUsage)
+	}
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при изменении команды %s: %v", name, err))
+	}
+	if args[0] == "delete" {
+		return c.Send(fmt.Sprintf("Команда %s удалена", name))
+	}
+	return c.Send(fmt.Sprintf("Команда %s изменена", name))
+}
+
+// ExtendStandHandler продлевает срок жизни стенда: /extend <стенд> <часы>
+func ExtendStandHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 2 {
+		return c.Send("Использование: /extend <стенд> <часы>")
+	}
+	hours, err := strconv.Atoi(args[1])
+	if err != nil || hours <= 0 {
+		return c.Send(fmt.Sprintf("Некорректное число часов %s", args[1]))
+	}
+
+	message, err := client.ExtendStand(args[0], c.Sender().ID, hours)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при продлении стенда: %v", err))
+	}
+	return c.Send(message)
+}
+
+func showTeams(c tele.Context) error {
+	filter := c.Sender().ID
+	if config.IsAdmin(filter) {
+		filter = 0
+	}
+	teams, err := client.FetchTeams(filter)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении команд: %v", err))
+	}
+	if len(teams) == 0 {
+		return c.Send("Команд нет\n\n" + teamUsage)
+	}
+
+	items := make([]string, 0, len(teams))
+	for _, team := range teams {
+		items = append(items, formatTeam(team))
+	}
+	return c.Send(strings.Join(items, "\n\n") + "\n\n" + teamUsage)
+}
+
+func formatTeam(team client.Team) string {
+	members := make([]string, 0, len(team.Members))
+	for _, member := range team.Members {
+		members = append(members, fmt.Sprintf("%d (%s)", member.UserID, member.Role))
+	}
+
+	text := "Команда " + team.Name
+	if team.Description != "" {
+		text += " — " + team.Description
+	}
+	text += "\nУчастники: " + strings.Join(members, ", ")
+	text += fmt.Sprintf("\nСтенды: %d из %s, ВМ: %d из %s, срок жизни стенда: %s ч",
+		len(team.Quota.Usage.Stands), quotaLimit(team.Quota.Limits.Stands),
+		team.Quota.Usage.VMs, quotaLimit(team.Quota.Limits.VMs), quotaLimit(team.Quota.Limits.TTLHours))
+	if len(team.Quota.Usage.Stands) > 0 {
+		text += "\nСтенды команды: " + strings.Join(team.Quota.Usage.Stands, ", ")
+	}
+	return text
+}
+
+// askTeam предлагает выбрать команду, которой будет принадлежать стенд.
+// Если пользователь не состоит в командах, сразу показывается подтверждение
+func askTeam(c tele.Context, user *config.UserContext) error {
+	teams, err := client.FetchTeams(c.Sender().ID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении команд: %v", err))
+	}
+	if len(teams) == 0 {
+		return handleDoneSelection(c, user)
+	}
+
+	markup := &tele.ReplyMarkup{}
+	var row []tele.InlineButton
+	for _, team := range teams {
+		row = append(row, tele.InlineButton{Unique: config.BtnTeam, Text: team.Name, Data: team.Name})
+		if len(row) == config.NumberOfLinesSubos {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+			row = []tele.InlineButton{}
+		}
+	}
+	if len(row) > 0 {
+		markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+	}
+	markup.InlineKeyboard = append(markup.InlineKeyboard, []tele.InlineButton{
+		{Unique: config.BtnTeam, Text: "Личный стенд", Data: personalStand},
+		{Unique: config.BtnCancel, Text: "❌ Отмена", Data: "cancel"},
+	})
+	return c.Edit("Выберите команду, которой будет принадлежать стенд", markup)
+}
+
+// SelectTeamHandler запоминает выбранную команду и показывает подтверждение
+func SelectTeamHandler(c tele.Context) error {
+	user := config.UserStates[c.Sender().ID]
+	if user.FilterSubos == nil {
+		return c.Respond(&tele.CallbackResponse{Text: "Выбор команды устарел"})
+	}
+
+	user.TeamID, user.TeamName = 0, ""
+	if name := c.Callback().Data; name != personalStand {
+		teams, err := client.FetchTeams(c.Sender().ID)
+		if err != nil {
+			return c.Send(fmt.Sprintf("Ошибка при получении команд: %v", err))
+		}
+		for _, team := range teams {
+			if team.Name == name {
+				user.TeamID, user.TeamName = team.ID, team.Name
+			}
+		}
+		if user.TeamID == 0 {
+			return c.Respond(&tele.CallbackResponse{Text: "Вы больше не состоите в команде " + name})
+		}
+	}
+	return handleDoneSelection(c, user)
+}
+
+// teamText возвращает строку с выбранной командой для подтверждения
+func teamText(user *config.UserContext) string {
+	if user.TeamName == "" {
+		return ""
+	}
+	return "\nКоманда: " + user.TeamName
+}
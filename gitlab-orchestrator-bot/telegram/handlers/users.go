@@ -0,0 +1,134 @@
+package handlers
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gitlab-orchestrator-bot/auth"
+	"gitlab-orchestrator-bot/client"
+	"gitlab-orchestrator-bot/config"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+const usersUsage = "Использование:\n/users — пользователи и их роли\n/adduser <ID|@username> <роль[,роль]>\n/role <ID|@username> [роль[,роль]]\n/removeuser <ID|@username>"
+
+// UsersHandler показывает пользователей бота и их роли: /users
+func UsersHandler(c tele.Context) error {
+	users, err := client.FetchUsers(c.Sender().ID)
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при получении пользователей: %v", err))
+	}
+	if len(users) == 0 {
+		return c.Send("Пользователей нет\n\n" + usersUsage)
+	}
+
+	items := make([]string, 0, len(users))
+	for _, user := range users {
+		roles := "без ролей"
+		if len(user.Roles) > 0 {
+			roles = strings.Join(user.Roles, ", ")
+		}
+		items = append(items, fmt.Sprintf("%s (%d): %s", user.Name, user.ID, roles))
+	}
+	return c.Send(strings.Join(items, "\n") + "\n\n" + usersUsage)
+}
+
+// AddUserHandler добавляет пользователя с ролями: /adduser <ID|@username> <роль[,роль]>.
+// По @username находится только пользователь, который уже писал боту
+func AddUserHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) < 2 {
+		return c.Send(usersUsage)
+	}
+
+	userID, name, err := resolveTelegramUser(c, args[0])
+	if err != nil {
+		return c.Send(err.Error())
+	}
+	user, err := client.AddUser(c.Sender().ID, userID, name, parseRoles(args[1:]))
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при добавлении пользователя: %v", err))
+	}
+	auth.InitUsers()
+	return c.Send(fmt.Sprintf("Пользователь %s (%d) добавлен с ролями: %s", user.Name, user.ID, strings.Join(user.Roles, ", ")))
+}
+
+// RoleHandler показывает или заменяет роли пользователя: /role <ID|@username> [роль[,роль]]
+func RoleHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) == 0 {
+		return c.Send(usersUsage)
+	}
+
+	ref := strings.TrimPrefix(args[0], "@")
+	if len(args) == 1 {
+		user, err := client.FetchUser(c.Sender().ID, ref)
+		if err != nil {
+			return c.Send(fmt.Sprintf("Ошибка при получении пользователя: %v", err))
+		}
+		return c.Send(fmt.Sprintf("%s (%d)\nРоли: %s\nПрава: %s", user.Name, user.ID,
+			joinOrDash(user.Roles), joinOrDash(user.Permissions)))
+	}
+
+	user, err := client.SetUserRoles(c.Sender().ID, ref, parseRoles(args[1:]))
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при изменении ролей: %v", err))
+	}
+	auth.InitUsers()
+	return c.Send(fmt.Sprintf("Роли пользователя %s: %s", user.Name, strings.Join(user.Roles, ", ")))
+}
+
+// RemoveUserHandler удаляет пользователя и отзывает его доступ к боту: /removeuser <ID|@username>
+func RemoveUserHandler(c tele.Context) error {
+	args := c.Args()
+	if len(args) != 1 {
+		return c.Send(usersUsage)
+	}
+
+	message, err := client.RemoveUser(c.Sender().ID, strings.TrimPrefix(args[0], "@"))
+	if err != nil {
+		return c.Send(fmt.Sprintf("Ошибка при удалении пользователя: %v", err))
+	}
+	auth.InitUsers()
+	return c.Send(message)
+}
+
+// resolveTelegramUser возвращает ID и имя пользователя Telegram по ID или @username
+func resolveTelegramUser(c tele.Context, ref string) (int64, string, error) {
+	if userID, err := strconv.ParseInt(ref, 10, 64); err == nil {
+		// Имя известно, только если пользователь уже писал боту
+		if chat, err := c.Bot().ChatByID(userID); err == nil && chat.Username != "" {
+			return userID, chat.Username, nil
+		}
+		return userID, "", nil
+	}
+
+	username := strings.TrimPrefix(ref, "@")
+	userID, found := config.LookupUser(username)
+	if !found {
+		return 0, "", fmt.Errorf("Пользователь @%s еще не писал боту, укажите его Telegram ID", username)
+	}
+	return userID, username, nil
+}
+
+// parseRoles разбирает роли, перечисленные через запятую или пробел
+func parseRoles(args []string) []string {
+	var roles []string
+	for _, arg := range args {
+		for _, role := range strings.Split(arg, ",") {
+			if role = strings.TrimSpace(role); role != "" {
+				roles = append(roles, role)
+			}
+		}
+	}
+	return roles
+}
+
+func joinOrDash(values []string) string {
+	if len(values) == 0 {
+		return "—"
+	}
+	return strings.Join(values, ", ")
+}
@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab-orchestrator-bot/client"
+	"gitlab-orchestrator-bot/config"
+
+	tele "gopkg.in/telebot.v3"
+)
+
+// startVersionSelection после выбора продуктов предлагает выбрать версию каждого из них
+func startVersionSelection(c tele.Context, user *config.UserContext) error {
+	subos, err := fetchSubos()
+	if err != nil {
+		return fmt.Errorf("ошибка при получении списка продуктов: %v", err)
+	}
+
+	user.Versions = make(map[string]string)
+	user.VersionQueue = NameToCodeSubo(user.FilterSubos, subos)
+	sort.Strings(user.VersionQueue)
+	return askNextVersion(c, user)
+}
+
+// askNextVersion показывает версии следующего продукта из очереди.
+// Продукты без версий в реестре пропускаются, после последнего продукта предлагается выбрать регион
+func askNextVersion(c tele.Context, user *config.UserContext) error {
+	for len(user.VersionQueue) > 0 {
+		code := user.VersionQueue[0]
+		versions, err := client.FetchProductVersions(code)
+		if err != nil {
+			return c.Send(fmt.Sprintf("Ошибка при получении версий продукта %s: %v", code, err))
+		}
+		if len(versions) == 0 {
+			user.VersionQueue = user.VersionQueue[1:]
+			continue
+		}
+
+		// Показываем последние версии, реестр возвращает их по возрастанию
+		if len(versions) > config.MaxVersionButtons {
+			versions = versions[len(versions)-config.MaxVersionButtons:]
+		}
+		markup := &tele.ReplyMarkup{}
+		var row []tele.InlineButton
+		for i := len(versions) - 1; i >= 0; i-- {
+			row = append(row, tele.InlineButton{Unique: config.BtnVersion, Text: versions[i], Data: code + "|" + versions[i]})
+			if len(row) == config.NumberOfLinesSubos {
+				markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+				row = []tele.InlineButton{}
+			}
+		}
+		if len(row) > 0 {
+			markup.InlineKeyboard = append(markup.InlineKeyboard, row)
+		}
+		markup.InlineKeyboard = append(markup.InlineKeyboard, []tele.InlineButton{
+			{Unique: config.BtnVersion, Text: "По умолчанию", Data: code + "|"},
+			{Unique: config.BtnCancel, Text: "❌ Отмена", Data: "cancel"},
+		})
+		return c.Edit(fmt.Sprintf("Выберите версию продукта %s", code), markup)
+	}
+	return askRegion(c, user)
+}
+
+// SelectVersionHandler запоминает выбранную версию продукта и переходит к следующему
+func SelectVersionHandler(c tele.Context) error {
+	user := config.UserStates[c.Sender().ID]
+	code, version, found := strings.Cut(c.Callback().Data, "|")
+	if !found || len(user.VersionQueue) == 0 || user.VersionQueue[0] != code {
+		return c.Respond(&tele.CallbackResponse{Text: "Выбор версии устарел"})
+	}
+
+	if version != "" {
+		user.Versions[code] = version
+	}
+	user.VersionQueue = user.VersionQueue[1:]
+	return askNextVersion(c, user)
+}
+
+// selectedProductsText возвращает выбранные продукты с версиями для подтверждения
+func selectedProductsText(user *config.UserContext) string {
+	subos, err := fetchSubos()
+	if err != nil {
+		return fmt.Sprintf("%v", user.FilterSubos)
+	}
+
+	var lines []string
+	for _, code := range NameToCodeSubo(user.FilterSubos, subos) {
+		version := user.Versions[code]
+		if version == "" {
+			version = "по умолчанию"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", subos[code], version))
+	}
+	sort.Strings(lines)
+	return strings.Join(lines, "\n")
+}
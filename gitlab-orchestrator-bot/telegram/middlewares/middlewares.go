@@ -3,14 +3,20 @@ package middlewares
 import (
 	"fmt"
 	"gitlab-orchestrator-bot/config"
+	"gitlab-orchestrator-bot/telegram/handlers"
 	tele "gopkg.in/telebot.v3"
 	"log"
 )
 
 func UserCheckMiddleware(next tele.HandlerFunc) tele.HandlerFunc {
 	return func(c tele.Context) error {
-		if !config.AllowedUsers[c.Sender().ID] {
-			return c.Send("Не авторизирован")
+		config.RememberUser(c.Sender().Username, c.Sender().ID)
+		if !config.IsUser(c.Sender().ID) {
+			// Кнопка «Запросить доступ» доступна неизвестным пользователям
+			if callback := c.Callback(); callback != nil && callback.Unique == config.BtnRequestAccess {
+				return next(c)
+			}
+			return handlers.UnauthorizedHandler(c)
 		}
 		return next(c)
 	}
@@ -18,7 +24,7 @@ func UserCheckMiddleware(next tele.HandlerFunc) tele.HandlerFunc {
 
 func AdminCheckMiddleware(next tele.HandlerFunc) tele.HandlerFunc {
 	return func(c tele.Context) error {
-		if !config.AllowedAdmins[c.Sender().ID] {
+		if !config.IsAdmin(c.Sender().ID) {
 			return c.Send("Не авторизирован")
 		}
 		return next(c)
@@ -34,10 +40,10 @@ func Logger(next tele.HandlerFunc) tele.HandlerFunc {
 			config.UserStates[id] = &config.UserContext{}
 		}
 		role := ""
-		if config.AllowedAdmins[id] {
+		if config.IsAdmin(id) {
 			role += "admin "
 		}
-		if config.AllowedUsers[id] {
+		if config.IsUser(id) {
 			role += "user "
 		}
 